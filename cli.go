@@ -0,0 +1,1242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"macos-cleaner/internal/cleaner"
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/hashcache"
+	"macos-cleaner/internal/history"
+	"macos-cleaner/internal/imagehash"
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/output"
+	"macos-cleaner/internal/quarantine"
+	"macos-cleaner/internal/scanner"
+	"macos-cleaner/internal/scope"
+	"macos-cleaner/internal/utils"
+)
+
+// Exit codes returned by the non-interactive commands
+const (
+	exitOK          = 0   // Everything succeeded
+	exitError       = 1   // At least one scan or delete operation failed
+	exitUsage       = 2   // Invalid command line
+	exitInterrupted = 130 // Cancelled by SIGINT or SIGTERM; results are partial
+)
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// cli runs the non-interactive subcommands
+type cli struct {
+	scanner *scanner.Scanner
+	cleaner *cleaner.Cleaner
+	history *history.Store
+	hashes  *hashcache.Cache
+	targets []models.CleanupTarget
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func newCLI(set settings) *cli {
+	sudoMgr := utils.NewSudoManager()
+	s := scanner.New(sudoMgr)
+	s.Scope = set.scope
+	s.KeepPolicy = set.keep
+	s.KeepPreferred = set.prefer
+	s.Duplicates = set.filter
+	s.ImageHash = set.imageHash
+	s.ImageDistance = set.distance
+	// Count like the TUI unless --size says otherwise, so the finders, which
+	// take no --size, report and record the same freed bytes
+	s.SizeMode = utils.SizeAllocated
+	c := cleaner.New(sudoMgr)
+	c.SizeMode = utils.SizeAllocated
+	c.Protected = set.protected
+	return &cli{
+		scanner: s,
+		cleaner: c,
+		history: history.New(history.DefaultPath()),
+		hashes:  hashcache.New(hashcache.DefaultPath()),
+		targets: set.targets,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+}
+
+// run dispatches args to a subcommand and returns the process exit code.
+// Scans and deletions stop early once ctx is done.
+func (c *cli) run(ctx context.Context, args []string) int {
+	switch args[0] {
+	case "scan":
+		return c.runScan(ctx, args[1:])
+	case "clean":
+		return c.runClean(ctx, args[1:])
+	case "bigfiles":
+		return c.runBigFiles(ctx, args[1:])
+	case "duplicates":
+		return c.runDuplicates(ctx, args[1:])
+	case "oldfiles":
+		return c.runOldFiles(ctx, args[1:])
+	case "targets":
+		return c.runTargets(args[1:])
+	case "restore":
+		return c.runRestore(args[1:])
+	case "purge":
+		return c.runPurge(args[1:])
+	case "history":
+		return c.runHistory(args[1:])
+	case "cache":
+		return c.runCache(args[1:])
+	case "help", "-h", "--help":
+		c.usage(c.stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(c.stderr, "unknown command %q\n\n", args[0])
+		c.usage(c.stderr)
+		return exitUsage
+	}
+}
+
+func (c *cli) usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: macos-cleaner [command] [flags]
+
+Without a command the interactive menu is started.
+
+Commands:
+  scan          Calculate the size of cleanup targets
+  clean         Clean cleanup targets
+  bigfiles      Find (and optionally delete) large files
+  duplicates    Find (and optionally delete) duplicate files
+  oldfiles      Find (and optionally delete) files not modified recently
+  targets list  List the available cleanup targets
+  restore       List or restore items moved to the quarantine with --trash
+  purge         Permanently delete old items from the quarantine
+  history       Show space reclaimed per target over past runs
+  cache clear   Forget the file hashes remembered by duplicate scans
+
+Run "macos-cleaner <command> -h" for the flags of a command. Every command
+accepts --format text|json|ndjson|csv.
+
+Exit codes: 0 success, 1 an operation failed, 2 invalid usage,
+130 interrupted (results are partial).
+`)
+}
+
+// flagSet creates a flag set that reports errors on stderr
+func (c *cli) flagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: macos-cleaner %s\n\nFlags:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args; when ok is false the command should exit with code
+func (c *cli) parse(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(c.stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// progress returns a progress callback printing to stderr when verbose is set
+func (c *cli) progress(verbose bool) func(string) {
+	if !verbose {
+		return func(string) {}
+	}
+	return func(status string) {
+		fmt.Fprintln(c.stderr, status)
+	}
+}
+
+// selectTargets applies --target/--category/--all to c.targets
+func (c *cli) selectTargets(names, categories []string, all bool) int {
+	if all {
+		for i := range c.targets {
+			c.targets[i].Selected = true
+		}
+		return exitOK
+	}
+	if len(names) == 0 && len(categories) == 0 {
+		fmt.Fprintln(c.stderr, "no targets selected: use --target, --category or --all")
+		return exitUsage
+	}
+	if err := models.SelectTargets(c.targets, names, categories); err != nil {
+		fmt.Fprintf(c.stderr, "%v (see \"macos-cleaner targets list\")\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// addFormat registers the --format flag
+func addFormat(fs *flag.FlagSet) *string {
+	return fs.String("format", "text", "output format: text, json, ndjson or csv")
+}
+
+// parseFormat validates the --format value
+func (c *cli) parseFormat(s string) (output.Format, int) {
+	format, err := output.ParseFormat(s)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return "", exitUsage
+	}
+	return format, exitOK
+}
+
+// write serializes records for the machine-readable formats
+func write[T output.Record](c *cli, format output.Format, records []T) int {
+	if err := output.Write(c.stdout, format, records); err != nil {
+		fmt.Fprintf(c.stderr, "write output: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// addTrash registers the --trash flag
+func addTrash(fs *flag.FlagSet) *bool {
+	return fs.Bool("trash", false, "move items to the MaCleaner quarantine instead of deleting them")
+}
+
+// useTrash makes the cleaner move items to the quarantine
+func (c *cli) useTrash() {
+	c.cleaner.Trash = quarantine.New(quarantine.DefaultDir(), c.cleaner.SudoManager)
+}
+
+// addSizeMode registers the --size flag
+func addSizeMode(fs *flag.FlagSet) *string {
+	return fs.String("size", utils.SizeAllocated.String(), "count target sizes as allocated (on-disk blocks) or apparent bytes")
+}
+
+// setSizeMode validates --size and applies it to the scanner and cleaner
+func (c *cli) setSizeMode(s string) int {
+	mode, err := utils.ParseSizeMode(s)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--size: %v\n", err)
+		return exitUsage
+	}
+	c.scanner.SizeMode = mode
+	c.cleaner.SizeMode = mode
+	return exitOK
+}
+
+// scopeFlags are the finder flags that adjust the scan scope
+type scopeFlags struct {
+	dirs     stringList
+	exclude  stringList
+	hidden   *bool
+	noIgnore *bool
+}
+
+// addScope registers --dir, --exclude, --hidden and --no-ignore-files
+func addScope(fs *flag.FlagSet) *scopeFlags {
+	f := &scopeFlags{}
+	fs.Var(&f.dirs, "dir", "directory to search instead of the defaults (repeatable)")
+	fs.Var(&f.exclude, "exclude", "gitignore-style pattern to skip, ! re-includes (repeatable)")
+	f.hidden = fs.Bool("hidden", false, "include dot files and directories")
+	f.noIgnore = fs.Bool("no-ignore-files", false, "do not read "+scope.IgnoreFileName+" files")
+	return f
+}
+
+// setScope validates the scope flags and applies them to the scanner
+func (c *cli) setScope(f *scopeFlags) int {
+	s := c.scanner.Scope
+	if len(f.dirs) > 0 {
+		s.Roots = f.dirs
+	}
+	if err := (scope.Scope{Exclude: f.exclude}).Validate(); err != nil {
+		fmt.Fprintf(c.stderr, "--exclude: %v\n", err)
+		return exitUsage
+	}
+	s.Exclude = append(append([]string(nil), s.Exclude...), f.exclude...)
+	if *f.hidden {
+		s.Hidden = true
+	}
+	if *f.noIgnore {
+		s.IgnoreFiles = false
+	}
+	c.scanner.Scope = s
+	return exitOK
+}
+
+// addJobs registers the --jobs flag
+func addJobs(fs *flag.FlagSet) *int {
+	return fs.Int("jobs", 0, "directories walked and files hashed in parallel (0 = one per CPU)")
+}
+
+// setJobs validates --jobs and applies it to the scanner
+func (c *cli) setJobs(jobs int) int {
+	if jobs < 0 {
+		fmt.Fprintln(c.stderr, "--jobs must not be negative")
+		return exitUsage
+	}
+	c.scanner.Workers = jobs
+	return exitOK
+}
+
+func (c *cli) runScan(ctx context.Context, args []string) int {
+	fs := c.flagSet("scan", "scan [--target NAME]... [--category NAME]... [--all]")
+	var names, categories stringList
+	fs.Var(&names, "target", "target name (repeatable)")
+	fs.Var(&categories, "category", "target category (repeatable)")
+	all := fs.Bool("all", false, "select every target")
+	jobs := addJobs(fs)
+	sizeMode := addSizeMode(fs)
+	formatStr := addFormat(fs)
+	verbose := fs.Bool("v", false, "print progress to stderr")
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	if code := c.selectTargets(names, categories, *all); code != exitOK {
+		return code
+	}
+	if code := c.setJobs(*jobs); code != exitOK {
+		return code
+	}
+	if code := c.setSizeMode(*sizeMode); code != exitOK {
+		return code
+	}
+
+	progress := c.progress(*verbose)
+	var scanned []models.CleanupTarget
+	var scanErr error
+	for i := range c.targets {
+		target := &c.targets[i]
+		if !target.Selected {
+			continue
+		}
+		progress("Scanning: " + target.Name)
+		target.Size, scanErr = c.scanner.CalculateSizeForTargetContext(ctx, target)
+		scanned = append(scanned, *target)
+		if scanErr != nil {
+			break
+		}
+	}
+
+	if format != output.FormatText {
+		if code := write(c, format, output.FromTargets(scanned)); code != exitOK {
+			return code
+		}
+		return c.finish(scanErr, exitOK)
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCATEGORY\tSIZE\tSUDO")
+	for _, target := range scanned {
+		total += target.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", target.Name, target.Category, utils.FormatBytes(target.Size), yesNo(target.RequiresSudo))
+	}
+	w.Flush()
+	fmt.Fprintf(c.stdout, "\nTotal: %s\n", utils.FormatBytes(total))
+	return c.finish(scanErr, exitOK)
+}
+
+func (c *cli) runClean(ctx context.Context, args []string) int {
+	fs := c.flagSet("clean", "clean [--target NAME]... [--category NAME]... [--all] --yes|--dry-run")
+	var names, categories stringList
+	fs.Var(&names, "target", "target name (repeatable)")
+	fs.Var(&categories, "category", "target category (repeatable)")
+	all := fs.Bool("all", false, "select every target")
+	yes := fs.Bool("yes", false, "confirm deletion (required unless --dry-run)")
+	dryRun := fs.Bool("dry-run", false, "list what would be deleted without deleting")
+	trash := addTrash(fs)
+	sizeMode := addSizeMode(fs)
+	formatStr := addFormat(fs)
+	verbose := fs.Bool("v", false, "print progress to stderr")
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	if code := c.selectTargets(names, categories, *all); code != exitOK {
+		return code
+	}
+	if code := c.setSizeMode(*sizeMode); code != exitOK {
+		return code
+	}
+	if !*yes && !*dryRun {
+		fmt.Fprintln(c.stderr, "refusing to clean without --yes")
+		return exitUsage
+	}
+	if *trash {
+		c.useTrash()
+	}
+
+	progress := c.progress(*verbose)
+	for i := range c.targets {
+		if c.targets[i].Selected {
+			progress("Scanning: " + c.targets[i].Name)
+			size, err := c.scanner.CalculateSizeForTargetContext(ctx, &c.targets[i])
+			if err != nil {
+				// Nothing has been deleted yet, so there is nothing partial to report
+				fmt.Fprintf(c.stderr, "interrupted: %v\n", err)
+				return exitInterrupted
+			}
+			c.targets[i].Size = size
+		}
+	}
+
+	if *dryRun {
+		var plan []models.PlannedDeletion
+		results, _ := c.dryRunCleaner(&plan).CleanTargets(c.targets, progress)
+		code := c.writePlan(format, plan)
+		for _, r := range results {
+			if r.Error != nil {
+				fmt.Fprintf(c.stderr, "%s: %v\n", r.Target, r.Error)
+				code = exitError
+			}
+		}
+		if c.reportRefused(results) {
+			code = exitError
+		}
+		return code
+	}
+
+	results, totalSaved, cleanErr := c.cleaner.CleanTargetsContext(ctx, c.targets, progress)
+	if len(results) == 0 && cleanErr == nil {
+		fmt.Fprintln(c.stderr, "cleanup aborted: administrator privileges were not granted")
+		return exitError
+	}
+	if len(results) > 0 {
+		c.record(history.FromCleanResults(results, *trash))
+	}
+
+	code = exitOK
+	for _, r := range results {
+		if r.Error != nil {
+			code = exitError
+		}
+	}
+	if c.reportRefused(results) {
+		code = exitError
+	}
+
+	if format != output.FormatText {
+		if wcode := write(c, format, output.FromCleanResults(results)); wcode != exitOK {
+			return wcode
+		}
+		return c.finish(cleanErr, code)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tREQUESTED\tFREED\tERROR")
+	for _, r := range results {
+		errStr := ""
+		if r.Error != nil {
+			errStr = r.Error.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Target, utils.FormatBytes(r.Requested), utils.FormatBytes(r.Actual), errStr)
+	}
+	w.Flush()
+	fmt.Fprintf(c.stdout, "\nSpace freed: %s\n", utils.FormatBytes(totalSaved))
+	return c.finish(cleanErr, code)
+}
+
+// reportRefused lists the paths the cleaner would not touch because they
+// resolve outside their target's base directory, and reports whether there
+// were any
+func (c *cli) reportRefused(results []cleaner.CleanResult) bool {
+	refused := false
+	for _, r := range results {
+		for _, path := range r.Refused {
+			fmt.Fprintf(c.stderr, "%s: refused %s: resolves outside the target directory\n", r.Target, path)
+			refused = true
+		}
+	}
+	return refused
+}
+
+func (c *cli) runBigFiles(ctx context.Context, args []string) int {
+	fs := c.flagSet("bigfiles", "bigfiles [--min-size SIZE] [--kind KIND]... [--dir DIR]... [--exclude PATTERN]... [--delete --yes | --dry-run]")
+	minSizeStr := fs.String("min-size", "100MB", "minimum file size, e.g. 500MB or 1.5GB")
+	var kindNames stringList
+	fs.Var(&kindNames, "kind", "only report files of this kind: "+filetype.KindNames()+" or other (repeatable)")
+	scopeOpts := addScope(fs)
+	jobs := addJobs(fs)
+	del := fs.Bool("delete", false, "delete every file found")
+	yes := fs.Bool("yes", false, "confirm deletion (required with --delete)")
+	dryRun := fs.Bool("dry-run", false, "list what --delete would remove without deleting")
+	trash := addTrash(fs)
+	formatStr := addFormat(fs)
+	verbose := fs.Bool("v", false, "print progress to stderr")
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	minSize, err := utils.ParseSize(*minSizeStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--min-size: %v\n", err)
+		return exitUsage
+	}
+	var kinds []filetype.Kind
+	for _, name := range kindNames {
+		// Unrecognised files can be listed too, though not filtered for duplicates
+		if strings.EqualFold(strings.TrimSpace(name), string(filetype.Other)) {
+			kinds = append(kinds, filetype.Other)
+			continue
+		}
+		kind, err := filetype.ParseKind(name)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "--kind: %v\n", err)
+			return exitUsage
+		}
+		kinds = append(kinds, kind)
+	}
+	if code := c.checkDelete(*del && !*dryRun, *yes); code != exitOK {
+		return code
+	}
+	if *trash {
+		c.useTrash()
+	}
+
+	if code := c.setJobs(*jobs); code != exitOK {
+		return code
+	}
+	if code := c.setScope(scopeOpts); code != exitOK {
+		return code
+	}
+	progress := c.progress(*verbose)
+	files, scanErr := c.scanner.ScanBigFilesContext(ctx, minSize, progress)
+	if len(kinds) > 0 {
+		files = slices.DeleteFunc(files, func(f models.BigFile) bool {
+			return !slices.Contains(kinds, f.Kind)
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Size > files[j].Size
+	})
+
+	if *dryRun {
+		var plan []models.PlannedDeletion
+		c.dryRunCleaner(&plan).DeleteBigFiles(files, selectAll(len(files)), progress)
+		return c.finish(scanErr, c.writePlan(format, plan))
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+
+	// A deletion writes its per-file results instead of the list
+	deleting := scanErr == nil && *del && len(files) > 0
+	if format != output.FormatText {
+		if !deleting {
+			if code := write(c, format, output.FromBigFiles(files)); code != exitOK {
+				return code
+			}
+		}
+	} else {
+		w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SIZE\tKIND\tMODIFIED\tPATH")
+		for _, f := range files {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", utils.FormatBytes(f.Size), f.Kind, f.ModTime.Format(time.DateOnly), f.Path)
+		}
+		w.Flush()
+		fmt.Fprintf(c.stdout, "\nFound %d files (%s)\n", len(files), utils.FormatBytes(total))
+		for _, k := range models.TotalByKind(files) {
+			fmt.Fprintf(w, "  %s\t%s\t(%d files)\n", k.Kind.Label(), utils.FormatBytes(k.Size), k.Count)
+		}
+		w.Flush()
+	}
+
+	// An interrupted scan is only reported; nothing is deleted from it
+	if !deleting {
+		return c.finish(scanErr, exitOK)
+	}
+	report, delErr := c.cleaner.DeleteBigFilesContext(ctx, files, selectAll(len(files)), progress)
+	c.record(history.FromDeletion("bigfiles", "Big Files", total, report, *trash))
+	return c.finish(delErr, c.reportDeletion(format, report))
+}
+
+func (c *cli) runDuplicates(ctx context.Context, args []string) int {
+	fs := c.flagSet("duplicates", "duplicates [--keep POLICY] [--prefer DIR]... [--min-size SIZE] [--max-size SIZE] [--ext EXT]... [--kind KIND]... [--dir DIR]... [--exclude PATTERN]... [--dirs | --similar [--image-hash ALGO] [--distance N]] [--delete | --dedupe MODE] [--yes | --dry-run] [--no-cache]")
+	keepStr := fs.String("keep", string(c.scanner.KeepPolicy), "copy to keep: not-downloads, newest, oldest, shortest or preferred")
+	var prefer stringList
+	fs.Var(&prefer, "prefer", "directory whose copy the preferred policy keeps, highest priority first (repeatable)")
+	minSizeStr := fs.String("min-size", "", "smallest file, or directory tree with --dirs, compared, e.g. 100KB (default 1MB)")
+	maxSizeStr := fs.String("max-size", "", "largest file, or directory tree with --dirs, compared, e.g. 4GB")
+	var exts, kinds stringList
+	fs.Var(&exts, "ext", "only compare files with this extension, e.g. psd (repeatable)")
+	fs.Var(&kinds, "kind", "only compare files of this kind: "+filetype.KindNames()+" (repeatable)")
+	dirs := fs.Bool("dirs", false, "find directory trees with identical contents instead of identical files")
+	similar := fs.Bool("similar", false, "find images that look alike (JPEG, PNG, GIF) instead of identical files")
+	imageHashStr := fs.String("image-hash", string(c.scanner.ImageHash), "perceptual hash for --similar: ahash, dhash or phash")
+	distance := fs.Int("distance", c.scanner.ImageDistance, "differing hash bits (0-64) up to which --similar groups images")
+	scopeOpts := addScope(fs)
+	jobs := addJobs(fs)
+	del := fs.Bool("delete", false, "delete every copy but the kept one of each group")
+	dedupeStr := fs.String("dedupe", "off", "replace every copy but the kept one with a clone or hard link: auto, clone or hardlink")
+	yes := fs.Bool("yes", false, "confirm deletion (required with --delete and --dedupe)")
+	dryRun := fs.Bool("dry-run", false, "list what --delete or --dedupe would change without changing it")
+	trash := addTrash(fs)
+	noCache := fs.Bool("no-cache", false, "hash every file instead of reusing the hashes of unchanged files")
+	formatStr := addFormat(fs)
+	verbose := fs.Bool("v", false, "print progress to stderr")
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	keep, err := models.ParseKeepPolicy(*keepStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--keep: %v\n", err)
+		return exitUsage
+	}
+	if len(prefer) > 0 {
+		c.scanner.KeepPreferred = prefer
+	}
+	if keep == models.KeepPreferred && len(c.scanner.KeepPreferred) == 0 {
+		fmt.Fprintln(c.stderr, "--keep preferred needs at least one --prefer directory")
+		return exitUsage
+	}
+	c.scanner.KeepPolicy = keep
+	imageHash, err := imagehash.ParseAlgorithm(*imageHashStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--image-hash: %v\n", err)
+		return exitUsage
+	}
+	if *distance < 0 || *distance > 64 {
+		fmt.Fprintf(c.stderr, "--distance: %d is not between 0 and 64\n", *distance)
+		return exitUsage
+	}
+	c.scanner.ImageHash, c.scanner.ImageDistance = imageHash, *distance
+	if code := c.setDuplicateFilter(*minSizeStr, *maxSizeStr, exts, kinds); code != exitOK {
+		return code
+	}
+	dedupe, err := cleaner.ParseDedupeMode(*dedupeStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--dedupe: %v\n", err)
+		return exitUsage
+	}
+	if *dirs && *similar {
+		fmt.Fprintln(c.stderr, "--dirs cannot be combined with --similar")
+		return exitUsage
+	}
+	if dedupe != cleaner.DedupeOff && (*similar || *dirs) {
+		fmt.Fprintln(c.stderr, "--dedupe only replaces identical files; it cannot be combined with --similar or --dirs")
+		return exitUsage
+	}
+	if dedupe != cleaner.DedupeOff && (*del || *trash) {
+		fmt.Fprintln(c.stderr, "--dedupe cannot be combined with --delete or --trash")
+		return exitUsage
+	}
+	c.cleaner.Dedupe = dedupe
+	*del = *del || dedupe != cleaner.DedupeOff
+	if code := c.checkDelete(*del && !*dryRun, *yes); code != exitOK {
+		return code
+	}
+	if *trash {
+		c.useTrash()
+	}
+
+	if code := c.setJobs(*jobs); code != exitOK {
+		return code
+	}
+	if code := c.setScope(scopeOpts); code != exitOK {
+		return code
+	}
+	progress := c.progress(*verbose)
+	var groups []models.DuplicateGroup
+	var totalSize int64
+	var scanErr error
+	if *similar {
+		groups, totalSize, scanErr = c.scanner.ScanSimilarImagesContext(ctx, progress)
+	} else {
+		if !*noCache {
+			c.loadHashes()
+			defer c.saveHashes()
+		}
+		if *dirs {
+			groups, totalSize, scanErr = c.scanner.ScanDuplicateDirsContext(ctx, progress)
+		} else {
+			groups, totalSize, scanErr = c.scanner.ScanDuplicatesContext(ctx, progress)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Size*int64(len(groups[i].Files)) > groups[j].Size*int64(len(groups[j].Files))
+	})
+
+	if *dryRun {
+		var plan []models.PlannedDeletion
+		c.dryRunCleaner(&plan).DeleteDuplicates(groups, selectAll(len(groups)), progress)
+		return c.finish(scanErr, c.writePlan(format, plan))
+	}
+
+	// A deletion writes its per-file results instead of the groups
+	deleting := scanErr == nil && *del && len(groups) > 0
+	if format != output.FormatText {
+		if !deleting {
+			if code := write(c, format, output.FromDuplicateGroups(groups)); code != exitOK {
+				return code
+			}
+		}
+	} else {
+		for i, group := range groups {
+			switch group.Kind {
+			case models.GroupSimilar:
+				fmt.Fprintf(c.stdout, "Group %d: %d similar images (%s)\n", i+1, len(group.Files), group.Hash)
+			case models.GroupDirectory:
+				fmt.Fprintf(c.stdout, "Group %d: %s x %d directories (%s)\n", i+1, utils.FormatBytes(group.Size), len(group.Files), group.Hash)
+			default:
+				fmt.Fprintf(c.stdout, "Group %d: %s x %d (%s)\n", i+1, utils.FormatBytes(group.Size), len(group.Files), group.Hash)
+			}
+			kept := group.Kept()
+			for j, file := range group.Files {
+				mark := " "
+				if slices.Contains(kept, file) {
+					mark = "*"
+				}
+				if group.Kind == models.GroupSimilar {
+					fmt.Fprintf(c.stdout, "%s %10s  %s\n", mark, utils.FormatBytes(group.FileSize(j)), file)
+				} else {
+					fmt.Fprintf(c.stdout, "%s %s\n", mark, file)
+				}
+			}
+		}
+		kind := models.GroupIdentical
+		if *similar {
+			kind = models.GroupSimilar
+		} else if *dirs {
+			kind = models.GroupDirectory
+		}
+		fmt.Fprintf(c.stdout, "\nFound %d duplicate groups among %s (%s reclaimable); * marks the copy kept (%s)\n",
+			len(groups), c.scanner.DescribeDuplicates(kind), utils.FormatBytes(totalSize), keep.Label())
+	}
+
+	// An interrupted scan is only reported; nothing is deleted from it
+	if !deleting {
+		return c.finish(scanErr, exitOK)
+	}
+	report, delErr := c.cleaner.DeleteDuplicatesContext(ctx, groups, selectAll(len(groups)), progress)
+	c.record(history.FromDeletion("duplicates", "Duplicates", totalSize, report, *trash))
+	return c.finish(delErr, c.reportDeletion(format, report))
+}
+
+func (c *cli) runOldFiles(ctx context.Context, args []string) int {
+	fs := c.flagSet("oldfiles", "oldfiles [--days N] [--by atime|mtime|ctime|birth] [--dir DIR]... [--exclude PATTERN]... [--delete --yes | --dry-run]")
+	days := fs.Int("days", 180, "minimum age in days")
+	byStr := fs.String("by", string(models.FileTimeAccessed), "timestamp that decides age: atime, mtime, ctime or birth")
+	scopeOpts := addScope(fs)
+	jobs := addJobs(fs)
+	del := fs.Bool("delete", false, "delete every file found")
+	yes := fs.Bool("yes", false, "confirm deletion (required with --delete)")
+	dryRun := fs.Bool("dry-run", false, "list what --delete would remove without deleting")
+	trash := addTrash(fs)
+	formatStr := addFormat(fs)
+	verbose := fs.Bool("v", false, "print progress to stderr")
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	if *days <= 0 {
+		fmt.Fprintln(c.stderr, "--days must be positive")
+		return exitUsage
+	}
+	by, err := models.ParseFileTime(*byStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--by: %v\n", err)
+		return exitUsage
+	}
+	if code := c.checkDelete(*del && !*dryRun, *yes); code != exitOK {
+		return code
+	}
+	if *trash {
+		c.useTrash()
+	}
+
+	if code := c.setJobs(*jobs); code != exitOK {
+		return code
+	}
+	if code := c.setScope(scopeOpts); code != exitOK {
+		return code
+	}
+	c.scanner.OldFilesBy = by
+	for _, warning := range c.scanner.AccessTimeWarnings() {
+		fmt.Fprintf(c.stderr, "warning: %s\n", warning)
+	}
+	progress := c.progress(*verbose)
+	files, scanErr := c.scanner.ScanOldFilesContext(ctx, *days, progress)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time(by).Before(files[j].Time(by))
+	})
+
+	if *dryRun {
+		var plan []models.PlannedDeletion
+		c.dryRunCleaner(&plan).DeleteOldFiles(files, selectAll(len(files)), progress)
+		return c.finish(scanErr, c.writePlan(format, plan))
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+
+	// A deletion writes its per-file results instead of the list
+	deleting := scanErr == nil && *del && len(files) > 0
+	if format != output.FormatText {
+		if !deleting {
+			if code := write(c, format, output.FromOldFiles(files)); code != exitOK {
+				return code
+			}
+		}
+	} else {
+		w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "SIZE\t%s\tPATH\n", strings.ToUpper(by.Label()))
+		for _, f := range files {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", utils.FormatBytes(f.Size), f.Time(by).Format(time.DateOnly), f.Path)
+		}
+		w.Flush()
+		fmt.Fprintf(c.stdout, "\nFound %d files (%s)\n", len(files), utils.FormatBytes(total))
+	}
+
+	// An interrupted scan is only reported; nothing is deleted from it
+	if !deleting {
+		return c.finish(scanErr, exitOK)
+	}
+	report, delErr := c.cleaner.DeleteOldFilesContext(ctx, files, selectAll(len(files)), progress)
+	c.record(history.FromDeletion("oldfiles", "Old Files", total, report, *trash))
+	return c.finish(delErr, c.reportDeletion(format, report))
+}
+
+func (c *cli) runTargets(args []string) int {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintln(c.stderr, "Usage: macos-cleaner targets list [--category NAME]...")
+		return exitUsage
+	}
+
+	fs := c.flagSet("targets list", "targets list [--category NAME]...")
+	var categories stringList
+	fs.Var(&categories, "category", "only list this category (repeatable)")
+	formatStr := addFormat(fs)
+	if code, ok := c.parse(fs, args[1:]); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	if len(categories) > 0 {
+		if err := models.SelectTargets(c.targets, nil, categories); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return exitUsage
+		}
+	}
+
+	var listed []models.CleanupTarget
+	for _, t := range c.targets {
+		if len(categories) == 0 || t.Selected {
+			listed = append(listed, t)
+		}
+	}
+
+	if format != output.FormatText {
+		return write(c, format, output.FromTargets(listed))
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCATEGORY\tSUDO\tPATH")
+	for _, t := range listed {
+		path := t.Path
+		if t.IsCommand {
+			path = "$ " + t.Command
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, t.Category, yesNo(t.RequiresSudo), path)
+	}
+	w.Flush()
+	return exitOK
+}
+
+func (c *cli) runCache(args []string) int {
+	if len(args) == 0 || args[0] != "clear" {
+		fmt.Fprintln(c.stderr, "Usage: macos-cleaner cache clear")
+		return exitUsage
+	}
+	fs := c.flagSet("cache clear", "cache clear")
+	if code, ok := c.parse(fs, args[1:]); !ok {
+		return code
+	}
+
+	if err := c.hashes.Clear(); err != nil {
+		fmt.Fprintf(c.stderr, "cache clear: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(c.stdout, "Cleared %s\n", c.hashes.Path)
+	return exitOK
+}
+
+// setDuplicateFilter applies the duplicates filter flags on top of the
+// configured filter; --ext and --kind replace the configured lists
+func (c *cli) setDuplicateFilter(minSize, maxSize string, exts, kinds []string) int {
+	f := c.scanner.Duplicates
+	var err error
+	if minSize != "" {
+		if f.MinSize, err = utils.ParseSize(minSize); err != nil {
+			fmt.Fprintf(c.stderr, "--min-size: %v\n", err)
+			return exitUsage
+		}
+	}
+	if maxSize != "" {
+		if f.MaxSize, err = utils.ParseSize(maxSize); err != nil {
+			fmt.Fprintf(c.stderr, "--max-size: %v\n", err)
+			return exitUsage
+		}
+	}
+	if len(exts) > 0 {
+		f.Extensions = nil
+		for _, ext := range exts {
+			f.Extensions = append(f.Extensions, filetype.NormalizeExtension(ext))
+		}
+	}
+	if len(kinds) > 0 {
+		f.Kinds = nil
+		for _, name := range kinds {
+			kind, err := filetype.ParseKind(name)
+			if err != nil {
+				fmt.Fprintf(c.stderr, "--kind: %v\n", err)
+				return exitUsage
+			}
+			f.Kinds = append(f.Kinds, kind)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		// Blame the flags given; a size not given comes from the config
+		name := "--min-size and --max-size"
+		switch {
+		case minSize == "" && maxSize == "":
+			name = "size range"
+		case minSize == "":
+			name = "--max-size"
+		case maxSize == "":
+			name = "--min-size"
+		}
+		fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+		return exitUsage
+	}
+	c.scanner.Duplicates = f
+	return exitOK
+}
+
+// loadHashes lets the scanner reuse the hashes of files unchanged since an
+// earlier duplicate scan. A broken cache only costs time, so it is reported
+// and the scan hashes every file.
+func (c *cli) loadHashes() {
+	if err := c.hashes.Load(); err != nil {
+		fmt.Fprintf(c.stderr, "load hash cache: %v\n", err)
+	}
+	c.scanner.Hashes = c.hashes
+}
+
+// saveHashes writes the hashes computed by the scan for the next one
+func (c *cli) saveHashes() {
+	if err := c.hashes.Save(); err != nil {
+		fmt.Fprintf(c.stderr, "save hash cache: %v\n", err)
+	}
+}
+
+// dryRunCleaner returns a cleaner that appends every planned deletion to plan
+func (c *cli) dryRunCleaner(plan *[]models.PlannedDeletion) *cleaner.Cleaner {
+	return c.cleaner.WithDryRun(func(p models.PlannedDeletion) {
+		*plan = append(*plan, p)
+	})
+}
+
+// writePlan prints a dry-run plan
+func (c *cli) writePlan(format output.Format, plan []models.PlannedDeletion) int {
+	if format != output.FormatText {
+		return write(c, format, output.FromPlan(plan))
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tSUDO\tREASON\tPATH")
+	for _, p := range plan {
+		total += p.Size
+		path := p.Path
+		if p.Command != "" {
+			path = "$ " + p.Command
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", utils.FormatBytes(p.Size), yesNo(p.Sudo), p.Reason, path)
+	}
+	w.Flush()
+	fmt.Fprintf(c.stdout, "\nDry run: would delete %d paths (%s)\n", len(plan), utils.FormatBytes(total))
+	return exitOK
+}
+
+func (c *cli) runRestore(args []string) int {
+	fs := c.flagSet("restore", "restore [--list] [--all] [ID]...")
+	list := fs.Bool("list", false, "list quarantined items")
+	all := fs.Bool("all", false, "restore every quarantined item")
+	formatStr := addFormat(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+
+	q := quarantine.New(quarantine.DefaultDir(), c.cleaner.SudoManager)
+	entries, err := q.List()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "read quarantine: %v\n", err)
+		return exitError
+	}
+
+	ids := fs.Args()
+	if *all {
+		ids = nil
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+	if *list || len(ids) == 0 {
+		if !*list && !*all {
+			fmt.Fprintln(c.stderr, "nothing to restore: pass IDs, --all or --list")
+			return exitUsage
+		}
+		return c.writeQuarantine(format, entries)
+	}
+
+	code = exitOK
+	var restored []quarantine.Entry
+	for _, id := range ids {
+		entry, err := q.Restore(id)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "%s: %v\n", id, err)
+			code = exitError
+			continue
+		}
+		restored = append(restored, entry)
+	}
+	if wcode := c.writeQuarantine(format, restored); wcode != exitOK {
+		return wcode
+	}
+	return code
+}
+
+func (c *cli) runPurge(args []string) int {
+	fs := c.flagSet("purge", "purge --older-than AGE")
+	olderThanStr := fs.String("older-than", "30d", "purge items quarantined longer ago than this, e.g. 30d, 2w or 0")
+	formatStr := addFormat(fs)
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+	olderThan, err := utils.ParseAge(*olderThanStr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "--older-than: %v\n", err)
+		return exitUsage
+	}
+
+	q := quarantine.New(quarantine.DefaultDir(), c.cleaner.SudoManager)
+	purged, err := q.Purge(olderThan)
+	code = exitOK
+	if err != nil {
+		fmt.Fprintf(c.stderr, "purge: %v\n", err)
+		code = exitError
+	}
+	if wcode := c.writeQuarantine(format, purged); wcode != exitOK {
+		return wcode
+	}
+	return code
+}
+
+// writeQuarantine prints quarantine entries
+func (c *cli) writeQuarantine(format output.Format, entries []quarantine.Entry) int {
+	if format != output.FormatText {
+		return write(c, format, output.FromQuarantine(entries))
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSIZE\tDATE\tOPERATION\tPATH")
+	for _, e := range entries {
+		total += e.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ID, utils.FormatBytes(e.Size), e.Time.Format(time.DateTime), e.Operation, e.OriginalPath)
+	}
+	w.Flush()
+	fmt.Fprintf(c.stdout, "\n%d items (%s)\n", len(entries), utils.FormatBytes(total))
+	return exitOK
+}
+
+func (c *cli) runHistory(args []string) int {
+	fs := c.flagSet("history", "history [--target NAME] [--runs]")
+	target := fs.String("target", "", "only show this target")
+	runs := fs.Bool("runs", false, "list every recorded run instead of per-target totals")
+	formatStr := addFormat(fs)
+	if code, ok := c.parse(fs, args); !ok {
+		return code
+	}
+	format, code := c.parseFormat(*formatStr)
+	if code != exitOK {
+		return code
+	}
+
+	recorded, err := c.history.Load()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "read history: %v\n", err)
+		return exitError
+	}
+	if *target != "" {
+		recorded = history.Filter(recorded, *target)
+	}
+
+	if *runs {
+		if format != output.FormatText {
+			return write(c, format, output.FromHistoryRuns(recorded))
+		}
+		w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DATE\tOPERATION\tTARGET\tREQUESTED\tFREED\tERROR")
+		for _, run := range recorded {
+			for _, r := range run.Results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", run.Time.Format(time.DateTime), run.Operation, r.Target,
+					utils.FormatBytes(r.Requested), utils.FormatBytes(r.Freed), r.Error)
+			}
+		}
+		w.Flush()
+		return exitOK
+	}
+
+	summaries := history.Summarize(recorded)
+	if format != output.FormatText {
+		return write(c, format, output.FromHistorySummaries(summaries))
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tRUNS\tFAILED\tTOTAL FREED\tLAST FREED\tREGROWTH/DAY\tLAST RUN")
+	for _, s := range summaries {
+		total += s.TotalFreed
+		regrowth := "-"
+		if s.RegrowthPerDay > 0 {
+			regrowth = utils.FormatBytes(s.RegrowthPerDay)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", s.Target, s.Runs, s.Failures, utils.FormatBytes(s.TotalFreed),
+			utils.FormatBytes(s.LastFreed), regrowth, s.LastRun.Format(time.DateOnly))
+	}
+	w.Flush()
+	fmt.Fprintf(c.stdout, "\nTotal reclaimed: %s\n", utils.FormatBytes(total))
+	return exitOK
+}
+
+// record appends a run to the history; a failure is reported but does not
+// fail the command since the deletion itself already happened
+func (c *cli) record(run history.Run) {
+	if err := c.history.Append(run); err != nil {
+		fmt.Fprintf(c.stderr, "record history: %v\n", err)
+	}
+}
+
+// finish returns code, or exitInterrupted with a note on stderr when err
+// reports that the operation was cancelled before it completed
+func (c *cli) finish(err error, code int) int {
+	if err == nil {
+		return code
+	}
+	fmt.Fprintf(c.stderr, "interrupted: %v (results are partial)\n", err)
+	return exitInterrupted
+}
+
+// checkDelete validates the --delete/--yes combination
+func (c *cli) checkDelete(del, yes bool) int {
+	if del && !yes {
+		fmt.Fprintln(c.stderr, "refusing to delete without --yes")
+		return exitUsage
+	}
+	return exitOK
+}
+
+// reportDeletion writes the per-file results of a finder deletion: in JSON
+// as one object holding the records and the summary, as records in the other
+// machine-readable formats, otherwise one line per failure on stderr. It
+// fails if any selected path is still there.
+func (c *cli) reportDeletion(format output.Format, report cleaner.DeleteReport) int {
+	failures := report.Failures()
+	switch format {
+	case output.FormatJSON:
+		if err := output.WriteJSON(c.stdout, output.FromDeleteSummary(report)); err != nil {
+			fmt.Fprintf(c.stderr, "write output: %v\n", err)
+			return exitError
+		}
+	case output.FormatText:
+		for _, f := range failures {
+			fmt.Fprintf(c.stderr, "%s: %s: %v\n", f.Status.Label(), f.Path, f.Err)
+		}
+		fmt.Fprintf(c.stdout, "Space freed: %s (%s)\n", utils.FormatBytes(report.Freed()), report.Summary())
+	default:
+		if code := write(c, format, output.FromDeleteReport(report)); code != exitOK {
+			return code
+		}
+		fmt.Fprintf(c.stderr, "Space freed: %s (%s)\n", utils.FormatBytes(report.Freed()), report.Summary())
+	}
+	if len(failures) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// selectAll returns a selection map covering n items
+func selectAll(n int) map[int]bool {
+	selected := make(map[int]bool, n)
+	for i := 0; i < n; i++ {
+		selected[i] = true
+	}
+	return selected
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -2,6 +2,7 @@
 package cleaner
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,12 +11,20 @@
 	"time"
 
 	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/quarantine"
 	"macos-cleaner/internal/utils"
 )
 
 // Cleaner handles file deletion operations
 type Cleaner struct {
 	SudoManager *utils.SudoManager
+	Trash       *quarantine.Quarantine // When set, items are moved here instead of being deleted
+	SizeMode    utils.SizeMode         // How freed bytes are counted; match the Scanner's
+	Protected   []string               // Extra paths never deleted, nor anything below them; the built-in list always applies
+	Dedupe      DedupeMode             // How DeleteDuplicates frees the removed copies
+
+	dryRun bool
+	onPlan func(models.PlannedDeletion)
 }
 
 // New creates a new Cleaner
@@ -25,17 +34,42 @@ func New(sudoMgr *utils.SudoManager) *Cleaner {
 	}
 }
 
+// WithDryRun returns a copy of the cleaner that goes through the same glob
+// expansion and sudo decisions but reports every path it would delete to
+// onPlan instead of touching the disk
+func (c *Cleaner) WithDryRun(onPlan func(models.PlannedDeletion)) *Cleaner {
+	dry := *c
+	dry.dryRun = true
+	dry.onPlan = onPlan
+	return &dry
+}
+
+// plan reports a path a dry run would delete
+func (c *Cleaner) plan(p models.PlannedDeletion) {
+	if c.onPlan != nil {
+		c.onPlan(p)
+	}
+}
+
 // CleanResult represents the result of a cleaning operation
 type CleanResult struct {
 	Target    string
 	Requested int64
 	Actual    int64
 	Error     error
+	Refused   []string // Matches left alone because they resolve outside the target's base directory
 	Timestamp time.Time
 }
 
 // CleanTargets cleans the selected targets and returns actual space freed
 func (c *Cleaner) CleanTargets(targets []models.CleanupTarget, progress func(string)) ([]CleanResult, int64) {
+	results, totalSaved, _ := c.CleanTargetsContext(context.Background(), targets, progress)
+	return results, totalSaved
+}
+
+// CleanTargetsContext is CleanTargets that stops once ctx is done. Targets
+// not started are left out of the results and ctx.Err() is returned.
+func (c *Cleaner) CleanTargetsContext(ctx context.Context, targets []models.CleanupTarget, progress func(string)) ([]CleanResult, int64, error) {
 	var results []CleanResult
 	var totalSaved int64
 
@@ -48,10 +82,10 @@ func (c *Cleaner) CleanTargets(targets []models.CleanupTarget, progress func(str
 		}
 	}
 
-	// Authenticate once if needed
-	if needsSudo {
+	// Authenticate once if needed (a dry run never escalates)
+	if needsSudo && !c.dryRun {
 		if err := c.SudoManager.EnsureSudo(); err != nil {
-			return results, 0
+			return results, 0, nil
 		}
 	}
 
@@ -59,24 +93,29 @@ func (c *Cleaner) CleanTargets(targets []models.CleanupTarget, progress func(str
 		if !targets[i].Selected {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			return results, totalSaved, err
+		}
 
 		target := &targets[i]
 		progress("Cleaning: " + target.Name)
 
-		result := c.cleanTarget(target)
+		result := c.cleanTarget(ctx, target)
 		results = append(results, result)
 
 		if result.Error == nil {
 			totalSaved += result.Actual
-			target.Size = 0 // Reset size after successful cleaning
+			if !c.dryRun {
+				target.Size = 0 // Reset size after successful cleaning
+			}
 		}
 	}
 
-	return results, totalSaved
+	return results, totalSaved, ctx.Err()
 }
 
 // cleanTarget cleans a single target and returns the actual space freed
-func (c *Cleaner) cleanTarget(target *models.CleanupTarget) CleanResult {
+func (c *Cleaner) cleanTarget(ctx context.Context, target *models.CleanupTarget) CleanResult {
 	result := CleanResult{
 		Target:    target.Name,
 		Requested: target.Size,
@@ -84,7 +123,17 @@ func (c *Cleaner) cleanTarget(target *models.CleanupTarget) CleanResult {
 	}
 
 	if target.IsCommand && target.Command != "" {
-		if err := c.executeCommand(target.Command); err != nil {
+		if c.dryRun {
+			c.plan(models.PlannedDeletion{
+				Size:    target.Size,
+				Reason:  target.Name,
+				Sudo:    target.RequiresSudo,
+				Command: target.Command,
+			})
+			result.Actual = target.Size
+			return result
+		}
+		if err := c.executeCommand(ctx, target.Command); err != nil {
 			result.Error = fmt.Errorf("command failed: %w", err)
 		}
 		// For command-based targets, assume all requested space is freed
@@ -108,6 +157,20 @@ func (c *Cleaner) cleanTarget(target *models.CleanupTarget) CleanResult {
 		return result
 	}
 
+	if c.dryRun {
+		// Report what each match would free, as seen by the deletion itself
+		sub := c.WithDryRun(func(p models.PlannedDeletion) {
+			result.Actual += p.Size
+			c.plan(p)
+		})
+		refused, err := sub.deletePath(ctx, path, target.RequiresSudo, target.Name)
+		result.Refused = refused
+		if err != nil {
+			result.Error = fmt.Errorf("failed to delete %s: %w", target.Path, err)
+		}
+		return result
+	}
+
 	// Calculate actual size BEFORE deletion
 	actualBefore := c.calculateActualSize(path)
 
@@ -116,10 +179,14 @@ func (c *Cleaner) cleanTarget(target *models.CleanupTarget) CleanResult {
 		// Try to clean anyway
 	}
 
-	// Perform deletion
-	if err := c.deletePath(path, target.RequiresSudo); err != nil {
+	// Perform deletion; a cancelled target still reports what it freed
+	refused, err := c.deletePath(ctx, path, target.RequiresSudo, target.Name)
+	result.Refused = refused
+	if err != nil {
 		result.Error = fmt.Errorf("failed to delete %s: %w", target.Path, err)
-		return result
+		if ctx.Err() == nil {
+			return result
+		}
 	}
 
 	// Wait a moment for filesystem to sync
@@ -134,88 +201,59 @@ func (c *Cleaner) cleanTarget(target *models.CleanupTarget) CleanResult {
 	return result
 }
 
-// calculateActualSize calculates the actual disk space used by a path
-// This uses a more robust method that handles wildcards properly
+// calculateActualSize calculates the disk space used by the paths matching
+// pattern, counted like Scanner.CalculateSize so the two agree
 func (c *Cleaner) calculateActualSize(pattern string) int64 {
-	// Handle wildcards by finding matching paths
-	if strings.Contains(pattern, "*") {
-		basePath := strings.Split(pattern, "*")[0]
-		var total int64
-
-		// Use find command for more accurate results with wildcards
-		cmd := exec.Command("find", basePath, "-type", "f", "-print0")
-		output, err := cmd.Output()
-		if err != nil {
-			// Fallback to walk
-			return c.walkCalculateSize(basePath, pattern)
-		}
-
-		// Parse null-terminated output
-		files := strings.Split(string(output), "\x00")
-		for _, file := range files {
-			if file == "" {
-				continue
-			}
-			if matched, _ := filepath.Match(pattern, file); matched {
-				if info, err := os.Stat(file); err == nil && !info.IsDir() {
-					total += info.Size()
-				}
-			}
-		}
-		return total
-	}
-
-	// No wildcard, use standard calculation
-	info, err := os.Stat(pattern)
+	matches, err := utils.SafeGlob(pattern)
 	if err != nil {
 		return 0
 	}
 
-	if info.IsDir() {
-		return utils.DirSize(pattern)
-	}
-	return info.Size()
-}
-
-// walkCalculateSize calculates size by walking directory
-func (c *Cleaner) walkCalculateSize(basePath, pattern string) int64 {
+	counter := utils.NewSizeCounter(c.SizeMode)
 	var total int64
-
-	filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+	for _, match := range matches {
+		info, err := os.Lstat(match)
 		if err != nil {
-			return nil
+			continue
 		}
 		if info.IsDir() {
-			return nil
-		}
-
-		// Simple glob match
-		if matched, _ := filepath.Match(pattern, path); matched {
-			total += info.Size()
+			size, _ := utils.DirUsageContext(context.Background(), match, 0, counter)
+			total += size
+		} else {
+			total += counter.Add(info)
 		}
-		return nil
-	})
-
+	}
 	return total
 }
 
-// deletePath deletes a path, using sudo if required
-func (c *Cleaner) deletePath(path string, useSudo bool) error {
+// deletePath deletes a path, using sudo if required. Glob matches that
+// resolve outside the pattern's base directory are refused and returned
+// instead of deleted. Matches not yet deleted when ctx is done are skipped.
+func (c *Cleaner) deletePath(ctx context.Context, path string, useSudo bool, reason string) ([]string, error) {
 	// Handle wildcards by expanding and deleting each match
 	if strings.Contains(path, "*") {
 		matches, err := filepath.Glob(path)
 		if err != nil {
-			return fmt.Errorf("glob failed: %w", err)
+			return nil, fmt.Errorf("glob failed: %w", err)
 		}
 
 		if len(matches) == 0 {
-			return nil // Nothing to delete
+			return nil, nil // Nothing to delete
 		}
 
+		base := utils.GlobBase(path)
+		var refused []string
 		var lastErr error
 		deletedCount := 0
 		for _, match := range matches {
-			if err := c.deleteSinglePath(match, useSudo); err != nil {
+			if err := ctx.Err(); err != nil {
+				return refused, err
+			}
+			if !utils.Within(match, base) {
+				refused = append(refused, match)
+				continue
+			}
+			if err := c.deleteSinglePath(match, useSudo, reason); err != nil {
 				lastErr = err
 				// Continue trying to delete other matches
 				continue
@@ -224,18 +262,23 @@ func (c *Cleaner) deletePath(path string, useSudo bool) error {
 		}
 
 		if lastErr != nil && deletedCount == 0 {
-			return fmt.Errorf("failed to delete any files: %w", lastErr)
+			return refused, fmt.Errorf("failed to delete any files: %w", lastErr)
 		}
-		return nil
+		return refused, nil
 	}
 
-	return c.deleteSinglePath(path, useSudo)
+	return nil, c.deleteSinglePath(path, useSudo, reason)
 }
 
-// deleteSinglePath deletes a single file or directory
-func (c *Cleaner) deleteSinglePath(path string, useSudo bool) error {
+// deleteSinglePath deletes a single file or directory. A symlink is removed
+// itself; what it points to is left alone.
+func (c *Cleaner) deleteSinglePath(path string, useSudo bool, reason string) error {
+	if err := c.checkProtected(path); err != nil {
+		return err
+	}
+
 	// Check if path exists
-	info, err := os.Stat(path)
+	info, err := os.Lstat(path)
 	if os.IsNotExist(err) {
 		return nil // Already deleted
 	}
@@ -243,6 +286,23 @@ func (c *Cleaner) deleteSinglePath(path string, useSudo bool) error {
 		return fmt.Errorf("cannot access path: %w", err)
 	}
 
+	if c.dryRun {
+		counter := utils.NewSizeCounter(c.SizeMode)
+		size := counter.Add(info)
+		if info.IsDir() {
+			size, _ = utils.DirUsageContext(context.Background(), path, 0, counter)
+		}
+		c.plan(models.PlannedDeletion{Path: path, Size: size, Reason: reason, Sudo: useSudo})
+		return nil
+	}
+
+	if c.Trash != nil {
+		if _, err := c.Trash.Move(path, reason, useSudo); err != nil {
+			return fmt.Errorf("move to quarantine failed: %w", err)
+		}
+		return nil
+	}
+
 	if useSudo {
 		if err := c.SudoManager.Run("rm", "-rf", path); err != nil {
 			return fmt.Errorf("sudo rm failed: %w", err)
@@ -265,13 +325,13 @@ func (c *Cleaner) deleteSinglePath(path string, useSudo bool) error {
 }
 
 // executeCommand executes a shell command for special targets
-func (c *Cleaner) executeCommand(command string) error {
+func (c *Cleaner) executeCommand(ctx context.Context, command string) error {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
 		return fmt.Errorf("empty command")
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%v: %s", err, string(output))
@@ -279,85 +339,229 @@ func (c *Cleaner) executeCommand(command string) error {
 	return nil
 }
 
-// DeleteFiles deletes a list of files and returns total bytes freed
-func (c *Cleaner) DeleteFiles(files []string, progress func(string)) (int64, error) {
-	var totalDeleted int64
+// deletion is a file queued for deletion together with why it was selected
+type deletion struct {
+	path   string
+	reason string
+	keep   string // Kept copy a duplicate is replaced with when deduplicating
+	hash   string // Content hash of the duplicate group, if known
+}
+
+// DeleteFiles deletes a list of files and directories and reports the
+// outcome for each. Directories are removed with everything below them.
+func (c *Cleaner) DeleteFiles(files []string, progress func(string)) DeleteReport {
+	report, _ := c.DeleteFilesContext(context.Background(), files, progress)
+	return report
+}
 
+// DeleteFilesContext is DeleteFiles that stops once ctx is done, returning
+// the results so far together with ctx.Err()
+func (c *Cleaner) DeleteFilesContext(ctx context.Context, files []string, progress func(string)) (DeleteReport, error) {
+	items := make([]deletion, 0, len(files))
 	for _, file := range files {
-		progress("Deleting: " + utils.ShortenPath(file, 40))
+		items = append(items, deletion{path: file, reason: "selected file"})
+	}
+	return c.deleteFiles(ctx, items, progress)
+}
 
-		// Get size before deletion
-		info, err := os.Stat(file)
+// deleteFiles deletes the queued files. Files not reached before ctx is
+// done are left out of the report.
+func (c *Cleaner) deleteFiles(ctx context.Context, items []deletion, progress func(string)) (DeleteReport, error) {
+	report := make(DeleteReport, 0, len(items))
+	sizes, err := c.measure(ctx, items)
+	if err != nil {
+		return report, err
+	}
+
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		file := item.path
+		dedupe := item.keep != "" && c.Dedupe != DedupeOff
+		if dedupe {
+			progress("Replacing: " + utils.ShortenPath(file, 40))
+		} else {
+			progress("Deleting: " + utils.ShortenPath(file, 40))
+		}
+		result := FileResult{Path: file}
+		if err := c.checkProtected(file); err != nil {
+			result.Status, result.Err = StatusSkipped, err
+			report = append(report, result)
+			continue
+		}
+
+		// Stat before deletion
+		info, err := os.Lstat(file)
 		if err != nil {
-			continue // File might already be gone
+			result.Status = statusOf(err)
+			if result.Status != StatusVanished {
+				result.Err = err
+			}
+			report = append(report, result)
+			continue
 		}
-		size := info.Size()
+		result.Size = sizes[i]
 
 		// Determine if sudo is needed (outside home directory)
 		needsSudo := !strings.HasPrefix(file, os.Getenv("HOME"))
 
+		if c.dryRun {
+			// Mirror the fallback below: sudo is only used when a plain remove fails
+			c.plan(models.PlannedDeletion{
+				Path:   file,
+				Size:   result.Size,
+				Reason: item.reason,
+				Sudo:   !dedupe && needsSudo && !utils.CanRemove(file),
+			})
+			result.Status = StatusDeleted
+			if dedupe {
+				result.Status = StatusReplaced
+			}
+			report = append(report, result)
+			continue
+		}
+
 		var deleteErr error
-		if needsSudo {
+		if dedupe {
+			var freed bool
+			if freed, deleteErr = c.dedupe(file, item.keep, item.hash, info); deleteErr == nil {
+				result.Status = StatusReplaced
+				if !freed {
+					result.Size = 0
+				}
+				report = append(report, result)
+				continue
+			}
+		} else if c.Trash != nil {
+			_, deleteErr = c.Trash.Move(file, item.reason, needsSudo && !utils.CanRemove(file))
+		} else if needsSudo {
 			// Try without sudo first (in case we have permissions)
-			deleteErr = os.Remove(file)
+			deleteErr = os.RemoveAll(file)
 			if deleteErr != nil {
 				// Then try with sudo
-				deleteErr = c.SudoManager.Run("rm", "-f", file)
+				if err := c.SudoManager.Run("rm", "-rf", file); err != nil {
+					deleteErr = fmt.Errorf("%w (sudo rm: %w)", deleteErr, err)
+				} else {
+					deleteErr = nil
+				}
 			}
 		} else {
-			deleteErr = os.Remove(file)
+			deleteErr = os.RemoveAll(file)
 		}
 
-		if deleteErr == nil {
-			totalDeleted += size
+		result.Status = StatusDeleted
+		if deleteErr != nil {
+			result.Status, result.Err = statusOf(deleteErr), deleteErr
 		}
+		report = append(report, result)
 	}
 
-	return totalDeleted, nil
+	return report, nil
+}
+
+// measure returns the size of each of items, counted like
+// calculateActualSize so freed totals match the scans. Sizes are taken
+// before anything is deleted, so links to one file count once however many
+// of them are removed.
+func (c *Cleaner) measure(ctx context.Context, items []deletion) ([]int64, error) {
+	counter := utils.NewSizeCounter(c.SizeMode)
+	sizes := make([]int64, len(items))
+	for i, item := range items {
+		if c.checkProtected(item.path) != nil {
+			continue
+		}
+		info, err := os.Lstat(item.path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			sizes[i] = counter.Add(info)
+			continue
+		}
+		if sizes[i], err = utils.DirUsageContext(ctx, item.path, 0, counter); err != nil {
+			return nil, err
+		}
+	}
+	return sizes, nil
 }
 
 // DeleteBigFiles deletes selected big files
-func (c *Cleaner) DeleteBigFiles(files []models.BigFile, selected map[int]bool, progress func(string)) int64 {
-	var paths []string
-	for i, sel := range selected {
-		if sel && i < len(files) {
-			paths = append(paths, files[i].Path)
+func (c *Cleaner) DeleteBigFiles(files []models.BigFile, selected map[int]bool, progress func(string)) DeleteReport {
+	report, _ := c.DeleteBigFilesContext(context.Background(), files, selected, progress)
+	return report
+}
+
+// DeleteBigFilesContext is DeleteBigFiles that stops once ctx is done, returning
+// the results so far together with ctx.Err()
+func (c *Cleaner) DeleteBigFilesContext(ctx context.Context, files []models.BigFile, selected map[int]bool, progress func(string)) (DeleteReport, error) {
+	var items []deletion
+	for i := range files {
+		if selected[i] {
+			items = append(items, deletion{
+				path:   files[i].Path,
+				reason: "large file (" + utils.FormatBytes(files[i].Size) + ")",
+			})
 		}
 	}
 
-	deleted, _ := c.DeleteFiles(paths, progress)
-	return deleted
+	return c.deleteFiles(ctx, items, progress)
+}
+
+// DeleteDuplicates deletes the removed copies of the selected duplicate
+// groups, keeping the copies marked in each group's Keep. With Dedupe set the
+// removed copies are replaced with clones of or links to the first kept copy
+// instead, and groups of similar images, whose contents differ, and of
+// directories are skipped.
+func (c *Cleaner) DeleteDuplicates(groups []models.DuplicateGroup, selected map[int]bool, progress func(string)) DeleteReport {
+	report, _ := c.DeleteDuplicatesContext(context.Background(), groups, selected, progress)
+	return report
 }
 
-// DeleteDuplicates deletes selected duplicate files (keeping one copy)
-func (c *Cleaner) DeleteDuplicates(groups []models.DuplicateGroup, selected map[int]bool, progress func(string)) int64 {
-	var filesToDelete []string
+// DeleteDuplicatesContext is DeleteDuplicates that stops once ctx is done, returning
+// the results so far together with ctx.Err()
+func (c *Cleaner) DeleteDuplicatesContext(ctx context.Context, groups []models.DuplicateGroup, selected map[int]bool, progress func(string)) (DeleteReport, error) {
+	var items []deletion
 
-	for i, sel := range selected {
-		if !sel || i >= len(groups) {
+	for i, group := range groups {
+		if !selected[i] || (c.Dedupe != DedupeOff && group.Kind != models.GroupIdentical) {
 			continue
 		}
 
-		group := groups[i]
-		// Keep the first file, delete the rest
-		for j := 1; j < len(group.Files); j++ {
-			filesToDelete = append(filesToDelete, group.Files[j])
+		// Removed is empty unless some copy stays
+		for _, file := range group.Removed() {
+			keep := group.Kept()[0]
+			item := deletion{path: file, reason: "duplicate of " + keep}
+			if c.Dedupe != DedupeOff {
+				item.reason = "replace with " + c.Dedupe.String() + " of " + keep
+				item.keep, item.hash = keep, group.Hash
+			}
+			items = append(items, item)
 		}
 	}
 
-	deleted, _ := c.DeleteFiles(filesToDelete, progress)
-	return deleted
+	return c.deleteFiles(ctx, items, progress)
 }
 
 // DeleteOldFiles deletes selected old files
-func (c *Cleaner) DeleteOldFiles(files []models.OldFile, selected map[int]bool, progress func(string)) int64 {
-	var paths []string
-	for i, sel := range selected {
-		if sel && i < len(files) {
-			paths = append(paths, files[i].Path)
+func (c *Cleaner) DeleteOldFiles(files []models.OldFile, selected map[int]bool, progress func(string)) DeleteReport {
+	report, _ := c.DeleteOldFilesContext(context.Background(), files, selected, progress)
+	return report
+}
+
+// DeleteOldFilesContext is DeleteOldFiles that stops once ctx is done, returning
+// the results so far together with ctx.Err()
+func (c *Cleaner) DeleteOldFilesContext(ctx context.Context, files []models.OldFile, selected map[int]bool, progress func(string)) (DeleteReport, error) {
+	var items []deletion
+	for i := range files {
+		if selected[i] {
+			items = append(items, deletion{
+				path:   files[i].Path,
+				reason: "last used " + files[i].LastAccess.Format(time.DateOnly),
+			})
 		}
 	}
 
-	deleted, _ := c.DeleteFiles(paths, progress)
-	return deleted
+	return c.deleteFiles(ctx, items, progress)
 }
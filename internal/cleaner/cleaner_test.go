@@ -1,11 +1,14 @@
 package cleaner
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/quarantine"
 	"macos-cleaner/internal/utils"
 )
 
@@ -34,7 +37,7 @@ func TestCleanTarget(t *testing.T) {
 		Selected: true,
 	}
 
-	result := cleaner.cleanTarget(target)
+	result := cleaner.cleanTarget(context.Background(), target)
 
 	if result.Error != nil {
 		t.Errorf("cleanTarget() error = %v", result.Error)
@@ -79,7 +82,7 @@ func TestCleanTarget_Directory(t *testing.T) {
 		Selected: true,
 	}
 
-	result := cleaner.cleanTarget(target)
+	result := cleaner.cleanTarget(context.Background(), target)
 
 	if result.Error != nil {
 		t.Errorf("cleanTarget() error = %v", result.Error)
@@ -208,19 +211,18 @@ func TestDeleteFiles(t *testing.T) {
 	cleaner := New(sudoMgr)
 
 	progressCalled := false
-	deleted, err := cleaner.DeleteFiles(files, func(status string) {
+	report := cleaner.DeleteFiles(files, func(status string) {
 		progressCalled = true
 	})
 
-	if err != nil {
-		t.Errorf("DeleteFiles() error = %v", err)
-	}
-
 	if !progressCalled {
 		t.Error("Progress callback was not called")
 	}
 
-	if deleted != 300 {
+	if report.Count(StatusDeleted) != 2 || len(report.Failures()) != 0 {
+		t.Errorf("DeleteFiles() = %s, want 2 deleted", report.Summary())
+	}
+	if deleted := report.Freed(); deleted != 300 {
 		t.Errorf("DeleteFiles() deleted = %d, want 300", deleted)
 	}
 
@@ -261,7 +263,7 @@ func TestDeleteBigFiles(t *testing.T) {
 	sudoMgr := utils.NewSudoManager()
 	cleaner := New(sudoMgr)
 
-	deleted := cleaner.DeleteBigFiles(files, selected, func(status string) {})
+	deleted := cleaner.DeleteBigFiles(files, selected, func(status string) {}).Freed()
 
 	if deleted != 1000 {
 		t.Errorf("DeleteBigFiles() deleted = %d, want 1000", deleted)
@@ -309,7 +311,7 @@ func TestDeleteDuplicates(t *testing.T) {
 	sudoMgr := utils.NewSudoManager()
 	cleaner := New(sudoMgr)
 
-	deleted := cleaner.DeleteDuplicates(groups, selected, func(status string) {})
+	deleted := cleaner.DeleteDuplicates(groups, selected, func(status string) {}).Freed()
 
 	// Should delete 2 files (file2 and file3), keep file1
 	expectedDeleted := int64(len(content)) * 2
@@ -357,7 +359,7 @@ func TestDeleteOldFiles(t *testing.T) {
 	sudoMgr := utils.NewSudoManager()
 	cleaner := New(sudoMgr)
 
-	deleted := cleaner.DeleteOldFiles(files, selected, func(status string) {})
+	deleted := cleaner.DeleteOldFiles(files, selected, func(status string) {}).Freed()
 
 	if deleted != 100 {
 		t.Errorf("DeleteOldFiles() deleted = %d, want 100", deleted)
@@ -408,3 +410,339 @@ func TestCalculateActualSize(t *testing.T) {
 		t.Errorf("calculateActualSize() = %d, want 0 for non-existing", size)
 	}
 }
+
+func TestCleanTargets_DryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cleaner_dryrun_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	os.Mkdir(cacheDir, 0755)
+	file1 := filepath.Join(cacheDir, "a.bin")
+	subDir := filepath.Join(cacheDir, "sub")
+	os.WriteFile(file1, make([]byte, 100), 0644)
+	os.Mkdir(subDir, 0755)
+	os.WriteFile(filepath.Join(subDir, "b.bin"), make([]byte, 250), 0644)
+
+	targets := []models.CleanupTarget{
+		{Name: "Test Cache", Path: cacheDir + "/*", Size: 350, Selected: true},
+		{Name: "Brew", IsCommand: true, Command: "brew cleanup", Size: 42, Selected: true},
+	}
+
+	var plan []models.PlannedDeletion
+	dry := New(utils.NewSudoManager()).WithDryRun(func(p models.PlannedDeletion) {
+		plan = append(plan, p)
+	})
+
+	results, totalSaved := dry.CleanTargets(targets, func(string) {})
+
+	if len(results) != 2 {
+		t.Fatalf("CleanTargets() returned %d results, want 2", len(results))
+	}
+	if totalSaved != 392 {
+		t.Errorf("CleanTargets() totalSaved = %d, want 392", totalSaved)
+	}
+	if targets[0].Size != 350 {
+		t.Error("Dry run should not reset target sizes")
+	}
+
+	sizes := make(map[string]int64)
+	for _, p := range plan {
+		if p.Reason == "" {
+			t.Errorf("planned deletion %q has no reason", p.Path)
+		}
+		sizes[p.Path+p.Command] = p.Size
+	}
+	if sizes[file1] != 100 || sizes[subDir] != 250 || sizes["brew cleanup"] != 42 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+
+	// Nothing may be touched
+	if _, err := os.Stat(file1); err != nil {
+		t.Error("file1 should not have been deleted")
+	}
+	if _, err := os.Stat(subDir); err != nil {
+		t.Error("subDir should not have been deleted")
+	}
+}
+
+func TestDeleteDuplicates_DryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "delete_dup_dryrun_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file1 := filepath.Join(tmpDir, "file1.txt")
+	file2 := filepath.Join(tmpDir, "file2.txt")
+	content := []byte("duplicate content")
+	os.WriteFile(file1, content, 0644)
+	os.WriteFile(file2, content, 0644)
+
+	groups := []models.DuplicateGroup{
+		{Hash: "abc123", Size: int64(len(content)), Files: []string{file1, file2}},
+	}
+
+	var plan []models.PlannedDeletion
+	dry := New(utils.NewSudoManager()).WithDryRun(func(p models.PlannedDeletion) {
+		plan = append(plan, p)
+	})
+
+	deleted := dry.DeleteDuplicates(groups, map[int]bool{0: true}, func(string) {}).Freed()
+
+	if deleted != int64(len(content)) {
+		t.Errorf("DeleteDuplicates() deleted = %d, want %d", deleted, len(content))
+	}
+	if len(plan) != 1 || plan[0].Path != file2 || plan[0].Reason != "duplicate of "+file1 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if _, err := os.Stat(file2); err != nil {
+		t.Error("file2 should not have been deleted")
+	}
+}
+
+func TestDeleteFiles_Trash(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "delete_trash_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file1 := filepath.Join(tmpDir, "file1.txt")
+	os.WriteFile(file1, make([]byte, 100), 0644)
+
+	sudoMgr := utils.NewSudoManager()
+	cleaner := New(sudoMgr)
+	cleaner.Trash = quarantine.New(filepath.Join(tmpDir, "quarantine"), sudoMgr)
+
+	if deleted := cleaner.DeleteFiles([]string{file1}, func(string) {}).Freed(); deleted != 100 {
+		t.Errorf("DeleteFiles() deleted = %d, want 100", deleted)
+	}
+	if _, err := os.Stat(file1); !os.IsNotExist(err) {
+		t.Error("file1 should have been moved to quarantine")
+	}
+
+	entries, err := cleaner.Trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].OriginalPath != file1 || entries[0].Operation != "selected file" {
+		t.Fatalf("unexpected manifest: %+v", entries)
+	}
+	if _, err := cleaner.Trash.Restore(entries[0].ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err := os.Stat(file1); err != nil {
+		t.Error("file1 should be restorable")
+	}
+}
+
+func TestCleanTargetsContext_Cancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "file1.txt")
+	file2 := filepath.Join(tmpDir, "file2.txt")
+	os.WriteFile(file1, make([]byte, 100), 0644)
+	os.WriteFile(file2, make([]byte, 200), 0644)
+
+	targets := []models.CleanupTarget{
+		{Name: "File 1", Path: file1, Size: 100, Selected: true},
+		{Name: "File 2", Path: file2, Size: 200, Selected: true},
+	}
+
+	// Cancel as soon as the first target starts
+	ctx, cancel := context.WithCancel(context.Background())
+	results, totalSaved, err := New(utils.NewSudoManager()).CleanTargetsContext(ctx, targets, func(string) {
+		cancel()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("CleanTargetsContext() error = %v, want context.Canceled", err)
+	}
+	if len(results) != 1 || totalSaved != 100 {
+		t.Errorf("CleanTargetsContext() = %d results, %d saved; want the first target only", len(results), totalSaved)
+	}
+	if _, err := os.Stat(file2); err != nil {
+		t.Error("file2 should not have been deleted after cancellation")
+	}
+}
+
+func TestDeleteFilesContext_Cancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(file, make([]byte, 100), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := New(utils.NewSudoManager()).DeleteFilesContext(ctx, []string{file}, func(string) {})
+	if !errors.Is(err, context.Canceled) || len(report) != 0 {
+		t.Errorf("DeleteFilesContext() = %d results, %v; want none, context.Canceled", len(report), err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error("file should not have been deleted")
+	}
+}
+
+func TestDeleteFiles_Directory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	if err := os.MkdirAll(filepath.Join(dir, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.bin"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(dir, "build", "b.bin"), make([]byte, 50), 0644)
+
+	cleaner := New(utils.NewSudoManager())
+	if deleted := cleaner.DeleteFiles([]string{dir}, func(string) {}).Freed(); deleted != 150 {
+		t.Errorf("DeleteFiles() deleted = %d, want 150", deleted)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("directory should have been deleted")
+	}
+}
+
+func TestDeleteFiles_HardLinks(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.bin")
+	link := filepath.Join(dir, "b.bin")
+	os.WriteFile(file, make([]byte, 100), 0644)
+	if err := os.Link(file, link); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	cleaner := New(utils.NewSudoManager())
+	if deleted := cleaner.DeleteFiles([]string{file, link}, func(string) {}).Freed(); deleted != 100 {
+		t.Errorf("DeleteFiles() deleted = %d, want 100 for two links to one file", deleted)
+	}
+}
+
+func TestCleanTarget_RefusesOutsideBase(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(root, "outside")
+	base := filepath.Join(root, "Containers")
+	os.MkdirAll(filepath.Join(outside, "Caches"), 0755)
+	os.MkdirAll(filepath.Join(base, "good", "Data", "Caches"), 0755)
+	os.MkdirAll(filepath.Join(base, "evil"), 0755)
+	precious := filepath.Join(outside, "Caches", "precious")
+	cache := filepath.Join(base, "good", "Data", "Caches", "cache.db")
+	os.WriteFile(precious, make([]byte, 10), 0644)
+	os.WriteFile(cache, make([]byte, 10), 0644)
+	if err := os.Symlink(outside, filepath.Join(base, "evil", "Data")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cleaner := New(utils.NewSudoManager())
+	result := cleaner.cleanTarget(context.Background(), &models.CleanupTarget{
+		Name: "Container Caches",
+		Path: filepath.Join(base, "*", "Data", "Caches", "*"),
+	})
+
+	if result.Error != nil {
+		t.Errorf("cleanTarget() error = %v", result.Error)
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Error("cache inside the base should have been deleted")
+	}
+	if _, err := os.Stat(precious); err != nil {
+		t.Errorf("file outside the base was deleted: %v", err)
+	}
+	want := filepath.Join(base, "evil", "Data", "Caches", "precious")
+	if len(result.Refused) != 1 || result.Refused[0] != want {
+		t.Errorf("Refused = %v, want [%s]", result.Refused, want)
+	}
+}
+
+func TestDeleteFiles_Statuses(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "file.txt")
+	gone := filepath.Join(tmpDir, "gone.txt")
+	os.WriteFile(file, make([]byte, 100), 0644)
+
+	locked := filepath.Join(tmpDir, "locked")
+	stuck := filepath.Join(locked, "stuck.txt")
+	os.Mkdir(locked, 0755)
+	os.WriteFile(stuck, make([]byte, 10), 0644)
+	os.Chmod(locked, 0555)
+	defer os.Chmod(locked, 0755)
+
+	// Keep every path inside HOME so no sudo fallback is attempted
+	t.Setenv("HOME", filepath.Dir(tmpDir))
+	report := New(utils.NewSudoManager()).DeleteFiles([]string{file, gone, stuck}, func(string) {})
+
+	want := []DeleteStatus{StatusDeleted, StatusVanished, StatusPermissionDenied}
+	if os.Geteuid() == 0 {
+		want[2] = StatusDeleted // root ignores the directory's permissions
+	}
+	if len(report) != len(want) {
+		t.Fatalf("DeleteFiles() returned %d results, want %d", len(report), len(want))
+	}
+	for i, status := range want {
+		if report[i].Status != status {
+			t.Errorf("%s: status = %s (%v), want %s", filepath.Base(report[i].Path), report[i].Status, report[i].Err, status)
+		}
+	}
+	if report[1].Err != nil {
+		t.Errorf("vanished file has error %v", report[1].Err)
+	}
+	if report.Freed() != 100 && os.Geteuid() != 0 {
+		t.Errorf("Freed() = %d, want 100", report.Freed())
+	}
+	if os.Geteuid() != 0 {
+		if got, want := report.Summary(), "1 deleted, 1 permission denied, 1 vanished"; got != want {
+			t.Errorf("Summary() = %q, want %q", got, want)
+		}
+		if failures := report.Failures(); len(failures) != 1 || failures[0].Path != stuck {
+			t.Errorf("Failures() = %+v, want only %s", failures, stuck)
+		}
+	}
+}
+
+func TestDeleteDuplicates_Keep(t *testing.T) {
+	tmpDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, []byte("same"), 0644)
+		files = append(files, path)
+	}
+	groups := []models.DuplicateGroup{
+		{Size: 4, Files: files[:2], Keep: []bool{false, true}},
+		{Size: 4, Files: files[2:], Keep: []bool{false}}, // Nothing kept, so nothing deleted
+	}
+
+	report := New(utils.NewSudoManager()).DeleteDuplicates(groups, map[int]bool{0: true, 1: true}, func(string) {})
+
+	if len(report) != 1 || report[0].Path != files[0] {
+		t.Fatalf("DeleteDuplicates() = %+v, want only %s", report, files[0])
+	}
+	for _, kept := range files[1:] {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s should have been kept", filepath.Base(kept))
+		}
+	}
+}
+
+func TestDeleteDuplicates_Directories(t *testing.T) {
+	tmpDir := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"photos", "photos copy"} {
+		dir := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Join(dir, "2024"), 0755)
+		os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("aaaa"), 0644)
+		os.WriteFile(filepath.Join(dir, "2024", "b.jpg"), []byte("bbbbbb"), 0644)
+		dirs = append(dirs, dir)
+	}
+	groups := []models.DuplicateGroup{{Kind: models.GroupDirectory, Size: 10, Files: dirs}}
+
+	report := New(utils.NewSudoManager()).DeleteDuplicates(groups, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 || report[0].Status != StatusDeleted || report.Freed() != 10 {
+		t.Fatalf("DeleteDuplicates() = %+v, want the copy deleted freeing 10", report)
+	}
+	if _, err := os.Stat(dirs[1]); !os.IsNotExist(err) {
+		t.Errorf("duplicate tree still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "2024", "b.jpg")); err != nil {
+		t.Errorf("kept tree was touched: %v", err)
+	}
+}
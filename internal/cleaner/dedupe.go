@@ -0,0 +1,106 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"macos-cleaner/internal/utils"
+)
+
+// DedupeMode says how DeleteDuplicates frees the space of removed copies
+type DedupeMode int
+
+const (
+	DedupeOff      DedupeMode = iota // Delete them, or move them to quarantine
+	DedupeAuto                       // Clone where the volume supports it, otherwise hard link
+	DedupeClone                      // Replace them with copy-on-write clones of the kept copy
+	DedupeHardLink                   // Replace them with hard links to the kept copy
+)
+
+// ParseDedupeMode parses "off", "auto", "clone" or "hardlink"
+func ParseDedupeMode(s string) (DedupeMode, error) {
+	switch s {
+	case "off":
+		return DedupeOff, nil
+	case "auto":
+		return DedupeAuto, nil
+	case "clone":
+		return DedupeClone, nil
+	case "hardlink":
+		return DedupeHardLink, nil
+	}
+	return DedupeOff, fmt.Errorf("unknown dedupe mode %q (use auto, clone, hardlink or off)", s)
+}
+
+// String describes what a removed copy is replaced with
+func (m DedupeMode) String() string {
+	switch m {
+	case DedupeAuto:
+		return "clone or hard link"
+	case DedupeClone:
+		return "clone"
+	case DedupeHardLink:
+		return "hard link"
+	}
+	return "off"
+}
+
+// errContentChanged is returned when a duplicate no longer matches its kept copy
+var errContentChanged = errors.New("content changed since the scan")
+
+// dedupe replaces path with a clone of or a hard link to keep. Clones get
+// path's permissions, owner and timestamps; a hard link shares keep's, so it
+// is only made when the owner and permissions already match. The new file is
+// created next to path and renamed over it, so path is left untouched on any
+// failure. It returns false when path already is keep, which frees nothing.
+func (c *Cleaner) dedupe(path, keep, hash string, info os.FileInfo) (bool, error) {
+	keepInfo, err := os.Lstat(keep)
+	if err != nil {
+		// Not wrapped: the duplicate itself is still there
+		return false, fmt.Errorf("kept copy: %v", err)
+	}
+	if !info.Mode().IsRegular() || !keepInfo.Mode().IsRegular() {
+		return false, fmt.Errorf("only regular files can be replaced")
+	}
+	if os.SameFile(info, keepInfo) {
+		return false, nil
+	}
+
+	// Never link files that stopped being identical after the scan
+	sum := utils.FileHash(keep)
+	if sum == "" || sum != utils.FileHash(path) || (hash != "" && sum != hash) {
+		return false, errContentChanged
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".macleaner-dedupe")
+	cloned := false
+	if c.Dedupe != DedupeHardLink {
+		err := utils.CloneFile(keep, tmp)
+		switch {
+		case err == nil:
+			if err := utils.CopyMetadata(tmp, info); err != nil {
+				os.Remove(tmp)
+				return false, fmt.Errorf("copy metadata: %w", err)
+			}
+			cloned = true
+		case c.Dedupe == DedupeClone || !errors.Is(err, utils.ErrCloneUnsupported):
+			return false, err
+		}
+	}
+	if !cloned {
+		if !utils.SameOwnerAndMode(info, keepInfo) {
+			return false, fmt.Errorf("cannot hard link: owner or permissions differ from %s", keep)
+		}
+		if err := os.Link(keep, tmp); err != nil {
+			return false, fmt.Errorf("hard link: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,151 @@
+package cleaner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/utils"
+)
+
+// duplicatePair writes two identical files and returns them as a group that
+// keeps the first
+func duplicatePair(t *testing.T) (models.DuplicateGroup, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep.bin")
+	dup := filepath.Join(dir, "dup.bin")
+	content := []byte("identical content")
+	os.WriteFile(keep, content, 0644)
+	os.WriteFile(dup, content, 0644)
+	group := models.DuplicateGroup{
+		Hash:  utils.FileHash(keep),
+		Size:  int64(len(content)),
+		Files: []string{keep, dup},
+		Keep:  []bool{true, false},
+	}
+	return group, keep, dup
+}
+
+func TestDeleteDuplicates_HardLink(t *testing.T) {
+	group, keep, dup := duplicatePair(t)
+	c := New(utils.NewSudoManager())
+	c.Dedupe = DedupeHardLink
+
+	report := c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 || report[0].Status != StatusReplaced || report.Freed() != group.Size {
+		t.Fatalf("DeleteDuplicates() = %+v, want replaced freeing %d", report, group.Size)
+	}
+	a, _ := os.Stat(keep)
+	b, err := os.Stat(dup)
+	if err != nil || !os.SameFile(a, b) {
+		t.Errorf("duplicate is not a hard link to the kept copy (%v)", err)
+	}
+
+	// Running again finds the files already linked and frees nothing
+	report = c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 || report[0].Status != StatusReplaced || report.Freed() != 0 {
+		t.Errorf("second DeleteDuplicates() = %+v, want replaced freeing 0", report)
+	}
+}
+
+func TestDeleteDuplicates_Clone(t *testing.T) {
+	group, keep, dup := duplicatePair(t)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chmod(dup, 0600)
+	os.Chtimes(dup, past, past)
+
+	c := New(utils.NewSudoManager())
+	c.Dedupe = DedupeClone
+	report := c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 {
+		t.Fatalf("DeleteDuplicates() returned %d results, want 1", len(report))
+	}
+
+	info, err := os.Stat(dup)
+	if err != nil {
+		t.Fatalf("duplicate is gone: %v", err)
+	}
+	if report[0].Status != StatusReplaced {
+		// Not every volume can clone; the duplicate must then be left alone
+		if !errors.Is(report[0].Err, utils.ErrCloneUnsupported) {
+			t.Errorf("clone failed with %v", report[0].Err)
+		}
+		t.Skip("volume does not support clones")
+	}
+	keepInfo, _ := os.Stat(keep)
+	if os.SameFile(info, keepInfo) {
+		t.Error("clone is a hard link")
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(past) {
+		t.Errorf("clone has mode %v, mtime %v; want the duplicate's 0600, %v", info.Mode().Perm(), info.ModTime(), past)
+	}
+}
+
+func TestDeleteDuplicates_DedupeRefusals(t *testing.T) {
+	// Content changed after the scan
+	group, _, dup := duplicatePair(t)
+	os.WriteFile(dup, []byte("edited content!!!"), 0644)
+
+	c := New(utils.NewSudoManager())
+	c.Dedupe = DedupeAuto
+	report := c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 || !errors.Is(report[0].Err, errContentChanged) {
+		t.Errorf("changed duplicate: %+v, want errContentChanged", report)
+	}
+	if data, _ := os.ReadFile(dup); string(data) != "edited content!!!" {
+		t.Error("changed duplicate was overwritten")
+	}
+
+	// A hard link would change the duplicate's permissions
+	group, _, dup = duplicatePair(t)
+	os.Chmod(dup, 0600)
+	c.Dedupe = DedupeHardLink
+	report = c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 1 || report[0].Status != StatusFailed {
+		t.Errorf("mode mismatch: %+v, want failed", report)
+	}
+	if info, err := os.Stat(dup); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("duplicate changed: %v, %v", info, err)
+	}
+}
+
+func TestDeleteDuplicates_DedupeDryRun(t *testing.T) {
+	group, keep, dup := duplicatePair(t)
+	var plan []models.PlannedDeletion
+	c := New(utils.NewSudoManager())
+	c.Dedupe = DedupeHardLink
+	report := c.WithDryRun(func(p models.PlannedDeletion) {
+		plan = append(plan, p)
+	}).DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+
+	if len(plan) != 1 || plan[0].Path != dup || plan[0].Reason != "replace with hard link of "+keep {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if len(report) != 1 || report[0].Status != StatusReplaced {
+		t.Errorf("dry run report = %+v", report)
+	}
+	a, _ := os.Stat(keep)
+	b, _ := os.Stat(dup)
+	if os.SameFile(a, b) {
+		t.Error("dry run linked the duplicate")
+	}
+}
+
+func TestDeleteDuplicates_SimilarNotLinked(t *testing.T) {
+	group, _, dup := duplicatePair(t)
+	group.Kind = models.GroupSimilar
+	c := New(utils.NewSudoManager())
+	c.Dedupe = DedupeAuto
+
+	report := c.DeleteDuplicates([]models.DuplicateGroup{group}, map[int]bool{0: true}, func(string) {})
+	if len(report) != 0 {
+		t.Errorf("DeleteDuplicates() = %+v, want similar images skipped", report)
+	}
+	if _, err := os.Stat(dup); err != nil {
+		t.Errorf("similar image was changed: %v", err)
+	}
+}
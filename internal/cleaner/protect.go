@@ -0,0 +1,134 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"macos-cleaner/internal/utils"
+)
+
+// ErrProtected is returned for paths the cleaner refuses to delete
+var ErrProtected = errors.New("protected path")
+
+// protection says how much around a protected path is off limits. Every
+// kind also covers the path's ancestors, since deleting one would take the
+// path with it.
+type protection int
+
+const (
+	protectSelf     protection = iota // The path itself
+	protectChildren                   // The path and the entries directly inside it
+	protectSubtree                    // The path and everything below it
+)
+
+// protectedPath is one entry of the denylist; Path may start with ~
+type protectedPath struct {
+	Path string
+	Kind protection
+}
+
+// builtinProtected is always enforced; configuration can only add to it
+var builtinProtected = []protectedPath{
+	// Nothing inside these is ever cleaned
+	{"/System", protectSubtree},
+	{"/bin", protectSubtree},
+	{"/sbin", protectSubtree},
+	{"/usr/bin", protectSubtree},
+	{"/usr/sbin", protectSubtree},
+	{"/usr/lib", protectSubtree},
+	{"/usr/libexec", protectSubtree},
+	{"/usr/share", protectSubtree},
+	{"/etc", protectSubtree},
+	{"/private/etc", protectSubtree},
+	{"~/.ssh", protectSubtree},
+	{"~/.gnupg", protectSubtree},
+	{"~/Library/Keychains", protectSubtree},
+	{"~/Library/Mobile Documents", protectSubtree},
+
+	// Containers whose entries are never removed wholesale
+	{"/", protectChildren},
+	{"~", protectChildren},
+	{"/Users", protectChildren},
+	{"/Applications", protectChildren},
+	{"/Library", protectChildren},
+	{"/Volumes", protectChildren},
+	{"/private", protectChildren},
+	{"/private/var", protectChildren},
+	{"/var", protectChildren},
+	{"/usr", protectChildren},
+	{"/opt", protectChildren},
+	{"~/Library", protectChildren},
+	{"~/Library/Containers", protectChildren},
+	{"~/Library/Application Support", protectChildren},
+
+	// Directories whose contents may be cleaned but which must stay
+	{"~/Documents", protectSelf},
+	{"~/Desktop", protectSelf},
+	{"~/Downloads", protectSelf},
+	{"~/Pictures", protectSelf},
+	{"~/Movies", protectSelf},
+	{"~/Music", protectSelf},
+	{"~/.Trash", protectSelf},
+	{"~/Library/Caches", protectSelf},
+	{"~/Library/Logs", protectSelf},
+	{"/Library/Caches", protectSelf},
+	{"/Library/Logs", protectSelf},
+	{"/tmp", protectSelf},
+	{"/private/tmp", protectSelf},
+	{"/private/var/tmp", protectSelf},
+	{"/var/log", protectSelf},
+	{"/private/var/log", protectSelf},
+}
+
+// checkProtected returns an error wrapping ErrProtected when path must not
+// be deleted under the built-in list or the user's Protected paths. Paths
+// are compared as given and with symlinks in their parent resolved.
+func (c *Cleaner) checkProtected(path string) error {
+	if path == "" || !filepath.IsAbs(path) {
+		return fmt.Errorf("refusing to delete %q: not an absolute path: %w", path, ErrProtected)
+	}
+
+	candidates := []string{filepath.Clean(path)}
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		candidates = append(candidates, filepath.Join(parent, filepath.Base(path)))
+	}
+
+	rules := builtinProtected
+	for _, p := range c.Protected {
+		rules = append(rules[:len(rules):len(rules)], protectedPath{p, protectSubtree})
+	}
+
+	for _, rule := range rules {
+		root := filepath.Clean(utils.ExpandPath(rule.Path))
+		for _, candidate := range candidates {
+			if protects(rule.Kind, root, candidate) {
+				return fmt.Errorf("refusing to delete %s: protects %s: %w", candidate, rule.Path, ErrProtected)
+			}
+		}
+	}
+	return nil
+}
+
+// protects reports whether a rule of kind on root covers path
+func protects(kind protection, root, path string) bool {
+	if path == root || isBelow(root, path) {
+		return true
+	}
+	switch kind {
+	case protectChildren:
+		return filepath.Dir(path) == root
+	case protectSubtree:
+		return isBelow(path, root)
+	}
+	return false
+}
+
+// isBelow reports whether path lies strictly inside dir
+func isBelow(path, dir string) bool {
+	if dir == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
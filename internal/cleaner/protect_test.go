@@ -0,0 +1,122 @@
+package cleaner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/utils"
+)
+
+func TestCheckProtected(t *testing.T) {
+	c := New(utils.NewSudoManager())
+	home, _ := os.UserHomeDir()
+
+	refused := []string{
+		"/",
+		home,
+		filepath.Dir(home),
+		"/System",
+		"/System/Library/CoreServices",
+		"/usr/bin/env",
+		"/etc/hosts",
+		"/Applications/Safari.app",
+		"/Library/Preferences",
+		"/Library/Caches",
+		filepath.Join(home, "Documents"),
+		filepath.Join(home, "Library"),
+		filepath.Join(home, "Library", "Preferences"),
+		filepath.Join(home, "Library", "Caches"),
+		filepath.Join(home, ".ssh", "id_ed25519"),
+		filepath.Join(home, "Library", "Keychains", "login.keychain-db"),
+		filepath.Join(home, "Projects"),
+		"",
+		"relative/path",
+	}
+	for _, path := range refused {
+		if err := c.checkProtected(path); !errors.Is(err, ErrProtected) {
+			t.Errorf("checkProtected(%q) = %v, want ErrProtected", path, err)
+		}
+	}
+
+	allowed := []string{
+		filepath.Join(home, "Library", "Caches", "com.example.app"),
+		filepath.Join(home, "Library", "Logs", "app.log"),
+		filepath.Join(home, "Downloads", "installer.dmg"),
+		filepath.Join(home, "Documents", "old-report.pdf"),
+		filepath.Join(home, ".Trash", "file.txt"),
+		filepath.Join(home, ".gradle", "caches"),
+		"/Library/Caches/com.example.daemon",
+		"/private/var/folders/xy/abc123/T/tmp.1",
+	}
+	for _, path := range allowed {
+		if err := c.checkProtected(path); err != nil {
+			t.Errorf("checkProtected(%q) = %v, want nil", path, err)
+		}
+	}
+}
+
+func TestCheckProtected_UserPaths(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep")
+
+	c := New(utils.NewSudoManager())
+	c.Protected = []string{keep}
+
+	for _, path := range []string{keep, filepath.Join(keep, "a", "b.txt"), dir} {
+		if err := c.checkProtected(path); !errors.Is(err, ErrProtected) {
+			t.Errorf("checkProtected(%q) = %v, want ErrProtected", path, err)
+		}
+	}
+	if err := c.checkProtected(filepath.Join(dir, "other")); err != nil {
+		t.Errorf("checkProtected(other) = %v, want nil", err)
+	}
+}
+
+// Catastrophic targets run as dry runs, so a broken guard cannot delete anything
+func TestCleanTargets_CatastrophicPatterns(t *testing.T) {
+	patterns := []string{"/", "/*", "~", "~/*", "~/Documents", "~/Library/*", "/System/*", "/Users/*", "/Applications/*"}
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			var plan []models.PlannedDeletion
+			c := New(utils.NewSudoManager()).WithDryRun(func(p models.PlannedDeletion) {
+				plan = append(plan, p)
+			})
+			results, _ := c.CleanTargets([]models.CleanupTarget{
+				{Name: "Bad", Path: pattern, Category: "Test", Selected: true},
+			}, func(string) {})
+
+			if len(plan) != 0 {
+				t.Errorf("dry run of %q planned %d deletions, e.g. %s", pattern, len(plan), plan[0].Path)
+			}
+			if len(results) == 1 && results[0].Error == nil {
+				if matches, _ := filepath.Glob(utils.ExpandPath(pattern)); len(matches) > 0 {
+					t.Errorf("cleaning %q reported no error", pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteFiles_Protected(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep.txt")
+	other := filepath.Join(dir, "other.txt")
+	os.WriteFile(keep, make([]byte, 10), 0644)
+	os.WriteFile(other, make([]byte, 20), 0644)
+
+	c := New(utils.NewSudoManager())
+	c.Protected = []string{keep}
+	report := c.DeleteFiles([]string{keep, other}, func(string) {})
+	if report[0].Status != StatusSkipped || !errors.Is(report[0].Err, ErrProtected) {
+		t.Errorf("protected file result = %+v, want skipped", report[0])
+	}
+	if deleted := report.Freed(); deleted != 20 {
+		t.Errorf("DeleteFiles() deleted = %d, want 20", deleted)
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("protected file was deleted: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"macos-cleaner/internal/utils"
+)
+
+// DeleteStatus is the outcome of deleting one file or directory
+type DeleteStatus string
+
+const (
+	StatusDeleted          DeleteStatus = "deleted"           // Deleted, moved to quarantine, or would be in a dry run
+	StatusReplaced         DeleteStatus = "replaced"          // Replaced with a clone of or hard link to the kept copy
+	StatusSkipped          DeleteStatus = "skipped"           // Protected path, left alone
+	StatusPermissionDenied DeleteStatus = "permission_denied" // Not allowed to remove it
+	StatusVanished         DeleteStatus = "vanished"          // Already gone when its turn came
+	StatusSudoRefused      DeleteStatus = "sudo_refused"      // Needed administrator rights that were not granted
+	StatusFailed           DeleteStatus = "failed"            // Any other error
+)
+
+// Statuses lists every status in the order summaries show them
+var Statuses = []DeleteStatus{
+	StatusDeleted, StatusReplaced, StatusSkipped, StatusPermissionDenied, StatusVanished, StatusSudoRefused, StatusFailed,
+}
+
+// Label returns the status as shown to the user
+func (s DeleteStatus) Label() string {
+	return strings.ReplaceAll(string(s), "_", " ")
+}
+
+// FileResult is the outcome of deleting one selected path
+type FileResult struct {
+	Path   string
+	Size   int64 // Bytes freed when deleted or replaced, otherwise the size the path had
+	Status DeleteStatus
+	Err    error // Why the path was not deleted; nil when deleted or vanished
+}
+
+// DeleteReport holds one result per path a deletion got to, in order
+type DeleteReport []FileResult
+
+// Freed returns the bytes freed by the deleted and replaced paths
+func (r DeleteReport) Freed() int64 {
+	var total int64
+	for _, f := range r {
+		if f.Status == StatusDeleted || f.Status == StatusReplaced {
+			total += f.Size
+		}
+	}
+	return total
+}
+
+// Count returns how many paths ended with status
+func (r DeleteReport) Count(status DeleteStatus) int {
+	n := 0
+	for _, f := range r {
+		if f.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failures returns the paths that were neither deleted nor replaced and are
+// still there
+func (r DeleteReport) Failures() []FileResult {
+	var failed []FileResult
+	for _, f := range r {
+		if f.Status != StatusDeleted && f.Status != StatusReplaced && f.Status != StatusVanished {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// Summary counts the paths by status, e.g. "12 deleted, 1 permission denied"
+func (r DeleteReport) Summary() string {
+	var parts []string
+	for _, status := range Statuses {
+		if n := r.Count(status); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, status.Label()))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing to delete"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// statusOf classifies the error a deletion failed with
+func statusOf(err error) DeleteStatus {
+	switch {
+	case errors.Is(err, ErrProtected):
+		return StatusSkipped
+	case errors.Is(err, utils.ErrSudoRefused):
+		return StatusSudoRefused
+	case errors.Is(err, fs.ErrNotExist):
+		return StatusVanished
+	case errors.Is(err, fs.ErrPermission):
+		return StatusPermissionDenied
+	}
+	return StatusFailed
+}
@@ -0,0 +1,408 @@
+// Package config loads the user configuration file
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/imagehash"
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/scope"
+	"macos-cleaner/internal/utils"
+)
+
+// EnvPath is the environment variable overriding the config file location
+const EnvPath = "MACLEANER_CONFIG"
+
+// Config is the user configuration file
+type Config struct {
+	Targets    []TargetEntry    `json:"targets"`
+	Scan       *ScanEntry       `json:"scan,omitempty"`
+	Protect    []string         `json:"protect,omitempty"` // Never deleted, in addition to the built-in list
+	Duplicates *DuplicatesEntry `json:"duplicates,omitempty"`
+}
+
+// DuplicatesEntry sets which copy of each duplicate group is kept and how
+// similar images are matched
+type DuplicatesEntry struct {
+	Keep      string   `json:"keep,omitempty"`       // A models.KeepPolicy name
+	Prefer    []string `json:"prefer,omitempty"`     // Directories for the "preferred" policy, highest priority first
+	ImageHash string   `json:"image_hash,omitempty"` // An imagehash.Algorithm name
+	Distance  *int     `json:"distance,omitempty"`   // Differing hash bits up to which images are similar
+
+	MinSize    string   `json:"min_size,omitempty"`   // Smallest file compared, e.g. "100KB"
+	MaxSize    string   `json:"max_size,omitempty"`   // Largest file compared, e.g. "4GB"
+	Extensions []string `json:"extensions,omitempty"` // Only compare files with these extensions...
+	Kinds      []string `json:"kinds,omitempty"`      // ...or of these filetype.Kind names
+}
+
+// ScanEntry sets where the Big Files, Duplicate and Old Files finders search
+type ScanEntry struct {
+	Roots       []string `json:"roots,omitempty"`        // Replace each finder's default directories
+	Exclude     []string `json:"exclude,omitempty"`      // Added after the default exclusions; "!" re-includes
+	Hidden      *bool    `json:"hidden,omitempty"`       // Include dot files and directories
+	IgnoreFiles *bool    `json:"ignore_files,omitempty"` // Honor .macleanerignore files
+}
+
+// TargetEntry adds, overrides or disables a cleanup target by name.
+// Unset fields keep the built-in value when overriding.
+type TargetEntry struct {
+	Name        string  `json:"name"`
+	Path        *string `json:"path,omitempty"` // Glob pattern, may start with ~
+	Description *string `json:"description,omitempty"`
+	Category    *string `json:"category,omitempty"`
+	Sudo        *bool   `json:"sudo,omitempty"`
+	Command     *string `json:"command,omitempty"` // Run instead of deleting Path
+	Disabled    bool    `json:"disabled,omitempty"`
+}
+
+// DefaultPath returns the config file location
+func DefaultPath() string {
+	if path := os.Getenv(EnvPath); path != "" {
+		return utils.ExpandPath(path)
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "macleaner", "config.json")
+	}
+	return utils.ExpandPath("~/.config/macleaner/config.json")
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineOf(data, syntaxErr.Offset), err)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// ApplyTargets layers the configured targets on top of base and returns the
+// result. All invalid entries are reported together.
+func (c *Config) ApplyTargets(base []models.CleanupTarget) ([]models.CleanupTarget, error) {
+	targets := make([]models.CleanupTarget, len(base))
+	copy(targets, base)
+
+	var errs []error
+	seen := make(map[string]bool)
+	disabled := make(map[string]bool)
+
+	for i, entry := range c.Targets {
+		where := fmt.Sprintf("targets[%d]", i)
+		name := strings.TrimSpace(entry.Name)
+		if name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+			continue
+		}
+		where = fmt.Sprintf("%s (%q)", where, name)
+
+		key := strings.ToLower(name)
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("%s: duplicate entry", where))
+			continue
+		}
+		seen[key] = true
+
+		idx := indexOf(targets, name)
+		if entry.Disabled {
+			if idx < 0 {
+				errs = append(errs, fmt.Errorf("%s: cannot disable unknown target", where))
+			}
+			disabled[key] = true
+			continue
+		}
+
+		var target models.CleanupTarget
+		if idx >= 0 {
+			target = targets[idx]
+		} else {
+			target = models.CleanupTarget{Name: name}
+		}
+		entry.applyTo(&target)
+
+		if err := validateTarget(target); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where, err))
+			continue
+		}
+
+		if idx >= 0 {
+			targets[idx] = target
+		} else {
+			targets = insertByCategory(targets, target)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	result := targets[:0]
+	for _, t := range targets {
+		if !disabled[strings.ToLower(t.Name)] {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// ApplyScope layers the configured scan scope on top of base and returns
+// the result. All invalid roots and patterns are reported together.
+func (c *Config) ApplyScope(base scope.Scope) (scope.Scope, error) {
+	if c.Scan == nil {
+		return base, nil
+	}
+
+	result := base
+	var errs []error
+	if len(c.Scan.Roots) > 0 {
+		result.Roots = nil
+		for i, root := range c.Scan.Roots {
+			root = strings.TrimSpace(root)
+			if !strings.HasPrefix(root, "/") && !strings.HasPrefix(root, "~/") {
+				errs = append(errs, fmt.Errorf("scan.roots[%d]: %q must be absolute or start with ~/", i, root))
+				continue
+			}
+			result.Roots = append(result.Roots, root)
+		}
+	}
+	result.Exclude = append(append([]string(nil), base.Exclude...), c.Scan.Exclude...)
+	if c.Scan.Hidden != nil {
+		result.Hidden = *c.Scan.Hidden
+	}
+	if c.Scan.IgnoreFiles != nil {
+		result.IgnoreFiles = *c.Scan.IgnoreFiles
+	}
+
+	if err := (scope.Scope{Exclude: c.Scan.Exclude}).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("scan.exclude: %w", err))
+	}
+	if len(errs) > 0 {
+		return base, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// ProtectedPaths returns the configured protected paths with ~ expanded.
+// Every entry must be absolute or start with ~/.
+func (c *Config) ProtectedPaths() ([]string, error) {
+	var paths []string
+	var errs []error
+	for i, path := range c.Protect {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "~/") {
+			errs = append(errs, fmt.Errorf("protect[%d]: %q must be absolute or start with ~/", i, path))
+			continue
+		}
+		paths = append(paths, filepath.Clean(utils.ExpandPath(path)))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return paths, nil
+}
+
+// KeepPolicy returns the configured duplicate keep policy and preferred
+// directories, defaulting to models.KeepNotDownloads
+func (c *Config) KeepPolicy() (models.KeepPolicy, []string, error) {
+	if c.Duplicates == nil {
+		return models.KeepNotDownloads, nil, nil
+	}
+
+	policy := models.KeepNotDownloads
+	var errs []error
+	if c.Duplicates.Keep != "" {
+		p, err := models.ParseKeepPolicy(c.Duplicates.Keep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("duplicates.keep: %w", err))
+		}
+		policy = p
+	}
+	var prefer []string
+	for i, dir := range c.Duplicates.Prefer {
+		dir = strings.TrimSpace(dir)
+		if !strings.HasPrefix(dir, "/") && !strings.HasPrefix(dir, "~/") {
+			errs = append(errs, fmt.Errorf("duplicates.prefer[%d]: %q must be absolute or start with ~/", i, dir))
+			continue
+		}
+		prefer = append(prefer, dir)
+	}
+	if policy == models.KeepPreferred && len(c.Duplicates.Prefer) == 0 {
+		errs = append(errs, fmt.Errorf("duplicates.keep: preferred needs duplicates.prefer"))
+	}
+	if len(errs) > 0 {
+		return "", nil, errors.Join(errs...)
+	}
+	return policy, prefer, nil
+}
+
+// SimilarImages returns the configured perceptual hash and distance for
+// finding similar images, defaulting to imagehash.Difference and
+// imagehash.DefaultDistance
+func (c *Config) SimilarImages() (imagehash.Algorithm, int, error) {
+	algo, distance := imagehash.Difference, imagehash.DefaultDistance
+	if c.Duplicates == nil {
+		return algo, distance, nil
+	}
+
+	var errs []error
+	if c.Duplicates.ImageHash != "" {
+		a, err := imagehash.ParseAlgorithm(c.Duplicates.ImageHash)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("duplicates.image_hash: %w", err))
+		}
+		algo = a
+	}
+	if c.Duplicates.Distance != nil {
+		distance = *c.Duplicates.Distance
+		if distance < 0 || distance > 64 {
+			errs = append(errs, fmt.Errorf("duplicates.distance: %d is not between 0 and 64", distance))
+		}
+	}
+	if len(errs) > 0 {
+		return "", 0, errors.Join(errs...)
+	}
+	return algo, distance, nil
+}
+
+// DuplicateFilter returns the configured filter of the Duplicate Finder,
+// defaulting to models.DefaultDuplicateFilter
+func (c *Config) DuplicateFilter() (models.DuplicateFilter, error) {
+	f := models.DefaultDuplicateFilter()
+	if c.Duplicates == nil {
+		return f, nil
+	}
+
+	var errs []error
+	if c.Duplicates.MinSize != "" {
+		size, err := utils.ParseSize(c.Duplicates.MinSize)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("duplicates.min_size: %w", err))
+		}
+		f.MinSize = size
+	}
+	if c.Duplicates.MaxSize != "" {
+		size, err := utils.ParseSize(c.Duplicates.MaxSize)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("duplicates.max_size: %w", err))
+		}
+		f.MaxSize = size
+	}
+	for i, ext := range c.Duplicates.Extensions {
+		ext = filetype.NormalizeExtension(ext)
+		if ext == "" || strings.ContainsAny(ext[1:], "./") {
+			errs = append(errs, fmt.Errorf("duplicates.extensions[%d]: %q is not an extension", i, c.Duplicates.Extensions[i]))
+			continue
+		}
+		f.Extensions = append(f.Extensions, ext)
+	}
+	for i, name := range c.Duplicates.Kinds {
+		kind, err := filetype.ParseKind(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("duplicates.kinds[%d]: %w", i, err))
+			continue
+		}
+		f.Kinds = append(f.Kinds, kind)
+	}
+	if len(errs) == 0 {
+		if err := f.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("duplicates: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return models.DuplicateFilter{}, errors.Join(errs...)
+	}
+	return f, nil
+}
+
+// applyTo copies the fields set in the entry onto target
+func (e TargetEntry) applyTo(target *models.CleanupTarget) {
+	if e.Path != nil {
+		target.Path = strings.TrimSpace(*e.Path)
+	}
+	if e.Description != nil {
+		target.Description = *e.Description
+	}
+	if e.Category != nil {
+		target.Category = strings.TrimSpace(*e.Category)
+	}
+	if e.Sudo != nil {
+		target.RequiresSudo = *e.Sudo
+	}
+	if e.Command != nil {
+		target.Command = strings.TrimSpace(*e.Command)
+		target.IsCommand = target.Command != ""
+	}
+}
+
+// validateTarget checks that a merged target can be scanned and cleaned
+func validateTarget(t models.CleanupTarget) error {
+	if t.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if t.IsCommand {
+		return nil
+	}
+	if t.Path == "" {
+		return fmt.Errorf("path or command is required")
+	}
+	if !strings.HasPrefix(t.Path, "/") && !strings.HasPrefix(t.Path, "~/") {
+		return fmt.Errorf("path %q must be absolute or start with ~/", t.Path)
+	}
+	if _, err := filepath.Match(t.Path, ""); err != nil {
+		return fmt.Errorf("invalid path pattern %q: %w", t.Path, err)
+	}
+	return nil
+}
+
+// indexOf finds a target by name (case-insensitive)
+func indexOf(targets []models.CleanupTarget, name string) int {
+	for i, t := range targets {
+		if strings.EqualFold(t.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertByCategory adds target after the last target of the same category so
+// the category headers in the UI stay grouped
+func insertByCategory(targets []models.CleanupTarget, target models.CleanupTarget) []models.CleanupTarget {
+	pos := len(targets)
+	for i := len(targets) - 1; i >= 0; i-- {
+		if strings.EqualFold(targets[i].Category, target.Category) {
+			pos = i + 1
+			break
+		}
+	}
+	targets = append(targets, models.CleanupTarget{})
+	copy(targets[pos+1:], targets[pos:])
+	targets[pos] = target
+	return targets
+}
+
+// lineOf returns the 1-based line number of a byte offset
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/imagehash"
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/scope"
+)
+
+func baseTargets() []models.CleanupTarget {
+	return []models.CleanupTarget{
+		{Name: "User Caches", Path: "~/Library/Caches/*", Category: "Cache"},
+		{Name: "Gradle Cache", Path: "~/.gradle/caches", Category: "Dev"},
+		{Name: "Trash", Path: "~/.Trash/*", Category: "Trash"},
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Missing(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Targets) != 0 {
+		t.Errorf("Load() returned %d targets, want 0", len(cfg.Targets))
+	}
+}
+
+func TestLoad_SyntaxError(t *testing.T) {
+	path := writeConfig(t, "{\n  \"targets\": [\n    {\"name\": \"x\",}\n  ]\n}")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load() expected syntax error")
+	}
+	if !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("error should point at line 3: %v", err)
+	}
+}
+
+func TestLoad_UnknownField(t *testing.T) {
+	path := writeConfig(t, `{"targets": [{"name": "x", "pth": "/tmp"}]}`)
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("Load() expected error for unknown field")
+	}
+}
+
+func TestApplyTargets(t *testing.T) {
+	path := writeConfig(t, `{
+		"targets": [
+			{"name": "Bazel Output", "path": "~/.cache/bazel/_bazel_*/*", "category": "Dev", "description": "Bazel output base"},
+			{"name": "gradle cache", "path": "~/.gradle/caches/*", "sudo": true},
+			{"name": "Trash", "disabled": true},
+			{"name": "SDK Artifacts", "command": "sdk prune", "category": "Company"}
+		]
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := cfg.ApplyTargets(baseTargets())
+	if err != nil {
+		t.Fatalf("ApplyTargets() error = %v", err)
+	}
+
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	want := []string{"User Caches", "Gradle Cache", "Bazel Output", "SDK Artifacts"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("targets = %v, want %v", names, want)
+	}
+
+	gradle := targets[1]
+	if gradle.Path != "~/.gradle/caches/*" || !gradle.RequiresSudo || gradle.Category != "Dev" {
+		t.Errorf("override not applied: %+v", gradle)
+	}
+
+	sdk := targets[3]
+	if !sdk.IsCommand || sdk.Command != "sdk prune" {
+		t.Errorf("command target not applied: %+v", sdk)
+	}
+}
+
+func TestApplyTargets_DoesNotModifyBase(t *testing.T) {
+	base := baseTargets()
+	path := "/tmp/other"
+	cfg := &Config{Targets: []TargetEntry{{Name: "Trash", Path: &path}}}
+
+	if _, err := cfg.ApplyTargets(base); err != nil {
+		t.Fatal(err)
+	}
+	if base[2].Path != "~/.Trash/*" {
+		t.Error("ApplyTargets() modified the base targets")
+	}
+}
+
+func TestApplyTargets_Validation(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{
+		"targets": [
+			{"path": "/tmp/x"},
+			{"name": "No Category", "path": "/tmp/x"},
+			{"name": "Relative", "path": "tmp/x", "category": "Dev"},
+			{"name": "Bad Glob", "path": "/tmp/[", "category": "Dev"},
+			{"name": "Empty", "category": "Dev"},
+			{"name": "Missing", "disabled": true},
+			{"name": "Trash", "disabled": true},
+			{"name": "trash", "path": "/tmp/trash"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cfg.ApplyTargets(baseTargets())
+	if err == nil {
+		t.Fatal("ApplyTargets() expected validation errors")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"targets[0]: name is required",
+		`targets[1] ("No Category"): category is required`,
+		`targets[2] ("Relative"): path "tmp/x" must be absolute`,
+		`targets[3] ("Bad Glob"): invalid path pattern`,
+		`targets[4] ("Empty"): path or command is required`,
+		`targets[5] ("Missing"): cannot disable unknown target`,
+		`targets[7] ("trash"): duplicate entry`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing error %q in:\n%s", want, msg)
+		}
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv(EnvPath, "/etc/macleaner.json")
+	if got := DefaultPath(); got != "/etc/macleaner.json" {
+		t.Errorf("DefaultPath() = %q, want env override", got)
+	}
+
+	t.Setenv(EnvPath, "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got := DefaultPath(); got != "/xdg/macleaner/config.json" {
+		t.Errorf("DefaultPath() = %q, want XDG path", got)
+	}
+}
+
+func TestApplyScope(t *testing.T) {
+	path := writeConfig(t, `{"scan": {"roots": ["~/Projects"], "exclude": ["*.iso", "!Library"], "hidden": true}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := cfg.ApplyScope(scope.Default())
+	if err != nil {
+		t.Fatalf("ApplyScope() error = %v", err)
+	}
+	if len(s.Roots) != 1 || s.Roots[0] != "~/Projects" {
+		t.Errorf("Roots = %v", s.Roots)
+	}
+	if len(s.Exclude) != len(scope.DefaultExclude)+2 || s.Exclude[len(s.Exclude)-1] != "!Library" {
+		t.Errorf("Exclude = %v, want defaults followed by the configured patterns", s.Exclude)
+	}
+	if !s.Hidden || !s.IgnoreFiles {
+		t.Errorf("Hidden = %v, IgnoreFiles = %v", s.Hidden, s.IgnoreFiles)
+	}
+}
+
+func TestApplyScope_Invalid(t *testing.T) {
+	path := writeConfig(t, `{"scan": {"roots": ["relative/dir"], "exclude": ["[oops"]}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cfg.ApplyScope(scope.Default())
+	if err == nil {
+		t.Fatal("ApplyScope() expected error")
+	}
+	for _, want := range []string{"scan.roots[0]", "scan.exclude"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestProtectedPaths(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	cfg := &Config{Protect: []string{"~/Projects", " /Volumes/Backup/ "}}
+
+	paths, err := cfg.ProtectedPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(home, "Projects"), "/Volumes/Backup"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("ProtectedPaths() = %v, want %v", paths, want)
+	}
+
+	cfg = &Config{Protect: []string{"/ok", "relative", ""}}
+	_, err = cfg.ProtectedPaths()
+	if err == nil {
+		t.Fatal("ProtectedPaths() expected error")
+	}
+	for _, want := range []string{"protect[1]", "protect[2]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestKeepPolicy(t *testing.T) {
+	policy, prefer, err := (&Config{}).KeepPolicy()
+	if err != nil || policy != models.KeepNotDownloads || prefer != nil {
+		t.Errorf("KeepPolicy() = %q, %v, %v; want the default", policy, prefer, err)
+	}
+
+	path := writeConfig(t, `{"duplicates": {"keep": "preferred", "prefer": ["~/Documents", "/Volumes/Photos"]}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, prefer, err = cfg.KeepPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy != models.KeepPreferred || !reflect.DeepEqual(prefer, []string{"~/Documents", "/Volumes/Photos"}) {
+		t.Errorf("KeepPolicy() = %q, %v", policy, prefer)
+	}
+
+	cfg = &Config{Duplicates: &DuplicatesEntry{Keep: "largest", Prefer: []string{"Documents"}}}
+	_, _, err = cfg.KeepPolicy()
+	if err == nil {
+		t.Fatal("KeepPolicy() expected error")
+	}
+	for _, want := range []string{"duplicates.keep", "duplicates.prefer[0]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestSimilarImages(t *testing.T) {
+	algo, distance, err := (&Config{}).SimilarImages()
+	if err != nil || algo != imagehash.Difference || distance != imagehash.DefaultDistance {
+		t.Errorf("SimilarImages() = %q, %d, %v; want the default", algo, distance, err)
+	}
+
+	path := writeConfig(t, `{"duplicates": {"image_hash": "phash", "distance": 0}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	algo, distance, err = cfg.SimilarImages()
+	if err != nil || algo != imagehash.Perceptual || distance != 0 {
+		t.Errorf("SimilarImages() = %q, %d, %v; want phash, 0", algo, distance, err)
+	}
+
+	tooFar := 65
+	cfg = &Config{Duplicates: &DuplicatesEntry{ImageHash: "md5", Distance: &tooFar}}
+	_, _, err = cfg.SimilarImages()
+	if err == nil {
+		t.Fatal("SimilarImages() expected error")
+	}
+	for _, want := range []string{"duplicates.image_hash", "duplicates.distance"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	f, err := (&Config{}).DuplicateFilter()
+	if err != nil || !reflect.DeepEqual(f, models.DefaultDuplicateFilter()) {
+		t.Errorf("DuplicateFilter() = %+v, %v; want the default", f, err)
+	}
+
+	path := writeConfig(t, `{"duplicates": {"min_size": "100KB", "max_size": "2GB", "extensions": ["PSD", ".ai"], "kinds": ["videos"]}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = cfg.DuplicateFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.DuplicateFilter{
+		MinSize:    100 * 1024,
+		MaxSize:    2 << 30,
+		Extensions: []string{".psd", ".ai"},
+		Kinds:      []filetype.Kind{filetype.Video},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("DuplicateFilter() = %+v, want %+v", f, want)
+	}
+
+	cfg = &Config{Duplicates: &DuplicatesEntry{MinSize: "huge", Extensions: []string{"tar.gz"}, Kinds: []string{"spreadsheets"}}}
+	_, err = cfg.DuplicateFilter()
+	if err == nil {
+		t.Fatal("DuplicateFilter() expected error")
+	}
+	for _, want := range []string{"duplicates.min_size", "duplicates.extensions[0]", "duplicates.kinds[0]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	cfg = &Config{Duplicates: &DuplicatesEntry{MinSize: "10MB", MaxSize: "1MB"}}
+	if _, err := cfg.DuplicateFilter(); err == nil {
+		t.Error("DuplicateFilter() expected error for an empty size range")
+	}
+}
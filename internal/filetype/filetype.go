@@ -0,0 +1,132 @@
+// Package filetype classifies files into broad kinds such as images, video
+// or archives
+package filetype
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Kind is a broad category of file content
+type Kind string
+
+const (
+	Image     Kind = "image"
+	Video     Kind = "video"
+	Audio     Kind = "audio"
+	Archive   Kind = "archive"
+	Document  Kind = "document"
+	DiskImage Kind = "disk-image" // ISO and other raw disk images
+	VMDisk    Kind = "vm-disk"    // Virtual machine disks
+	Installer Kind = "installer"  // Packages and downloaded .dmg images
+	Dataset   Kind = "dataset"    // Tabular and scientific data, databases
+	Other     Kind = "other"      // Anything not recognised
+)
+
+// Kinds lists every Kind a file can be filtered by, in menu order
+var Kinds = []Kind{Image, Video, Audio, Archive, Document, DiskImage, VMDisk, Installer, Dataset}
+
+// ParseKind parses a Kind name. Plurals such as "images" are accepted, and
+// spaces or underscores may stand for dashes.
+func ParseKind(s string) (Kind, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(" ", "-", "_", "-").Replace(s)
+	for _, k := range Kinds {
+		if string(k) == s || string(k)+"s" == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown file kind %q (use %s)", s, KindNames())
+}
+
+// KindNames lists the names ParseKind accepts, e.g. "image, video, ... or
+// dataset"
+func KindNames() string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	last := len(names) - 1
+	return strings.Join(names[:last], ", ") + " or " + names[last]
+}
+
+// Label returns the kind as shown in the UI, in the plural
+func (k Kind) Label() string {
+	switch k {
+	case Video, Audio:
+		return string(k)
+	case DiskImage:
+		return "disk images"
+	case VMDisk:
+		return "VM disks"
+	case Other:
+		return "other files"
+	}
+	return string(k) + "s"
+}
+
+// extensions maps lower-case extensions to their kind
+var extensions = map[string]Kind{
+	".jpg": Image, ".jpeg": Image, ".png": Image, ".gif": Image, ".heic": Image, ".heif": Image,
+	".tif": Image, ".tiff": Image, ".bmp": Image, ".webp": Image, ".psd": Image, ".svg": Image,
+	".raw": Image, ".cr2": Image, ".cr3": Image, ".nef": Image, ".arw": Image, ".dng": Image,
+
+	".mp4": Video, ".m4v": Video, ".mov": Video, ".avi": Video, ".mkv": Video, ".webm": Video,
+	".wmv": Video, ".flv": Video, ".mpg": Video, ".mpeg": Video, ".3gp": Video,
+
+	".mp3": Audio, ".m4a": Audio, ".aac": Audio, ".wav": Audio, ".aif": Audio, ".aiff": Audio,
+	".flac": Audio, ".ogg": Audio, ".opus": Audio,
+
+	".zip": Archive, ".tar": Archive, ".gz": Archive, ".tgz": Archive, ".bz2": Archive,
+	".xz": Archive, ".7z": Archive, ".rar": Archive, ".zst": Archive,
+
+	".pdf": Document, ".doc": Document, ".docx": Document, ".xls": Document, ".xlsx": Document,
+	".ppt": Document, ".pptx": Document, ".pages": Document, ".numbers": Document, ".key": Document,
+	".txt": Document, ".rtf": Document, ".md": Document, ".odt": Document, ".ods": Document,
+	".odp": Document, ".epub": Document,
+
+	".iso": DiskImage, ".img": DiskImage, ".cdr": DiskImage, ".toast": DiskImage,
+	".sparseimage": DiskImage,
+
+	".vmdk": VMDisk, ".vdi": VMDisk, ".vhd": VMDisk, ".vhdx": VMDisk, ".qcow2": VMDisk,
+	".qcow": VMDisk, ".hdd": VMDisk, ".hds": VMDisk,
+
+	// Most .dmg files are downloaded applications, so they count as installers
+	".pkg": Installer, ".mpkg": Installer, ".dmg": Installer, ".xip": Installer,
+	".msi": Installer, ".exe": Installer, ".deb": Installer, ".rpm": Installer,
+
+	".csv": Dataset, ".tsv": Dataset, ".parquet": Dataset, ".jsonl": Dataset, ".ndjson": Dataset,
+	".h5": Dataset, ".hdf5": Dataset, ".npy": Dataset, ".npz": Dataset, ".arrow": Dataset,
+	".feather": Dataset, ".avro": Dataset, ".orc": Dataset, ".sqlite": Dataset,
+	".sqlite3": Dataset, ".db": Dataset,
+}
+
+// ByExtension returns the kind of the file called name, judged by its
+// extension alone
+func ByExtension(name string) Kind {
+	if k, ok := extensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return k
+	}
+	return Other
+}
+
+// Classify returns the kind of the file at path: by its extension when that
+// is known, and otherwise by its contents, so renamed downloads and files
+// without an extension are still recognised
+func Classify(path string) Kind {
+	if k := ByExtension(path); k != Other {
+		return k
+	}
+	return Sniff(path)
+}
+
+// NormalizeExtension returns ext in lower case with a leading dot, so "PSD",
+// "psd" and ".psd" compare equal
+func NormalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
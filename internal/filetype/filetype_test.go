@@ -0,0 +1,112 @@
+package filetype
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestByExtension(t *testing.T) {
+	for name, want := range map[string]Kind{
+		"IMG_0001.HEIC":  Image,
+		"clip.mov":       Video,
+		"song.flac":      Audio,
+		"backup.tar.gz":  Archive,
+		"report.pdf":     Document,
+		"main.go":        Other,
+		"no-extension":   Other,
+		"/a/b/notes.txt": Document,
+		"Xcode_16.xip":   Installer,
+		"Firefox.dmg":    Installer,
+		"ubuntu.iso":     DiskImage,
+		"win11.vmdk":     VMDisk,
+		"events.parquet": Dataset,
+	} {
+		if got := ByExtension(name); got != want {
+			t.Errorf("ByExtension(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for s, want := range map[string]Kind{"image": Image, "Images": Image, "video": Video, "archives": Archive,
+		"disk images": DiskImage, "VM_disks": VMDisk, "installer": Installer, "datasets": Dataset,
+	} {
+		if got, err := ParseKind(s); err != nil || got != want {
+			t.Errorf("ParseKind(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseKind("other"); err == nil {
+		t.Error("ParseKind(other) expected error")
+	}
+}
+
+func TestNormalizeExtension(t *testing.T) {
+	for in, want := range map[string]string{"PSD": ".psd", ".Ai": ".ai", " raw ": ".raw", "": ""} {
+		if got := NormalizeExtension(in); got != want {
+			t.Errorf("NormalizeExtension(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	at := func(size, offset int, magic string) []byte {
+		data := make([]byte, size)
+		copy(data[offset:], magic)
+		return data
+	}
+
+	for name, tt := range map[string]struct {
+		data []byte
+		want Kind
+	}{
+		"jpeg":     {[]byte("\xff\xd8\xff\xe0 JFIF"), Image},
+		"heic":     {[]byte("\x00\x00\x00\x18ftypheic\x00\x00"), Image},
+		"mp4":      {[]byte("\x00\x00\x00\x20ftypisom\x00\x00"), Video},
+		"wav":      {[]byte("RIFF\x00\x00\x00\x00WAVEfmt "), Audio},
+		"zip":      {[]byte("PK\x03\x04rest"), Archive},
+		"tar":      {at(1024, 257, "ustar"), Archive},
+		"pdf":      {[]byte("%PDF-1.7"), Document},
+		"iso":      {at(40000, 32769, "CD001"), DiskImage},
+		"dmg":      {at(4096, 4096-512, "koly"), Installer},
+		"pkg":      {[]byte("xar!\x00\x1c"), Installer},
+		"vmdk":     {[]byte("KDMV\x01\x00"), VMDisk},
+		"vhd":      {at(4096, 4096-512, "conectix"), VMDisk},
+		"sqlite":   {[]byte("SQLite format 3\x00"), Dataset},
+		"text":     {[]byte(strings.Repeat("hello ", 100)), Other},
+		"empty":    {nil, Other},
+		"tinyriff": {[]byte("RIFF"), Other},
+	} {
+		if got := Sniff(write(name, tt.data)); got != tt.want {
+			t.Errorf("Sniff(%s) = %q, want %q", name, got, tt.want)
+		}
+	}
+	if got := Sniff(filepath.Join(dir, "missing")); got != Other {
+		t.Errorf("Sniff(missing) = %q, want other", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	dir := t.TempDir()
+	// A known extension wins over the contents: .docx files are zips
+	docx := filepath.Join(dir, "report.docx")
+	os.WriteFile(docx, []byte("PK\x03\x04"), 0644)
+	if got := Classify(docx); got != Document {
+		t.Errorf("Classify(report.docx) = %q, want document", got)
+	}
+	// Unknown extensions fall back to the contents
+	download := filepath.Join(dir, "Installer.pkg.download")
+	os.WriteFile(download, []byte("xar!\x00\x1c"), 0644)
+	if got := Classify(download); got != Installer {
+		t.Errorf("Classify(Installer.pkg.download) = %q, want installer", got)
+	}
+}
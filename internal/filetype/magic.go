@@ -0,0 +1,150 @@
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// headSize covers the deepest signature, the ISO 9660 volume descriptor
+const headSize = 32769 + 5
+
+// tailSize covers the trailers of .dmg images and VHD disks
+const tailSize = 512
+
+// signature is a run of bytes at a fixed offset that identifies a format.
+// A negative offset counts from the end of the file.
+type signature struct {
+	offset int
+	magic  string
+	kind   Kind
+}
+
+// signatures are checked in order; trailers come first, as the head of a
+// compressed disk image can be anything
+var signatures = []signature{
+	{-512, "koly", Installer},  // Apple disk image (UDIF)
+	{-512, "conectix", VMDisk}, // VHD
+
+	{0, "xar!", Installer}, // .pkg and .xip
+
+	{0, "KDMV", VMDisk},
+	{0, "# Disk DescriptorFile", VMDisk}, // VMDK descriptor
+	{0, "QFI\xfb", VMDisk},
+	{0, "vhdxfile", VMDisk},
+	{0x40, "\x7f\x10\xda\xbe", VMDisk}, // VirtualBox VDI
+	{0, "WithoutFreeSpace", VMDisk},    // Parallels
+	{0, "WithouFreSpacExt", VMDisk},
+
+	{32769, "CD001", DiskImage},  // ISO 9660
+	{512, "EFI PART", DiskImage}, // GPT partition table
+
+	{0, "SQLite format 3\x00", Dataset},
+	{0, "PAR1", Dataset},
+	{0, "\x89HDF\r\n\x1a\n", Dataset},
+	{0, "\x93NUMPY", Dataset},
+	{0, "ARROW1", Dataset},
+	{0, "Obj\x01", Dataset}, // Avro
+	{0, "ORC", Dataset},
+
+	{0, "\xff\xd8\xff", Image},
+	{0, "\x89PNG\r\n\x1a\n", Image},
+	{0, "GIF87a", Image},
+	{0, "GIF89a", Image},
+	{0, "II*\x00", Image},
+	{0, "MM\x00*", Image},
+	{0, "8BPS", Image},
+
+	{0, "\x1a\x45\xdf\xa3", Video}, // Matroska and WebM
+	{0, "\x00\x00\x01\xba", Video}, // MPEG program stream
+	{0, "FLV\x01", Video},
+
+	{0, "ID3", Audio},
+	{0, "fLaC", Audio},
+	{0, "OggS", Audio},
+
+	{0, "%PDF-", Document},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", Document}, // Legacy Office
+
+	{0, "PK\x03\x04", Archive},
+	{0, "\x1f\x8b", Archive},
+	{0, "BZh", Archive},
+	{0, "\xfd7zXZ\x00", Archive},
+	{0, "7z\xbc\xaf\x27\x1c", Archive},
+	{0, "Rar!\x1a\x07", Archive},
+	{0, "\x28\xb5\x2f\xfd", Archive}, // Zstandard
+	{257, "ustar", Archive},
+}
+
+// Sniff returns the kind of the file at path judged by its contents alone,
+// or Other when they are not recognised or cannot be read
+func Sniff(path string) Kind {
+	f, err := os.Open(path)
+	if err != nil {
+		return Other
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return Other
+	}
+
+	head := make([]byte, min(headSize, info.Size()))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return Other
+	}
+	var tail []byte
+	if info.Size() >= 2*tailSize {
+		tail = make([]byte, tailSize)
+		if _, err := f.ReadAt(tail, info.Size()-tailSize); err != nil {
+			return Other
+		}
+	}
+	return sniff(head, tail)
+}
+
+// sniff matches the first bytes of a file, and its last tailSize bytes when
+// it is large enough to have a trailer
+func sniff(head, tail []byte) Kind {
+	for _, sig := range signatures {
+		b, offset := head, sig.offset
+		if offset < 0 {
+			b, offset = tail, len(tail)+offset
+		}
+		if offset >= 0 && bytes.HasPrefix(b[min(offset, len(b)):], []byte(sig.magic)) {
+			return sig.kind
+		}
+	}
+	return container(head)
+}
+
+// container recognises formats that share a container by the type stored
+// after its header: ISO media files, RIFF and IFF
+func container(head []byte) Kind {
+	if len(head) < 12 {
+		return Other
+	}
+	brand := string(head[8:12])
+	switch {
+	case string(head[4:8]) == "ftyp":
+		switch brand {
+		case "heic", "heix", "heim", "heis", "mif1", "msf1", "avif":
+			return Image
+		case "M4A ", "M4B ", "M4P ":
+			return Audio
+		}
+		return Video
+	case string(head[:4]) == "RIFF":
+		switch brand {
+		case "WEBP":
+			return Image
+		case "WAVE":
+			return Audio
+		case "AVI ":
+			return Video
+		}
+	case string(head[:4]) == "FORM" && (brand == "AIFF" || brand == "AIFC"):
+		return Audio
+	}
+	return Other
+}
@@ -0,0 +1,223 @@
+// Package hashcache remembers file hashes between duplicate scans
+package hashcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"macos-cleaner/internal/utils"
+)
+
+// Version identifies how the cached hashes were computed. Bump it whenever
+// utils.PartialHash or utils.FileHash change so old hashes are discarded
+// instead of matched against new ones.
+const Version = 1
+
+// DefaultMaxEntries caps the number of files remembered; the least recently
+// used are dropped first
+const DefaultMaxEntries = 200000
+
+// entry is the cached hashes of one file. A file whose size, modification
+// time or inode differ from the entry's is hashed again.
+type entry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+	Inode   uint64 `json:"inode"`
+	Partial string `json:"partial,omitempty"`
+	Full    string `json:"full,omitempty"`
+	Used    int64  `json:"used"` // Unix seconds of the last lookup
+}
+
+// file is the on-disk format
+type file struct {
+	Version int      `json:"version"`
+	Entries []*entry `json:"entries"`
+}
+
+// Cache is a JSON file of file hashes. It is safe for concurrent use.
+type Cache struct {
+	Path       string
+	MaxEntries int // Entries kept by Save; 0 uses DefaultMaxEntries
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	dirty   bool
+}
+
+// DefaultPath returns the default cache file location
+func DefaultPath() string {
+	return filepath.Join(utils.DataDir(), "hashcache.json")
+}
+
+// New creates an empty Cache backed by path; call Load to read it
+func New(path string) *Cache {
+	return &Cache{Path: path, entries: make(map[string]*entry)}
+}
+
+// Load reads the cache file. A missing file, or one written for another
+// Version, leaves the cache empty. An unreadable file also leaves it empty
+// and returns the error, so callers can warn and carry on.
+func (c *Cache) Load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*entry)
+	data, err := os.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%s: %w", c.Path, err)
+	}
+	if f.Version != Version {
+		c.dirty = true // Rewrite in the current format
+		return nil
+	}
+	for _, e := range f.Entries {
+		c.entries[e.Path] = e
+	}
+	return nil
+}
+
+// Save writes the cache file if anything changed, keeping the MaxEntries
+// most recently used entries
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	f := file{Version: Version, Entries: make([]*entry, 0, len(c.entries))}
+	for _, e := range c.entries {
+		f.Entries = append(f.Entries, e)
+	}
+	sort.Slice(f.Entries, func(i, j int) bool {
+		if f.Entries[i].Used != f.Entries[j].Used {
+			return f.Entries[i].Used > f.Entries[j].Used
+		}
+		return f.Entries[i].Path < f.Entries[j].Path
+	})
+	max := c.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxEntries
+	}
+	if len(f.Entries) > max {
+		for _, e := range f.Entries[max:] {
+			delete(c.entries, e.Path)
+		}
+		f.Entries = f.Entries[:max]
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return err
+	}
+	tmp := c.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.Path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Clear forgets every entry and removes the cache file
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*entry)
+	c.dirty = false
+	if err := os.Remove(c.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Len returns the number of files remembered
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Partial returns utils.PartialHash of path, computing it only if the file
+// changed since it was cached
+func (c *Cache) Partial(path string) string {
+	return c.hash(path, func(e *entry) *string { return &e.Partial }, utils.PartialHash)
+}
+
+// Full returns utils.FileHash of path, computing it only if the file
+// changed since it was cached
+func (c *Cache) Full(path string) string {
+	return c.hash(path, func(e *entry) *string { return &e.Full }, utils.FileHash)
+}
+
+// hash looks up the hash field selects for path, computing and storing it
+// on a miss. Files that cannot be read are not cached.
+func (c *Cache) hash(path string, field func(*entry) *string, compute func(string) string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	key := entry{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   utils.Inode(info),
+	}
+	now := time.Now().Unix()
+
+	c.mu.Lock()
+	if e := c.entries[path]; e != nil && e.matches(key) && *field(e) != "" {
+		if e.Used != now {
+			e.Used = now
+			c.dirty = true
+		}
+		h := *field(e)
+		c.mu.Unlock()
+		return h
+	}
+	c.mu.Unlock()
+
+	h := compute(path)
+	if h == "" {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[path]
+	if e == nil || !e.matches(key) {
+		e = &key
+		c.entries[path] = e
+	}
+	*field(e) = h
+	e.Used = now
+	c.dirty = true
+	return h
+}
+
+// matches reports whether e describes the same version of the file as key
+func (e *entry) matches(key entry) bool {
+	return e.Size == key.Size && e.ModTime == key.ModTime && e.Inode == key.Inode
+}
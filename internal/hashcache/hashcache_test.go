@@ -0,0 +1,140 @@
+package hashcache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"macos-cleaner/internal/utils"
+)
+
+func TestCache_HitAndInvalidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.bin")
+	os.WriteFile(file, []byte("original"), 0644)
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(file, mtime, mtime)
+
+	cache := New(filepath.Join(dir, "hashcache.json"))
+	want := utils.FileHash(file)
+	if got := cache.Full(file); got != want {
+		t.Fatalf("Full() = %q, want %q", got, want)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size, modification time and inode: the remembered hash is used
+	// even though the content changed in place
+	os.WriteFile(file, []byte("modified"), 0644)
+	os.Chtimes(file, mtime, mtime)
+	reloaded := New(cache.Path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Full(file); got != want {
+		t.Errorf("Full() of unchanged metadata = %q, want cached %q", got, want)
+	}
+
+	// A new modification time invalidates the entry
+	later := mtime.Add(time.Hour)
+	os.Chtimes(file, later, later)
+	if got := reloaded.Full(file); got != utils.FileHash(file) || got == want {
+		t.Errorf("Full() after mtime change = %q, want a fresh hash", got)
+	}
+
+	if got := reloaded.Full(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("Full() of missing file = %q, want empty", got)
+	}
+}
+
+func TestCache_PartialAndFullSeparate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.bin")
+	os.WriteFile(file, make([]byte, 64*1024), 0644)
+
+	cache := New(filepath.Join(dir, "hashcache.json"))
+	partial, full := cache.Partial(file), cache.Full(file)
+	if partial != utils.PartialHash(file) || full != utils.FileHash(file) {
+		t.Errorf("Partial(), Full() = %q, %q", partial, full)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1 entry holding both hashes", cache.Len())
+	}
+}
+
+func TestCache_VersionMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hashcache.json")
+	old := fmt.Sprintf(`{"version":%d,"entries":[{"path":"/x","size":1,"mtime":1,"inode":1,"full":"abc","used":1}]}`, Version+1)
+	os.WriteFile(path, []byte(old), 0600)
+
+	cache := New(path)
+	if err := cache.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d, entries of another version must be discarded", cache.Len())
+	}
+}
+
+func TestCache_LoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashcache.json")
+	os.WriteFile(path, []byte("not json"), 0600)
+
+	cache := New(path)
+	if err := cache.Load(); err == nil {
+		t.Error("Load() expected error for corrupt file")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after failed Load, want 0", cache.Len())
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	dir := t.TempDir()
+	cache := New(filepath.Join(dir, "cache", "hashcache.json"))
+	cache.MaxEntries = 2
+	for i := range 5 {
+		file := filepath.Join(dir, fmt.Sprintf("%d.bin", i))
+		os.WriteFile(file, []byte{byte(i)}, 0644)
+		cache.Full(file)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := New(cache.Path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Len() != 2 {
+		t.Errorf("Len() after Save = %d, want MaxEntries 2", reloaded.Len())
+	}
+}
+
+func TestCache_Clear(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.bin")
+	os.WriteFile(file, []byte("data"), 0644)
+
+	cache := New(filepath.Join(dir, "hashcache.json"))
+	cache.Full(file)
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cache.Path); !os.IsNotExist(err) {
+		t.Errorf("cache file still exists after Clear(): %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after Clear(), want 0", cache.Len())
+	}
+	if err := cache.Clear(); err != nil {
+		t.Errorf("Clear() of missing file = %v, want nil", err)
+	}
+}
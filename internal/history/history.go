@@ -0,0 +1,229 @@
+// Package history keeps a local ledger of clean and delete runs
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"macos-cleaner/internal/cleaner"
+	"macos-cleaner/internal/utils"
+)
+
+// Run is one clean or delete operation
+type Run struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"` // clean, bigfiles, duplicates, oldfiles or diskusage
+	Trash     bool      `json:"trash,omitempty"`
+	Results   []Result  `json:"results"`
+}
+
+// Result is the outcome for a single target within a run
+type Result struct {
+	Target    string `json:"target"`
+	Requested int64  `json:"requested"`
+	Freed     int64  `json:"freed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Store is an append-only JSON lines file of runs
+type Store struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// DefaultPath returns the default history file location
+func DefaultPath() string {
+	return filepath.Join(utils.DataDir(), "history.jsonl")
+}
+
+// New creates a Store backed by path
+func New(path string) *Store {
+	return &Store{Path: path}
+}
+
+// Append adds a run to the history
+func (s *Store) Append(run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads every run, oldest first. A missing file is an empty history.
+func (s *Store) Load() ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Time.Before(runs[j].Time)
+	})
+	return runs, nil
+}
+
+// FromCleanResults builds the run for a CleanTargets call
+func FromCleanResults(results []cleaner.CleanResult, trash bool) Run {
+	run := Run{Time: time.Now(), Operation: "clean", Trash: trash}
+	for _, r := range results {
+		result := Result{Target: r.Target, Requested: r.Requested, Freed: r.Actual}
+		if r.Error != nil {
+			result.Error = r.Error.Error()
+		}
+		run.Results = append(run.Results, result)
+	}
+	return run
+}
+
+// FromDeletion builds the run for a file deletion, recorded as a single
+// target. requested is the size the finder showed and is only informative:
+// freed space is counted in the cleaner's size mode and a linked duplicate
+// frees nothing, so the run fails only when paths were left behind.
+func FromDeletion(operation, target string, requested int64, report cleaner.DeleteReport, trash bool) Run {
+	result := Result{Target: target, Requested: requested, Freed: report.Freed()}
+	if failures := report.Failures(); len(failures) > 0 {
+		result.Error = fmt.Sprintf("%d could not be deleted (%s)", len(failures), report.Summary())
+	}
+	return Run{
+		Time:      time.Now(),
+		Operation: operation,
+		Trash:     trash,
+		Results:   []Result{result},
+	}
+}
+
+// TargetSummary aggregates the history of one target
+type TargetSummary struct {
+	Target     string
+	Runs       int
+	Failures   int
+	TotalFreed int64
+	LastFreed  int64
+	FirstRun   time.Time
+	LastRun    time.Time
+	// RegrowthPerDay is the space freed after the first run divided by the
+	// days it took to accumulate, i.e. how fast the target fills up again
+	RegrowthPerDay int64
+}
+
+// Summarize aggregates runs per target, fastest-regrowing first
+func Summarize(runs []Run) []TargetSummary {
+	byTarget := make(map[string]*TargetSummary)
+	var order []string
+
+	for _, run := range runs {
+		for _, r := range run.Results {
+			sum, ok := byTarget[r.Target]
+			if !ok {
+				sum = &TargetSummary{Target: r.Target, FirstRun: run.Time}
+				byTarget[r.Target] = sum
+				order = append(order, r.Target)
+			}
+			sum.Runs++
+			if r.Error != "" {
+				sum.Failures++
+			}
+			sum.TotalFreed += r.Freed
+			sum.LastFreed = r.Freed
+			sum.LastRun = run.Time
+		}
+	}
+
+	summaries := make([]TargetSummary, 0, len(order))
+	for _, name := range order {
+		sum := byTarget[name]
+		days := sum.LastRun.Sub(sum.FirstRun).Hours() / 24
+		if sum.Runs > 1 && days > 0 {
+			regrown := sum.TotalFreed - firstFreed(runs, name)
+			sum.RegrowthPerDay = int64(float64(regrown) / days)
+		}
+		summaries = append(summaries, *sum)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].RegrowthPerDay != summaries[j].RegrowthPerDay {
+			return summaries[i].RegrowthPerDay > summaries[j].RegrowthPerDay
+		}
+		return summaries[i].TotalFreed > summaries[j].TotalFreed
+	})
+	return summaries
+}
+
+// Filter returns the runs that touched target (case-insensitive), keeping
+// only that target's results
+func Filter(runs []Run, target string) []Run {
+	var filtered []Run
+	for _, run := range runs {
+		var results []Result
+		for _, r := range run.Results {
+			if strings.EqualFold(r.Target, target) {
+				results = append(results, r)
+			}
+		}
+		if len(results) > 0 {
+			run.Results = results
+			filtered = append(filtered, run)
+		}
+	}
+	return filtered
+}
+
+// firstFreed returns what the first run of target freed, which was
+// accumulated before the history started
+func firstFreed(runs []Run, target string) int64 {
+	for _, run := range runs {
+		for _, r := range run.Results {
+			if r.Target == target {
+				return r.Freed
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"macos-cleaner/internal/cleaner"
+)
+
+func TestStore_AppendLoad(t *testing.T) {
+	store := New(filepath.Join(t.TempDir(), "nested", "history.jsonl"))
+
+	runs, err := store.Load()
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("Load() on missing file = %v, %v", runs, err)
+	}
+
+	first := Run{
+		Time:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Operation: "clean",
+		Results:   []Result{{Target: "User Caches", Requested: 100, Freed: 90}},
+	}
+	second := Run{
+		Time:      time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Operation: "bigfiles",
+		Trash:     true,
+		Results:   []Result{{Target: "Big Files", Requested: 500, Freed: 0, Error: "permission denied"}},
+	}
+	if err := store.Append(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append(second); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Load() returned %d runs, want 2", len(runs))
+	}
+	if runs[1].Results[0].Error != "permission denied" || !runs[1].Trash {
+		t.Errorf("second run not preserved: %+v", runs[1])
+	}
+}
+
+func TestStore_LoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	os.WriteFile(path, []byte("{\"time\":\"2024-01-01T00:00:00Z\"}\nnot json\n"), 0600)
+
+	if _, err := New(path).Load(); err == nil {
+		t.Fatal("Load() expected error for corrupt line")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, 1+d, 0, 0, 0, 0, time.UTC)
+	}
+	runs := []Run{
+		{Time: day(0), Results: []Result{
+			{Target: "npm Cache", Freed: 5000},
+			{Target: "User Caches", Freed: 1000},
+		}},
+		{Time: day(10), Results: []Result{
+			{Target: "npm Cache", Freed: 1000},
+			{Target: "User Caches", Freed: 3000},
+		}},
+		{Time: day(20), Results: []Result{
+			{Target: "User Caches", Freed: 1000, Error: "partial"},
+			{Target: "Trash", Freed: 7000},
+		}},
+	}
+
+	summaries := Summarize(runs)
+	if len(summaries) != 3 {
+		t.Fatalf("Summarize() returned %d targets, want 3", len(summaries))
+	}
+
+	// User Caches regrew 4000 bytes in 20 days, npm 1000 in 10 days
+	caches := summaries[0]
+	if caches.Target != "User Caches" || caches.RegrowthPerDay != 200 {
+		t.Errorf("first summary = %+v, want User Caches at 200/day", caches)
+	}
+	if caches.Runs != 3 || caches.Failures != 1 || caches.TotalFreed != 5000 || caches.LastFreed != 1000 {
+		t.Errorf("unexpected User Caches summary: %+v", caches)
+	}
+	if summaries[1].Target != "npm Cache" || summaries[1].RegrowthPerDay != 100 {
+		t.Errorf("second summary = %+v, want npm Cache at 100/day", summaries[1])
+	}
+	if summaries[2].Target != "Trash" || summaries[2].RegrowthPerDay != 0 {
+		t.Errorf("single-run target should have no regrowth: %+v", summaries[2])
+	}
+}
+
+func TestFilter(t *testing.T) {
+	runs := []Run{
+		{Operation: "clean", Results: []Result{{Target: "Trash"}, {Target: "npm Cache"}}},
+		{Operation: "clean", Results: []Result{{Target: "User Caches"}}},
+	}
+
+	filtered := Filter(runs, "trash")
+	if len(filtered) != 1 || len(filtered[0].Results) != 1 || filtered[0].Results[0].Target != "Trash" {
+		t.Errorf("Filter() = %+v", filtered)
+	}
+	if len(runs[0].Results) != 2 {
+		t.Error("Filter() modified its input")
+	}
+}
+
+func TestFromCleanResults(t *testing.T) {
+	results := []cleaner.CleanResult{
+		{Target: "Trash", Requested: 100, Actual: 100},
+		{Target: "System Logs", Requested: 50, Error: errors.New("sudo failed")},
+	}
+
+	run := FromCleanResults(results, true)
+	if run.Operation != "clean" || !run.Trash || len(run.Results) != 2 {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if run.Results[0].Freed != 100 || run.Results[1].Error != "sudo failed" {
+		t.Errorf("unexpected results: %+v", run.Results)
+	}
+}
+
+func TestFromDeletion(t *testing.T) {
+	report := cleaner.DeleteReport{
+		{Path: "/a", Size: 1024, Status: cleaner.StatusDeleted},
+		{Path: "/b", Size: 1024, Status: cleaner.StatusPermissionDenied, Err: os.ErrPermission},
+	}
+	run := FromDeletion("bigfiles", "Big Files", 2048, report, false)
+	if len(run.Results) != 1 || run.Results[0].Freed != 1024 || run.Results[0].Error == "" {
+		t.Errorf("partial deletion not recorded as error: %+v", run)
+	}
+
+	// Freeing less than requested is no failure: sizes may be counted
+	// differently, and a linked duplicate frees nothing
+	report = cleaner.DeleteReport{
+		{Path: "/a", Size: 4, Status: cleaner.StatusDeleted},
+		{Path: "/b", Size: 0, Status: cleaner.StatusReplaced},
+		{Path: "/c", Status: cleaner.StatusVanished},
+	}
+	run = FromDeletion("duplicates", "Duplicates", 30, report, false)
+	if run.Results[0].Error != "" || run.Results[0].Freed != 4 || run.Results[0].Requested != 30 {
+		t.Errorf("complete deletion recorded as %+v", run.Results[0])
+	}
+}
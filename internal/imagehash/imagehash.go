@@ -0,0 +1,239 @@
+// Package imagehash computes perceptual hashes, 64-bit fingerprints that stay
+// close when an image is resized, re-encoded or slightly edited
+package imagehash
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	// Decoders for the formats Supported accepts
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Algorithm selects how an image is reduced to its hash
+type Algorithm string
+
+const (
+	Average    Algorithm = "ahash" // Pixels of an 8x8 thumbnail brighter than its mean
+	Difference Algorithm = "dhash" // Brightness gradients between neighbours of a 9x8 thumbnail
+	Perceptual Algorithm = "phash" // Low frequencies of the DCT of a 32x32 thumbnail
+)
+
+// Algorithms lists every Algorithm, fastest first
+var Algorithms = []Algorithm{Average, Difference, Perceptual}
+
+// DefaultDistance is the number of differing bits up to which two images
+// count as similar. Re-encoded and resized copies usually differ in fewer
+// than 5 of the 64 bits; unrelated images in about 32.
+const DefaultDistance = 10
+
+// ParseAlgorithm parses an Algorithm name
+func ParseAlgorithm(s string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if string(a) == s {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown image hash %q (use ahash, dhash or phash)", s)
+}
+
+// Label returns the algorithm as shown in the UI
+func (a Algorithm) Label() string {
+	switch a {
+	case Average:
+		return "average hash"
+	case Perceptual:
+		return "DCT hash"
+	}
+	return "difference hash"
+}
+
+// extensions are the file types the registered decoders read
+var extensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// Supported reports whether name has the extension of a decodable image
+func Supported(name string) bool {
+	return slices.Contains(extensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// MaxPixels is the largest image File decodes. Decoding holds every pixel in
+// memory, up to 8 bytes each, and several images are hashed at once.
+const MaxPixels = 50_000_000
+
+// ErrTooLarge is returned by File for images of more than MaxPixels pixels
+var ErrTooLarge = errors.New("image too large to hash")
+
+// File decodes the image at path and returns its hash. Images larger than
+// MaxPixels are not decoded.
+func File(path string, algo Algorithm) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// The header gives the dimensions without decoding the pixels
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return 0, fmt.Errorf("%s: %dx%d: %w", path, cfg.Width, cfg.Height, ErrTooLarge)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return Hash(img, algo), nil
+}
+
+// Hash returns the hash of img; an unknown algo uses Difference
+func Hash(img image.Image, algo Algorithm) uint64 {
+	switch algo {
+	case Average:
+		return averageHash(img)
+	case Perceptual:
+		return perceptualHash(img)
+	}
+	return differenceHash(img)
+}
+
+// Distance returns the number of bits in which two hashes differ
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func averageHash(img image.Image) uint64 {
+	g := thumbnail(img, 8, 8)
+	var mean float64
+	for _, v := range g {
+		mean += v
+	}
+	mean /= float64(len(g))
+
+	var h uint64
+	for i, v := range g {
+		if v > mean {
+			h |= 1 << i
+		}
+	}
+	return h
+}
+
+func differenceHash(img image.Image) uint64 {
+	g := thumbnail(img, 9, 8)
+	var h uint64
+	for y := range 8 {
+		for x := range 8 {
+			if g[y*9+x] < g[y*9+x+1] {
+				h |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return h
+}
+
+func perceptualHash(img image.Image) uint64 {
+	const n, keep = 32, 8
+	g := thumbnail(img, n, n)
+
+	// Separable DCT-II, computing only the keep x keep lowest frequencies
+	var cos [keep][n]float64
+	for u := range keep {
+		for x := range n {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	var rows [n][keep]float64
+	for y := range n {
+		for u := range keep {
+			for x := range n {
+				rows[y][u] += g[y*n+x] * cos[u][x]
+			}
+		}
+	}
+	coeffs := make([]float64, 0, keep*keep)
+	for v := range keep {
+		for u := range keep {
+			var sum float64
+			for y := range n {
+				sum += rows[y][u] * cos[v][y]
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// The DC term is the overall brightness and would skew the median
+	sorted := slices.Clone(coeffs[1:])
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	var h uint64
+	for i, c := range coeffs {
+		if c > median {
+			h |= 1 << i
+		}
+	}
+	return h
+}
+
+// thumbnail shrinks img to a w x h grid of average luminances, row by row.
+// Large images are sampled rather than read pixel by pixel.
+func thumbnail(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	dx, dy := b.Dx(), b.Dy()
+	grid := make([]float64, w*h)
+	if dx == 0 || dy == 0 {
+		return grid
+	}
+	stepX, stepY := max(1, dx/(w*8)), max(1, dy/(h*8))
+	luma := lumaFunc(img)
+
+	for cy := range h {
+		y0 := b.Min.Y + cy*dy/h
+		y1 := max(y0+1, b.Min.Y+(cy+1)*dy/h)
+		for cx := range w {
+			x0 := b.Min.X + cx*dx/w
+			x1 := max(x0+1, b.Min.X+(cx+1)*dx/w)
+			var sum float64
+			var count int
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					sum += luma(x, y)
+					count++
+				}
+			}
+			grid[cy*w+cx] = sum / float64(count)
+		}
+	}
+	return grid
+}
+
+// lumaFunc returns a function reading the luminance of img's pixels,
+// avoiding a colour conversion per pixel for the common JPEG and gray models
+func lumaFunc(img image.Image) func(x, y int) float64 {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) float64 { return float64(m.Y[m.YOffset(x, y)]) }
+	case *image.Gray:
+		return func(x, y int) float64 { return float64(m.Pix[m.PixOffset(x, y)]) }
+	}
+	return func(x, y int) float64 {
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+}
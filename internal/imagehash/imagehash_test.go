@@ -0,0 +1,142 @@
+package imagehash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pattern draws a 16x16 grid of random gray blocks, the same for a seed
+// whatever the size
+func pattern(seed int64, w, h int) *image.RGBA {
+	rng := rand.New(rand.NewSource(seed))
+	var blocks [16][16]uint8
+	for y := range blocks {
+		for x := range blocks[y] {
+			blocks[y][x] = uint8(rng.Intn(256))
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := blocks[y*16/h][x*16/w]
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestHash_Similar(t *testing.T) {
+	original := pattern(1, 640, 480)
+	resized := pattern(1, 200, 150)
+	unrelated := pattern(2, 640, 480)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, original, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	reencoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, algo := range Algorithms {
+		h := Hash(original, algo)
+		if d := Distance(h, Hash(resized, algo)); d > DefaultDistance {
+			t.Errorf("%s: resized copy at distance %d", algo, d)
+		}
+		if d := Distance(h, Hash(reencoded, algo)); d > DefaultDistance {
+			t.Errorf("%s: re-encoded JPEG at distance %d", algo, d)
+		}
+		if d := Distance(h, Hash(unrelated, algo)); d <= DefaultDistance {
+			t.Errorf("%s: unrelated image at distance %d", algo, d)
+		}
+	}
+}
+
+func TestHash_Tiny(t *testing.T) {
+	// Images smaller than the thumbnail still hash without dividing by zero
+	img := pattern(1, 3, 2)
+	for _, algo := range Algorithms {
+		Hash(img, algo)
+	}
+	Hash(image.NewGray(image.Rect(0, 0, 0, 0)), Difference)
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, pattern(1, 64, 64))
+	f.Close()
+
+	h, err := File(path, Difference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != Hash(pattern(1, 64, 64), Difference) {
+		t.Errorf("File() = %x, want the hash of the encoded image", h)
+	}
+
+	notImage := filepath.Join(dir, "b.png")
+	os.WriteFile(notImage, []byte("not an image"), 0644)
+	if _, err := File(notImage, Difference); err == nil {
+		t.Error("File() expected error for undecodable file")
+	}
+}
+
+func TestFile_TooLarge(t *testing.T) {
+	// A 1x1 PNG whose header claims 10000x10000 pixels; File must refuse it
+	// from the header alone
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	b := buf.Bytes()
+	ihdr := b[12 : 12+4+13] // Chunk type and data, after the signature and length
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	binary.BigEndian.PutUint32(ihdr[8:], 10000)
+	binary.BigEndian.PutUint32(b[12+4+13:], crc32.ChecksumIEEE(ihdr))
+
+	path := filepath.Join(t.TempDir(), "huge.png")
+	os.WriteFile(path, b, 0644)
+	if _, err := File(path, Difference); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("File() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for name, want := range map[string]bool{
+		"photo.JPG": true,
+		"a.jpeg":    true,
+		"icon.png":  true,
+		"anim.gif":  true,
+		"raw.cr2":   false,
+		"noext":     false,
+	} {
+		if got := Supported(name); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, algo := range Algorithms {
+		if got, err := ParseAlgorithm(string(algo)); err != nil || got != algo {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v", algo, got, err)
+		}
+	}
+	if _, err := ParseAlgorithm("md5"); err == nil {
+		t.Error("ParseAlgorithm(md5) expected error")
+	}
+}
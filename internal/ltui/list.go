@@ -0,0 +1,264 @@
+package ltui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SortKey is an order the rows of a List can be shown in
+type SortKey int
+
+const (
+	SortDefault SortKey = iota // The order the items were given in
+	SortSize                   // Largest first
+	SortAge                    // Oldest first
+	SortPath                   // By full path
+	SortName                   // By file name
+)
+
+func (k SortKey) String() string {
+	switch k {
+	case SortSize:
+		return "size"
+	case SortAge:
+		return "age"
+	case SortPath:
+		return "path"
+	case SortName:
+		return "name"
+	}
+	return "default"
+}
+
+// Item is what a List knows of one row: enough to sort and filter it
+type Item struct {
+	Name    string // Matched by the filter and ordered by SortName
+	Path    string // Matched by the filter and ordered by SortPath
+	Size    int64
+	ModTime time.Time
+}
+
+// List is the state of a results list shared by the results screens: the
+// rows are sorted by Sort, narrowed by a filter typed after /, and paged
+// through with the cursor. A screen passes every key to Handle first and
+// prints the rows of Rows between the bounds of Window.
+type List struct {
+	Items   []Item
+	Sorts   []SortKey // Offered by s, in order
+	Sort    SortKey
+	Filter  string // A substring, or a glob such as *.dmg
+	Editing bool   // Keys are typed into Filter
+	Cursor  int    // Position in Rows
+	Page    int    // Rows on screen when last drawn, moved by PgUp and PgDn
+
+	// Only, when set, hides the items it returns false for
+	Only func(i int) bool
+	// Group, when set, orders items by its result before Sort
+	Group func(i int) int
+}
+
+// NewList returns a list of items sorted by the first of sorts
+func NewList(items []Item, sorts ...SortKey) *List {
+	l := &List{Items: items, Sorts: sorts, Page: 1}
+	if len(sorts) > 0 {
+		l.Sort = sorts[0]
+	}
+	return l
+}
+
+// Rows returns the indices into Items of the rows shown, in order
+func (l *List) Rows() []int {
+	var rows []int
+	for i, item := range l.Items {
+		if (l.Only == nil || l.Only(i)) && matches(item, l.Filter) {
+			rows = append(rows, i)
+		}
+	}
+	sort.SliceStable(rows, func(x, y int) bool {
+		i, j := rows[x], rows[y]
+		if l.Group != nil && l.Group(i) != l.Group(j) {
+			return l.Group(i) < l.Group(j)
+		}
+		a, b := l.Items[i], l.Items[j]
+		switch l.Sort {
+		case SortSize:
+			return a.Size > b.Size
+		case SortAge:
+			return a.ModTime.Before(b.ModTime)
+		case SortPath:
+			return a.Path < b.Path
+		case SortName:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+		return false
+	})
+	return rows
+}
+
+// Current returns the index into Items of the row under the cursor, or -1
+// when there are no rows
+func (l *List) Current() int {
+	rows := l.Rows()
+	if len(rows) == 0 {
+		return -1
+	}
+	return rows[min(l.Cursor, len(rows)-1)]
+}
+
+// Window returns the positions in rows of the first row on a screen height
+// lines tall and the one after the last, keeping the cursor in the middle.
+// The row at a position takes lines(pos) lines, or one when lines is nil.
+// Page becomes the number of rows shown.
+func (l *List) Window(rows []int, height int, lines func(pos int) int) (start, end int) {
+	start, end = scroll(l.Cursor, len(rows), height, lines)
+	l.Page = max(end-start, 1)
+	return start, end
+}
+
+// Handle applies a navigation, sort or filter key and reports whether key
+// was one. While the filter is typed every key is taken but Ctrl-C, which
+// drops the filter and is left to the screen to quit.
+func (l *List) Handle(key string) bool {
+	if l.Editing {
+		filter := l.Filter
+		switch key {
+		case "ctrl-c":
+			l.Editing = false
+			l.Filter = ""
+			l.Cursor = 0
+			return false
+		case "\r", "\n":
+			l.Editing = false
+		case "esc":
+			l.Editing = false
+			l.Filter = ""
+		case "backspace":
+			if l.Filter != "" {
+				_, size := utf8.DecodeLastRuneInString(l.Filter)
+				l.Filter = l.Filter[:len(l.Filter)-size]
+			}
+		default:
+			if l.move(key) {
+				return true
+			}
+			if utf8.RuneCountInString(key) == 1 && key >= " " {
+				l.Filter += key
+			}
+		}
+		// Other keys, such as "resize", keep the selection
+		if l.Filter != filter {
+			l.Cursor = 0
+		}
+		return true
+	}
+
+	switch key {
+	case "/":
+		l.Editing = true
+	case "esc":
+		if l.Filter == "" {
+			return false
+		}
+		l.Filter = ""
+		l.Cursor = 0
+	case "s", "S":
+		if len(l.Sorts) == 0 {
+			return false
+		}
+		i := slices.Index(l.Sorts, l.Sort)
+		l.Sort = l.Sorts[(i+1)%len(l.Sorts)]
+		l.Cursor = 0
+	default:
+		return l.move(key)
+	}
+	return true
+}
+
+// move applies a navigation key
+func (l *List) move(key string) bool {
+	last := len(l.Rows()) - 1
+	switch key {
+	case "up":
+		l.Cursor--
+	case "down":
+		l.Cursor++
+	case "pgup":
+		l.Cursor -= l.Page
+	case "pgdn":
+		l.Cursor += l.Page
+	case "home":
+		l.Cursor = 0
+	case "end":
+		l.Cursor = last
+	default:
+		return false
+	}
+	l.Cursor = max(min(l.Cursor, last), 0)
+	return true
+}
+
+// matches reports whether item matches filter, ignoring case: a substring
+// of its name or path, or a glob matched against its name, or when the glob
+// holds a / against the end of its path, e.g. Downloads/*.dmg
+func matches(item Item, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	filter = strings.ToLower(filter)
+	name, path := strings.ToLower(item.Name), strings.ToLower(item.Path)
+	if !strings.ContainsAny(filter, "*?[") {
+		return strings.Contains(name, filter) || strings.Contains(path, filter)
+	}
+	if !strings.Contains(filter, "/") {
+		ok, _ := filepath.Match(filter, name)
+		return ok
+	}
+	for {
+		if ok, _ := filepath.Match(filter, path); ok {
+			return true
+		}
+		i := strings.Index(path, "/")
+		if i < 0 {
+			return false
+		}
+		path = path[i+1:]
+	}
+}
+
+// printListBar prints how l is sorted and filtered, with a cursor while the
+// filter is typed
+func (t *Terminal) printListBar(l *List) {
+	fmt.Printf("  Sort: %s", l.Sort)
+	switch {
+	case l.Editing:
+		fmt.Print("  ")
+		t.PrintColored("cyan", "/"+l.Filter+"_")
+	case l.Filter != "":
+		fmt.Printf("  Filter: %s", l.Filter)
+	}
+	fmt.Println()
+	fmt.Println()
+}
+
+// printListPosition prints which of the rows are on screen, or that none
+// match the filter
+func (t *Terminal) printListPosition(l *List, rows []int, start, end int, noun string) {
+	switch {
+	case len(rows) == 0 && l.Filter != "":
+		t.PrintColored("gray", fmt.Sprintf("  No %s match %q", noun, l.Filter))
+		fmt.Println()
+	case len(rows) > l.Page || l.Filter != "":
+		fmt.Printf("\n  Showing %d-%d of %d %s\n", start+1, end, len(rows), noun)
+	}
+}
+
+// listKeys is the help for the keys Handle takes
+const listKeys = "[↑↓/PgUp/PgDn] Navigate  [s] Sort  [/] Filter"
+
+// listLines is how many lines printListBar and printListPosition take
+const listLines = 4
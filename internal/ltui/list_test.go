@@ -0,0 +1,285 @@
+package ltui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testItems are four files in no particular order of size, age, path or
+// name
+func testItems() []Item {
+	day := 24 * time.Hour
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	return []Item{
+		{Name: "b.dmg", Path: "/Users/me/Downloads/b.dmg", Size: 300, ModTime: now.Add(-2 * day)},
+		{Name: "C.mov", Path: "/Users/me/Movies/C.mov", Size: 400, ModTime: now.Add(-1 * day)},
+		{Name: "a.zip", Path: "/Users/me/Desktop/a.zip", Size: 100, ModTime: now.Add(-4 * day)},
+		{Name: "d.dmg", Path: "/Users/me/Desktop/d.dmg", Size: 200, ModTime: now.Add(-3 * day)},
+	}
+}
+
+// names returns the names of the rows of l, in order
+func names(l *List) []string {
+	var got []string
+	for _, i := range l.Rows() {
+		got = append(got, l.Items[i].Name)
+	}
+	return got
+}
+
+func TestListRows_Sort(t *testing.T) {
+	for _, tt := range []struct {
+		sort SortKey
+		want []string
+	}{
+		{SortSize, []string{"C.mov", "b.dmg", "d.dmg", "a.zip"}},
+		{SortAge, []string{"a.zip", "d.dmg", "b.dmg", "C.mov"}},
+		{SortPath, []string{"a.zip", "d.dmg", "b.dmg", "C.mov"}},
+		{SortName, []string{"a.zip", "b.dmg", "C.mov", "d.dmg"}},
+	} {
+		// The order the items come in must not matter
+		items := testItems()
+		if got := names(NewList(items, tt.sort)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Rows() = %v, want %v", tt.sort, got, tt.want)
+		}
+		slices.Reverse(items)
+		if got := names(NewList(items, tt.sort)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s, reversed input: Rows() = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+
+	items := testItems()
+	if got, want := names(NewList(items, SortDefault)), []string{"b.dmg", "C.mov", "a.zip", "d.dmg"}; !slices.Equal(got, want) {
+		t.Errorf("default: Rows() = %v, want the given order %v", got, want)
+	}
+	slices.Reverse(items)
+	if got, want := names(NewList(items, SortDefault)), []string{"d.dmg", "a.zip", "C.mov", "b.dmg"}; !slices.Equal(got, want) {
+		t.Errorf("default, reversed input: Rows() = %v, want the given order %v", got, want)
+	}
+}
+
+func TestListHandle_CycleSort(t *testing.T) {
+	l := NewList(testItems(), SortSize, SortAge, SortName)
+	l.Cursor = 2
+	for _, want := range []SortKey{SortAge, SortName, SortSize} {
+		if !l.Handle("s") || l.Sort != want || l.Cursor != 0 {
+			t.Fatalf("after s: Sort = %s, Cursor = %d; want %s and 0", l.Sort, l.Cursor, want)
+		}
+	}
+	if NewList(testItems()).Handle("s") {
+		t.Error("Handle(s) took the key for a list without sorts")
+	}
+}
+
+func TestListRows_Filter(t *testing.T) {
+	for _, tt := range []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"b.dmg", "C.mov", "a.zip", "d.dmg"}},
+		{"DMG", []string{"b.dmg", "d.dmg"}},         // Substring of the name, any case
+		{"desktop", []string{"a.zip", "d.dmg"}},     // Substring of the path
+		{"*.dmg", []string{"b.dmg", "d.dmg"}},       // Glob against the name
+		{"c.*", []string{"C.mov"}},                  // Glob ignores case
+		{"Downloads/*.dmg", []string{"b.dmg"}},      // Glob against the end of the path
+		{"*/desktop/*", []string{"a.zip", "d.dmg"}}, // Glob across several directories
+		{"/users/me/movies/*", []string{"C.mov"}},   // Glob against the whole path
+		{"*.pkg", nil},                                      // No match
+		{"Downloads/*.zip", nil},                            // Directory matches, name does not
+		{"[ab].*", []string{"b.dmg", "a.zip"}},              // Character class
+		{"me/Desktop", []string{"a.zip", "d.dmg"}},          // Substring with a /
+		{"?.mov", []string{"C.mov"}},                        // Single character wildcard
+		{"Desktop/*", []string{"a.zip", "d.dmg"}},           // Every file in a directory
+		{"Users/*/Downloads/*", []string{"b.dmg"}},          // Wildcard directory
+		{"nothing", nil},                                    // Substring matching nothing
+		{"*", []string{"b.dmg", "C.mov", "a.zip", "d.dmg"}}, // Everything
+	} {
+		l := NewList(testItems(), SortDefault)
+		l.Filter = tt.filter
+		if got := names(l); !slices.Equal(got, tt.want) {
+			t.Errorf("Filter %q: Rows() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestListRows_OnlyAndGroup(t *testing.T) {
+	l := NewList(testItems(), SortSize)
+	l.Only = func(i int) bool { return l.Items[i].Size >= 200 }
+	if got, want := names(l), []string{"C.mov", "b.dmg", "d.dmg"}; !slices.Equal(got, want) {
+		t.Errorf("Only: Rows() = %v, want %v", got, want)
+	}
+
+	// Disk images first, the rest after; Sort orders within each group
+	l.Only = nil
+	l.Group = func(i int) int {
+		if strings.HasSuffix(l.Items[i].Name, ".dmg") {
+			return 0
+		}
+		return 1
+	}
+	if got, want := names(l), []string{"b.dmg", "d.dmg", "C.mov", "a.zip"}; !slices.Equal(got, want) {
+		t.Errorf("Group: Rows() = %v, want %v", got, want)
+	}
+
+	// The filter applies on top of both
+	l.Only = func(i int) bool { return l.Items[i].Size != 300 }
+	l.Filter = "desktop"
+	if got, want := names(l), []string{"d.dmg", "a.zip"}; !slices.Equal(got, want) {
+		t.Errorf("Only, Group and Filter: Rows() = %v, want %v", got, want)
+	}
+}
+
+func TestListHandle_Filter(t *testing.T) {
+	l := NewList(testItems(), SortDefault)
+	l.Cursor = 3
+	for _, key := range []string{"/", "d", "m", "q"} {
+		if !l.Handle(key) {
+			t.Fatalf("Handle(%q) = false while filtering", key)
+		}
+	}
+	if !l.Editing || l.Filter != "dmq" || l.Cursor != 0 {
+		t.Fatalf("Editing = %v, Filter = %q, Cursor = %d; want a typed q and the cursor reset", l.Editing, l.Filter, l.Cursor)
+	}
+	l.Handle("backspace")
+	l.Handle("g")
+	l.Handle("\r")
+	if l.Editing || l.Filter != "dmg" {
+		t.Fatalf("after Enter: Editing = %v, Filter = %q; want the filter kept", l.Editing, l.Filter)
+	}
+
+	// Keys that do not change the filter keep the cursor
+	l.Handle("down")
+	l.Handle("/")
+	if !l.Handle("resize") || l.Cursor != 1 || l.Filter != "dmg" {
+		t.Errorf("after resize: Cursor = %d, Filter = %q; want 1 and dmg", l.Cursor, l.Filter)
+	}
+
+	// Esc while typing drops the filter, as does Esc afterwards
+	l.Handle("esc")
+	if l.Editing || l.Filter != "" {
+		t.Errorf("after esc: Editing = %v, Filter = %q; want both cleared", l.Editing, l.Filter)
+	}
+	l.Filter = "dmg"
+	if !l.Handle("esc") || l.Filter != "" {
+		t.Errorf("esc did not clear the filter %q", l.Filter)
+	}
+	if l.Handle("esc") {
+		t.Error("esc without a filter was taken from the screen")
+	}
+
+	// Ctrl-C drops the filter and is left to the screen to quit
+	l.Handle("/")
+	l.Handle("x")
+	if l.Handle("ctrl-c") || l.Editing || l.Filter != "" {
+		t.Errorf("ctrl-c: Editing = %v, Filter = %q; want it passed on with the filter dropped", l.Editing, l.Filter)
+	}
+}
+
+func TestListHandle_Move(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		items  int
+		page   int
+		cursor int
+		keys   []string
+		want   int
+	}{
+		{"empty", 0, 1, 0, []string{"down", "pgdn", "end", "up"}, 0},
+		{"empty end", 0, 1, 0, []string{"end"}, 0},
+		{"one item down", 1, 1, 0, []string{"down", "down"}, 0},
+		{"one item pgdn", 1, 10, 0, []string{"pgdn"}, 0},
+		{"one item end", 1, 1, 0, []string{"end"}, 0},
+		{"down", 4, 2, 0, []string{"down"}, 1},
+		{"up at top", 4, 2, 0, []string{"up"}, 0},
+		{"down at bottom", 4, 2, 3, []string{"down"}, 3},
+		{"pgdn", 4, 2, 0, []string{"pgdn"}, 2},
+		{"pgdn past the end", 4, 2, 2, []string{"pgdn", "pgdn"}, 3},
+		{"pgdn larger than the list", 4, 10, 0, []string{"pgdn"}, 3},
+		{"pgup past the start", 4, 2, 1, []string{"pgup"}, 0},
+		{"end", 4, 2, 0, []string{"end"}, 3},
+		{"home", 4, 2, 3, []string{"home"}, 0},
+	} {
+		l := NewList(testItems()[:tt.items], SortDefault)
+		l.Page, l.Cursor = tt.page, tt.cursor
+		for _, key := range tt.keys {
+			if !l.Handle(key) {
+				t.Fatalf("%s: Handle(%q) = false", tt.name, key)
+			}
+		}
+		if l.Cursor != tt.want {
+			t.Errorf("%s: Cursor = %d, want %d", tt.name, l.Cursor, tt.want)
+		}
+	}
+}
+
+func TestListCurrent(t *testing.T) {
+	if got := NewList(nil, SortSize).Current(); got != -1 {
+		t.Errorf("empty: Current() = %d, want -1", got)
+	}
+	l := NewList(testItems(), SortSize)
+	l.Cursor = 1
+	if got := l.Current(); got != 0 {
+		t.Errorf("Current() = %d, want 0 (b.dmg, the second largest)", got)
+	}
+	// A filter leaving fewer rows than the cursor clamps it
+	l.Filter = "mov"
+	if got := l.Current(); got != 1 {
+		t.Errorf("filtered: Current() = %d, want 1", got)
+	}
+}
+
+func TestListWindow(t *testing.T) {
+	l := NewList(nil, SortDefault)
+	if start, end := l.Window(nil, 10, nil); start != 0 || end != 0 || l.Page != 1 {
+		t.Errorf("empty: Window() = %d, %d with Page %d; want 0, 0 and 1", start, end, l.Page)
+	}
+
+	l = NewList(testItems()[:1], SortDefault)
+	if start, end := l.Window(l.Rows(), 10, nil); start != 0 || end != 1 || l.Page != 1 {
+		t.Errorf("one item: Window() = %d, %d with Page %d; want 0, 1 and 1", start, end, l.Page)
+	}
+
+	rows := make([]int, 100)
+	l = NewList(make([]Item, 100), SortDefault)
+	l.Cursor = 50
+	start, end := l.Window(rows, 10, nil)
+	if end-start != 10 || l.Cursor < start || l.Cursor >= end || l.Page != 10 {
+		t.Errorf("Window() = %d, %d with Page %d; want 10 rows around the cursor", start, end, l.Page)
+	}
+	l.Handle("pgdn")
+	if l.Cursor != 60 {
+		t.Errorf("pgdn moved the cursor to %d, want 60", l.Cursor)
+	}
+}
+
+func TestScroll(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		cursor, n, height  int
+		lines              func(int) int
+		wantStart, wantEnd int
+	}{
+		{"empty", 0, 0, 10, nil, 0, 0},
+		{"fits", 2, 5, 10, nil, 0, 5},
+		{"top", 0, 100, 10, nil, 0, 10},
+		{"bottom", 99, 100, 10, nil, 90, 100},
+		{"cursor past the end", 150, 100, 10, nil, 90, 100},
+		{"negative cursor", -5, 100, 10, nil, 0, 10},
+		{"no room", 5, 100, 0, nil, 5, 6},
+		{"tall rows", 0, 10, 10, func(int) int { return 3 }, 0, 3},
+		{"cursor row taller than the screen", 4, 10, 2, func(i int) int { return 5 }, 4, 5},
+	} {
+		start, end := scroll(tt.cursor, tt.n, tt.height, tt.lines)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: scroll() = %d, %d; want %d, %d", tt.name, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+
+	// In the middle the cursor is shown with rows on either side
+	start, end := scroll(50, 100, 11, nil)
+	if end-start != 11 || start > 45 || end < 55 {
+		t.Errorf("middle: scroll() = %d, %d; want 11 rows around 50", start, end)
+	}
+}
@@ -3,32 +3,61 @@
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"golang.org/x/sys/unix"
+
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/history"
 	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/scope"
+	"macos-cleaner/internal/usage"
+	"macos-cleaner/internal/utils"
 )
 
 // Terminal provides simple terminal UI functionality
 type Terminal struct {
-	Width  int
-	Height int
+	Width  int // Columns, kept current as the window is resized
+	Height int // Rows
+
+	resized atomic.Bool // Set on SIGWINCH until the size is read again
 }
 
-// NewTerminal creates a new terminal UI
+// NewTerminal creates a new terminal UI sized to the window, or 80x24 when
+// stdout is not a terminal
 func NewTerminal() *Terminal {
-	return &Terminal{
+	t := &Terminal{
 		Width:  80,
 		Height: 24,
 	}
+	t.updateSize()
+	sigs := make(chan os.Signal, 1)
+	notifyResize(sigs)
+	go func() {
+		for range sigs {
+			t.resized.Store(true)
+		}
+	}()
+	return t
 }
 
-// Clear clears the terminal screen
+// Clear clears the terminal screen, reading the window size first if it
+// has changed
 func (t *Terminal) Clear() {
+	if t.resized.Swap(false) {
+		t.updateSize()
+	}
 	if runtime.GOOS == "windows" {
 		cmd := exec.Command("cmd", "/c", "cls")
 		cmd.Stdout = os.Stdout
@@ -101,7 +130,7 @@ func (t *Terminal) PrintTitle(title string) {
 }
 
 // PrintMenu prints the main menu
-func (t *Terminal) PrintMenu() string {
+func (t *Terminal) PrintMenu(useTrash bool) string {
 	t.Clear()
 	t.PrintTitle("macOS Storage Cleaner")
 
@@ -110,8 +139,19 @@ func (t *Terminal) PrintMenu() string {
 	fmt.Println("  [2] 📦 Big Files Finder - Find large files taking up space")
 	fmt.Println("  [3] 🔁 Duplicate Finder - Find duplicate files")
 	fmt.Println("  [4] 📅 Old Files Finder - Find files not accessed recently")
+	fmt.Println("  [5] 🌳 Disk Usage - Explore where space is going")
+	fmt.Println("  [h] 📈 History - Space reclaimed per target over time")
+	fmt.Println("  [s] 🔭 Scan Scope - Where the finders search and what they skip")
 	fmt.Println()
-	t.PrintColored("gray", "  Press 1-4 to select, q to quit")
+	fmt.Print("  [t] Deletion mode: ")
+	if useTrash {
+		t.PrintColored("green", "move to quarantine (restorable)")
+	} else {
+		t.PrintColored("yellow", "delete permanently")
+	}
+	fmt.Println()
+	fmt.Println()
+	t.PrintColored("gray", "  Press 1-5, h or s to select, t to toggle deletion mode, q to quit")
 	fmt.Println()
 
 	return t.ReadKey()
@@ -122,8 +162,17 @@ func (t *Terminal) PrintTargets(targets []models.CleanupTarget, cursor int) stri
 	t.Clear()
 	t.PrintTitle("Storage Cleanup")
 
+	// A target starting a category is headed by a blank line and its name
+	help := "  [↑↓] Navigate  [Space] Toggle  [a] All  [n] None  [s] Scan  [b] Back  [q] Quit"
+	start, end := scroll(cursor, len(targets), t.Height-8-t.lines(help), func(i int) int {
+		if i == 0 || targets[i].Category != targets[i-1].Category {
+			return 3
+		}
+		return 1
+	})
 	currentCategory := ""
-	for i, target := range targets {
+	for i := start; i < end; i++ {
+		target := targets[i]
 		if target.Category != currentCategory {
 			currentCategory = target.Category
 			fmt.Println()
@@ -147,11 +196,123 @@ func (t *Terminal) PrintTargets(targets []models.CleanupTarget, cursor int) stri
 			fmt.Print(checked)
 		}
 
-		fmt.Printf(" %-28s %s\n", target.Name, target.Description)
+		fmt.Printf(" %-28s %s\n", target.Name, fitName(target.Description, max(t.Width-35, minPathWidth)))
+	}
+	if end-start < len(targets) {
+		fmt.Printf("\n  Showing %d-%d of %d targets\n", start+1, end, len(targets))
 	}
 
 	fmt.Println()
-	t.PrintColored("gray", "  [↑↓] Navigate  [Space] Toggle  [a] All  [n] None  [s] Scan  [b] Back  [q] Quit")
+	t.PrintColored("gray", help)
+	fmt.Println()
+
+	return t.readKeyOrResize()
+}
+
+// Keys that cancel a running operation
+const (
+	keyCtrlC = 3
+	keyEsc   = 27
+)
+
+// CancelWatcher cancels an operation on Esc, Ctrl-C, SIGINT or SIGTERM
+type CancelWatcher struct {
+	cancel context.CancelFunc
+	sigs   chan os.Signal
+
+	done     chan struct{}
+	finished sync.WaitGroup
+	oldState *unix.Termios
+}
+
+// WatchCancel calls cancel when Esc or Ctrl-C is pressed or SIGINT/SIGTERM
+// arrives, until Stop is called. Stop restores the terminal state, so it must
+// be called before reading keys again.
+func (t *Terminal) WatchCancel(cancel context.CancelFunc) *CancelWatcher {
+	w := &CancelWatcher{cancel: cancel, sigs: make(chan os.Signal, 1)}
+	signal.Notify(w.sigs, os.Interrupt, syscall.SIGTERM)
+	w.start()
+	return w
+}
+
+// Stop stops watching and restores the terminal
+func (w *CancelWatcher) Stop() {
+	w.stop()
+	signal.Stop(w.sigs)
+}
+
+// Suspend releases the terminal while fn runs, e.g. for a password prompt.
+// Signals still cancel in the meantime.
+func (w *CancelWatcher) Suspend(fn func() error) error {
+	w.stop()
+	defer w.start()
+	return fn()
+}
+
+// start puts the terminal in raw mode and watches keys and signals
+func (w *CancelWatcher) start() {
+	w.done = make(chan struct{})
+	done := w.done
+
+	w.finished.Add(1)
+	go func() {
+		defer w.finished.Done()
+		select {
+		case <-w.sigs:
+			w.cancel()
+		case <-done:
+		}
+	}()
+
+	// Without a terminal (e.g. piped stdin) only signals can cancel
+	oldState, err := makeRaw(os.Stdin)
+	if err != nil {
+		w.oldState = nil
+		return
+	}
+	w.oldState = oldState
+
+	w.finished.Add(1)
+	go func() {
+		defer w.finished.Done()
+		buf := make([]byte, 16)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if !waitReadable(os.Stdin, 100*time.Millisecond) {
+				continue
+			}
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			// A lone Esc cancels; arrow keys arrive as longer escape sequences
+			if n > 0 && (buf[0] == keyCtrlC || (buf[0] == keyEsc && n == 1)) {
+				w.cancel()
+			}
+		}
+	}()
+}
+
+// stop ends the watching goroutines and leaves raw mode
+func (w *CancelWatcher) stop() {
+	close(w.done)
+	w.finished.Wait()
+	restoreTerminal(os.Stdin, w.oldState)
+}
+
+// PrintCancelled tells the user an operation was cancelled and what is shown next
+func (t *Terminal) PrintCancelled(message string) string {
+	t.Clear()
+	t.PrintTitle("Cancelled")
+
+	t.PrintColored("yellow", "  ⚠️  "+message)
+	fmt.Println()
+	fmt.Println()
+	t.PrintColored("gray", "  Press any key to continue")
 	fmt.Println()
 
 	return t.ReadKey()
@@ -163,10 +324,14 @@ func (t *Terminal) PrintScanning(status string) {
 	t.PrintTitle("Scanning...")
 	fmt.Println()
 	fmt.Printf("  %s\n", status)
+	fmt.Println()
+	t.PrintColored("gray", "  [Esc] Cancel")
+	fmt.Println()
 }
 
-// PrintResults prints scan results
-func (t *Terminal) PrintResults(targets []models.CleanupTarget, cursor int) string {
+// PrintResults prints scan results as the rows of list, one item per
+// target. Targets are headed by their category in the default order.
+func (t *Terminal) PrintResults(targets []models.CleanupTarget, list *List) string {
 	t.Clear()
 	t.PrintTitle("Scan Results")
 
@@ -178,20 +343,32 @@ func (t *Terminal) PrintResults(targets []models.CleanupTarget, cursor int) stri
 	}
 
 	fmt.Printf("  Total potential savings: ")
-	t.PrintColored("yellow", formatBytes(totalSize))
+	t.PrintColored("yellow", utils.FormatBytes(totalSize))
 	fmt.Println()
 	fmt.Println()
-
+	t.printListBar(list)
+
+	// In the default order a target starting a category is headed by it
+	help := "  " + listKeys + "  [Space] Toggle  [c] Clean  [r] Rescan  [b] Back  [q] Quit"
+	rows := list.Rows()
+	start, end := list.Window(rows, t.Height-10-listLines-t.lines(help), func(pos int) int {
+		if list.Sort == SortDefault && (pos == 0 || targets[rows[pos]].Category != targets[rows[pos-1]].Category) {
+			return 2
+		}
+		return 1
+	})
 	currentCategory := ""
-	for i, target := range targets {
-		if target.Category != currentCategory {
+	for pos := start; pos < end; pos++ {
+		i := rows[pos]
+		target := targets[i]
+		if list.Sort == SortDefault && target.Category != currentCategory {
 			currentCategory = target.Category
 			t.PrintColored("magenta", "  "+currentCategory+":")
 			fmt.Println()
 		}
 
 		cursorStr := "  "
-		if cursor == i {
+		if list.Cursor == pos {
 			cursorStr = "> "
 		}
 
@@ -200,7 +377,7 @@ func (t *Terminal) PrintResults(targets []models.CleanupTarget, cursor int) stri
 			checked = "[✓]"
 		}
 
-		sizeStr := formatBytes(target.Size)
+		sizeStr := utils.FormatBytes(target.Size)
 		if target.Size == 0 {
 			sizeStr = "Empty"
 		}
@@ -210,23 +387,24 @@ func (t *Terminal) PrintResults(targets []models.CleanupTarget, cursor int) stri
 			status = "⚠ sudo"
 		}
 
-		if cursor == i {
+		if list.Cursor == pos {
 			t.PrintColored("cyan", cursorStr+checked)
 		} else {
 			fmt.Print(cursorStr + checked)
 		}
 		fmt.Printf(" %-28s %10s %s\n", target.Name, sizeStr, status)
 	}
+	t.printListPosition(list, rows, start, end, "targets")
 
 	fmt.Println()
-	t.PrintColored("gray", "  [↑↓] Navigate  [Space] Toggle  [c] Clean  [r] Rescan  [b] Back  [q] Quit")
+	t.PrintColored("gray", help)
 	fmt.Println()
 
-	return t.ReadKey()
+	return t.readKeyOrResize()
 }
 
 // PrintConfirm prints confirmation dialog
-func (t *Terminal) PrintConfirm(targets []models.CleanupTarget) string {
+func (t *Terminal) PrintConfirm(targets []models.CleanupTarget, useTrash bool) string {
 	t.Clear()
 	t.PrintTitle("Confirm Cleanup")
 
@@ -236,30 +414,313 @@ func (t *Terminal) PrintConfirm(targets []models.CleanupTarget) string {
 	for _, target := range targets {
 		if target.Selected && target.Size > 0 {
 			totalSize += target.Size
-			fmt.Printf("    • %s (%s)\n", target.Name, formatBytes(target.Size))
+			fmt.Printf("    • %s (%s)\n", target.Name, utils.FormatBytes(target.Size))
 		}
 	}
 
 	fmt.Println()
 	fmt.Printf("  Total: ")
-	t.PrintColored("yellow", formatBytes(totalSize))
+	t.PrintColored("yellow", utils.FormatBytes(totalSize))
 	fmt.Println()
 	fmt.Println()
-	t.PrintColored("red", "  ⚠ This action cannot be undone!")
+	if useTrash {
+		t.PrintColored("green", "  ♻ Items will be moved to the quarantine (see: macos-cleaner restore --list)")
+	} else {
+		t.PrintColored("red", "  ⚠ This action cannot be undone!")
+	}
 	fmt.Println()
 	fmt.Println()
-	t.PrintColored("gray", "  [y] Yes, delete  [n] Cancel")
+	t.PrintColored("gray", "  [y] Yes, delete  [p] Preview files  [n] Cancel")
 	fmt.Println()
 
 	return t.ReadKey()
 }
 
+// PrintPreview prints the paths a dry run would delete
+func (t *Terminal) PrintPreview(plan []models.PlannedDeletion, cursor int) string {
+	t.Clear()
+	t.PrintTitle("Preview (dry run)")
+
+	if len(plan) == 0 {
+		t.PrintColored("green", "  Nothing would be deleted.")
+		fmt.Println()
+		fmt.Println()
+		t.PrintColored("gray", "  [b] Back  [q] Quit")
+		fmt.Println()
+		return t.ReadKey()
+	}
+
+	var totalSize int64
+	for _, p := range plan {
+		totalSize += p.Size
+	}
+
+	fmt.Printf("  %d paths would be deleted (", len(plan))
+	t.PrintColored("yellow", utils.FormatBytes(totalSize))
+	fmt.Println("):")
+	fmt.Println()
+
+	help := "  [↑↓] Navigate  [b] Back  [q] Quit"
+	start, end := scroll(cursor, len(plan), t.Height-10-t.lines(help), nil)
+	for i := start; i < end; i++ {
+		p := plan[i]
+		cursorStr := "  "
+		if cursor == i {
+			cursorStr = "> "
+		}
+
+		path := p.Path
+		if p.Command != "" {
+			path = "$ " + p.Command
+		}
+		shortPath := t.fitPath(path, 20)
+
+		sudo := "    "
+		if p.Sudo {
+			sudo = "sudo"
+		}
+
+		if cursor == i {
+			t.PrintColored("cyan", cursorStr)
+		} else {
+			fmt.Print(cursorStr)
+		}
+		fmt.Printf("%10s  %s  %s\n", utils.FormatBytes(p.Size), sudo, shortPath)
+	}
+
+	if end-start < len(plan) {
+		fmt.Printf("\n  Showing %d-%d of %d paths\n", start+1, end, len(plan))
+	}
+
+	if cursor >= 0 && cursor < len(plan) {
+		fmt.Println()
+		t.PrintColored("gray", "  Reason: "+plan[cursor].Reason)
+		fmt.Println()
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", help)
+	fmt.Println()
+
+	return t.readKeyOrResize()
+}
+
+// PrintHistory prints the per-target cleanup history, fastest-regrowing first
+func (t *Terminal) PrintHistory(summaries []history.TargetSummary, cursor int) string {
+	t.Clear()
+	t.PrintTitle("Cleanup History")
+
+	if len(summaries) == 0 {
+		t.PrintColored("green", "  Nothing has been cleaned yet.")
+		fmt.Println()
+		fmt.Println()
+		t.PrintColored("gray", "  [b] Back  [q] Quit")
+		fmt.Println()
+		return t.ReadKey()
+	}
+
+	var totalFreed int64
+	for _, s := range summaries {
+		totalFreed += s.TotalFreed
+	}
+
+	fmt.Print("  Total reclaimed: ")
+	t.PrintColored("yellow", utils.FormatBytes(totalFreed))
+	fmt.Println()
+	fmt.Println()
+	t.PrintColored("gray", fmt.Sprintf("  %-30s %5s %10s %12s", "Target", "Runs", "Freed", "Regrowth/day"))
+	fmt.Println()
+
+	help := "  [↑↓] Navigate  [b] Back  [q] Quit"
+	start, end := scroll(cursor, len(summaries), t.Height-11-t.lines(help), nil)
+	for i := start; i < end; i++ {
+		s := summaries[i]
+		cursorStr := "  "
+		if cursor == i {
+			cursorStr = "> "
+		}
+
+		name := fitName(s.Target, 30)
+
+		regrowth := "-"
+		if s.RegrowthPerDay > 0 {
+			regrowth = utils.FormatBytes(s.RegrowthPerDay)
+		}
+
+		if cursor == i {
+			t.PrintColored("cyan", cursorStr)
+		} else {
+			fmt.Print(cursorStr)
+		}
+		fmt.Printf("%-30s %5d %10s %12s\n", name, s.Runs, utils.FormatBytes(s.TotalFreed), regrowth)
+	}
+
+	if end-start < len(summaries) {
+		fmt.Printf("\n  Showing %d-%d of %d targets\n", start+1, end, len(summaries))
+	}
+
+	if cursor >= 0 && cursor < len(summaries) {
+		s := summaries[cursor]
+		fmt.Println()
+		t.PrintColored("gray", fmt.Sprintf("  Last run %s freed %s", s.LastRun.Format(time.DateOnly), utils.FormatBytes(s.LastFreed)))
+		if s.Failures > 0 {
+			t.PrintColored("red", fmt.Sprintf("  (%d failed runs)", s.Failures))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", help)
+	fmt.Println()
+
+	return t.readKeyOrResize()
+}
+
+// PrintScope prints the finders' scan scope. The cursor moves over the
+// roots followed by the exclude patterns; message is shown in red when set.
+func (t *Terminal) PrintScope(s scope.Scope, cursor int, message string) string {
+	t.Clear()
+	t.PrintTitle("Scan Scope")
+
+	item := func(i int, text string) {
+		if cursor == i {
+			t.PrintColored("cyan", "  > ")
+			fmt.Println(text)
+		} else {
+			fmt.Printf("    %s\n", text)
+		}
+	}
+
+	t.PrintColored("green", "  Roots\n")
+	if len(s.Roots) == 0 {
+		t.PrintColored("gray", "    (each finder's default directories)\n")
+	}
+	for i, root := range s.Roots {
+		item(i, root)
+	}
+	fmt.Println()
+
+	t.PrintColored("green", "  Exclude\n")
+	if len(s.Exclude) == 0 {
+		t.PrintColored("gray", "    (nothing)\n")
+	}
+	for i, pattern := range s.Exclude {
+		item(len(s.Roots)+i, pattern)
+	}
+	fmt.Println()
+
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	fmt.Printf("  [.] Hidden files: %s\n", onOff(s.Hidden))
+	fmt.Printf("  [i] %s files: %s\n", scope.IgnoreFileName, onOff(s.IgnoreFiles))
+
+	if message != "" {
+		fmt.Println()
+		t.PrintColored("red", "  "+message)
+		fmt.Println()
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", "  Changes last until you quit; add a \"scan\" section to the config file to keep them")
+	fmt.Println()
+	t.PrintColored("gray", "  [↑↓] Navigate  [r] Add root  [e] Add exclude  [d] Remove  [b] Back  [q] Quit")
+	fmt.Println()
+
+	return t.ReadKey()
+}
+
+// ReadLine prints prompt and reads a line of input with echo on
+func (t *Terminal) ReadLine(prompt string) string {
+	t.ShowCursor()
+	defer t.HideCursor()
+
+	fmt.Println()
+	t.PrintColored("cyan", "  "+prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// PrintUsage prints the children of dir, sorted by key, with a bar showing
+// each entry's share of dir. Entries whose path is in marked are checked.
+func (t *Terminal) PrintUsage(dir *usage.Node, children []*usage.Node, marked map[string]*usage.Node, cursor int, key usage.SortKey) string {
+	t.Clear()
+	t.PrintTitle("Disk Usage")
+
+	shortDir := t.fitPath(dir.Path, 50)
+	fmt.Printf("  %s  ", shortDir)
+	t.PrintColored("yellow", utils.FormatBytes(dir.Size))
+	fmt.Printf(" in %d files (sorted by %s)\n\n", dir.Count, key)
+
+	if len(children) == 0 {
+		t.PrintColored("gray", "  (empty)")
+		fmt.Println()
+	}
+
+	help := "  [↑↓] Navigate  [→/Enter] Open  [←] Up  [s] Sort  [Space] Mark  [r] Refresh  [p] Preview  [d] Delete  [b] Back  [q] Quit"
+	start, end := scroll(cursor, len(children), t.Height-11-t.lines(help), nil)
+	for i := start; i < end; i++ {
+		node := children[i]
+		cursorStr := "  "
+		if cursor == i {
+			cursorStr = "> "
+		}
+
+		checked := "[ ]"
+		if marked[node.Path] != nil {
+			checked = "[✓]"
+		}
+
+		const barWidth = 10
+		filled := 0
+		if dir.Size > 0 {
+			filled = int(node.Size * barWidth / dir.Size)
+		}
+		bar := strings.Repeat("#", filled) + strings.Repeat(" ", barWidth-filled)
+
+		name := node.Name
+		if node.IsDir {
+			name += "/"
+		}
+		name = fitName(name, max(t.Width-52, minPathWidth))
+
+		if cursor == i {
+			t.PrintColored("cyan", cursorStr+checked)
+		} else if marked[node.Path] != nil {
+			t.PrintColored("green", cursorStr+checked)
+		} else {
+			fmt.Print(cursorStr + checked)
+		}
+		fmt.Printf(" %10s [%s] %7d  %s  %s\n", utils.FormatBytes(node.Size), bar, node.Count, node.ModTime.Format(time.DateOnly), name)
+	}
+
+	if end-start < len(children) {
+		fmt.Printf("\n  Showing %d-%d of %d entries\n", start+1, end, len(children))
+	}
+
+	if len(marked) > 0 {
+		fmt.Printf("\n  Marked: %d entries\n", len(marked))
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", help)
+	fmt.Println()
+
+	return t.readKeyOrResize()
+}
+
 // PrintCleaning prints cleaning status
 func (t *Terminal) PrintCleaning(status string) {
 	t.Clear()
 	t.PrintTitle("Cleaning...")
 	fmt.Println()
 	fmt.Printf("  %s\n", status)
+	fmt.Println()
+	t.PrintColored("gray", "  [Esc] Cancel")
+	fmt.Println()
 }
 
 // PrintDone prints completion message
@@ -277,14 +738,14 @@ func (t *Terminal) PrintDone(totalSaved int64, lastError string) string {
 		if totalSaved > 0 {
 			fmt.Println()
 			t.PrintColored("green", "  ✅ Partial success: ")
-			fmt.Printf("%s freed\n", formatBytes(totalSaved))
+			fmt.Printf("%s freed\n", utils.FormatBytes(totalSaved))
 		}
 	} else {
 		t.PrintColored("green", "  ✅ Complete!")
 		fmt.Println()
 		fmt.Println()
 		fmt.Printf("  Space freed: ")
-		t.PrintColored("yellow", formatBytes(totalSaved))
+		t.PrintColored("yellow", utils.FormatBytes(totalSaved))
 		fmt.Println()
 	}
 
@@ -312,11 +773,14 @@ func (t *Terminal) PrintBigFilesConfig() string {
 	return t.ReadKey()
 }
 
-// PrintBigFilesResults prints big files results
-func (t *Terminal) PrintBigFilesResults(files []models.BigFile, selected map[int]bool, cursor int, minSize int64) string {
+// PrintBigFilesResults prints big files results with the bytes per kind,
+// as the rows of list, one item per file. kind is the kind list shows, or ""
+// for every kind; a grouped list gets a heading before each kind.
+func (t *Terminal) PrintBigFilesResults(files []models.BigFile, list *List, selected map[int]bool, minSize int64, kind filetype.Kind) string {
+	help := "  " + listKeys + "  [Space] Toggle  [a] All Shown  [t] Kind  [g] Group  [p] Preview  [d] Delete  [b] Back  [q] Quit"
 	t.Clear()
 	t.PrintTitle("Big Files Results")
-	fmt.Printf("  (>%s)\n\n", formatBytes(minSize))
+	fmt.Printf("  (>%s)\n\n", utils.FormatBytes(minSize))
 
 	if len(files) == 0 {
 		t.PrintColored("green", "  No large files found!")
@@ -326,25 +790,40 @@ func (t *Terminal) PrintBigFilesResults(files []models.BigFile, selected map[int
 		fmt.Println()
 		return t.ReadKey()
 	} else {
-		fmt.Printf("  Found %d large files:\n\n", len(files))
-
-		start := cursor
-		if start > len(files)-15 {
-			start = len(files) - 15
-		}
-		if start < 0 {
-			start = 0
+		var total int64
+		for _, f := range files {
+			total += f.Size
 		}
-
-		end := start + 15
-		if end > len(files) {
-			end = len(files)
+		fmt.Printf("  Found %d large files (%s):\n\n", len(files), utils.FormatBytes(total))
+		for _, k := range models.TotalByKind(files) {
+			line := fmt.Sprintf("  %-13s %10s  %d files", k.Kind.Label(), utils.FormatBytes(k.Size), k.Count)
+			if k.Kind == kind {
+				t.PrintColored("cyan", line+"  ◀")
+				fmt.Println()
+			} else {
+				fmt.Println(line)
+			}
 		}
-
-		for i := start; i < end; i++ {
+		fmt.Println()
+		t.printListBar(list)
+
+		// A grouped file starting a kind is headed by it
+		totals := models.TotalByKind(files)
+		rows := list.Rows()
+		start, end := list.Window(rows, t.Height-13-len(totals)-listLines-t.lines(help), func(pos int) int {
+			if list.Group != nil && (pos == 0 || files[rows[pos]].Kind != files[rows[pos-1]].Kind) {
+				return 2
+			}
+			return 1
+		})
+		for pos := start; pos < end; pos++ {
+			i := rows[pos]
 			file := files[i]
+			if list.Group != nil && (pos == start || files[rows[pos-1]].Kind != file.Kind) {
+				t.PrintColored("green", "  "+file.Kind.Label()+":\n")
+			}
 			cursorStr := "  "
-			if cursor == i {
+			if list.Cursor == pos {
 				cursorStr = "> "
 			}
 
@@ -353,85 +832,154 @@ func (t *Terminal) PrintBigFilesResults(files []models.BigFile, selected map[int
 				checked = "[✓]"
 			}
 
-			shortPath := file.Path
-			if len(shortPath) > 50 {
-				shortPath = "..." + shortPath[len(shortPath)-47:]
-			}
+			shortPath := t.fitPath(file.Path, 30)
 
-			if cursor == i {
+			if list.Cursor == pos {
 				t.PrintColored("cyan", cursorStr+checked)
 			} else if selected[i] {
 				t.PrintColored("green", cursorStr+checked)
 			} else {
 				fmt.Print(cursorStr + checked)
 			}
-			fmt.Printf(" %10s  %s\n", formatBytes(file.Size), shortPath)
+			fmt.Printf(" %10s  %-10s  %s\n", utils.FormatBytes(file.Size), file.Kind, shortPath)
 		}
-
-		if len(files) > 15 {
-			fmt.Printf("\n  Showing %d-%d of %d files\n", start+1, end, len(files))
+		noun := "files"
+		if kind != "" {
+			noun = kind.Label()
 		}
+		t.printListPosition(list, rows, start, end, noun)
 
-		var selectedCount int
+		var selectedCount, hidden int
 		var selectedSize int64
+		shown := make(map[int]bool, len(rows))
+		for _, i := range rows {
+			shown[i] = true
+		}
 		for i, sel := range selected {
 			if sel && i < len(files) {
 				selectedCount++
 				selectedSize += files[i].Size
+				if !shown[i] {
+					hidden++
+				}
 			}
 		}
 		if selectedCount > 0 {
 			fmt.Printf("\n  Selected: %d files (", selectedCount)
-			t.PrintColored("yellow", formatBytes(selectedSize))
-			fmt.Println(")")
+			t.PrintColored("yellow", utils.FormatBytes(selectedSize))
+			if hidden > 0 {
+				fmt.Printf("), %d not shown\n", hidden)
+			} else {
+				fmt.Println(")")
+			}
 		}
 	}
 
 	fmt.Println()
-	t.PrintColored("gray", "  [↑↓] Navigate  [Space] Toggle  [a] All  [d] Delete  [b] Back  [q] Quit")
+	t.PrintColored("gray", help)
 	fmt.Println()
 
-	return t.ReadKey()
+	return t.readKeyOrResize()
 }
 
-// PrintDuplicatesConfig prints duplicates configuration
-func (t *Terminal) PrintDuplicatesConfig() string {
+// PrintDuplicatesConfig prints duplicates configuration: the filter applied
+// to identical files and the roots searched, as they should be shown.
+// message explains a rejected input.
+func (t *Terminal) PrintDuplicatesConfig(filter models.DuplicateFilter, roots []string, message string) string {
 	t.Clear()
 	t.PrintTitle("Duplicate Finder")
 
 	fmt.Println("  This will scan your home directory for duplicate files.")
 	fmt.Println("  Large directories like ~/Library will be skipped.")
+	fmt.Println()
+	fmt.Printf("  [s] Identical %s\n", filter)
+	fmt.Println("  [i] Similar images: resized or re-exported JPEG, PNG and GIF files")
+	fmt.Printf("  [f] Identical folders: whole directory trees %s\n", filter.SizeRange())
+	fmt.Println()
+
+	t.PrintColored("green", "  Filters:\n")
+	maxSize := "none"
+	if filter.MaxSize > 0 {
+		maxSize = utils.FormatBytes(filter.MaxSize)
+	}
+	fmt.Printf("  [m] Minimum size: %s\n", utils.FormatBytes(filter.MinSize))
+	fmt.Printf("  [x] Maximum size: %s\n", maxSize)
+	exts := "any"
+	if len(filter.Extensions) > 0 {
+		exts = strings.Join(filter.Extensions, " ")
+	}
+	fmt.Printf("  [e] Extensions:   %s\n", exts)
+	kinds := "any"
+	if len(filter.Kinds) > 0 {
+		var labels []string
+		for _, k := range filter.Kinds {
+			labels = append(labels, string(k))
+		}
+		kinds = strings.Join(labels, ", ")
+	}
+	fmt.Printf("  [t] Kinds:        %s\n", kinds)
+	fmt.Printf("  [r] Roots:        %s\n", strings.Join(roots, ", "))
+	if message != "" {
+		fmt.Println()
+		t.PrintColored("yellow", "  "+message)
+		fmt.Println()
+	}
+
 	fmt.Println()
 	t.PrintColored("yellow", "  ⚠ This may take several minutes!")
 	fmt.Println()
 	fmt.Println()
-	t.PrintColored("gray", "  [s] Start Scan  [b] Back  [q] Quit")
+	t.PrintColored("gray", "  [s] Start Scan  [i] Find Similar Images  [f] Find Duplicate Folders  [b] Back  [q] Quit")
 	fmt.Println()
 
 	return t.ReadKey()
 }
 
-// PrintOldFilesConfig prints old files configuration
-func (t *Terminal) PrintOldFilesConfig() string {
+// PrintOldFilesConfig prints old files configuration. warnings explain why
+// the selected timestamp may be unreliable.
+func (t *Terminal) PrintOldFilesConfig(by models.FileTime, warnings []string) string {
 	t.Clear()
 	t.PrintTitle("Old Files Finder")
 
-	t.PrintColored("green", "  Find files not accessed in:\n\n")
+	t.PrintColored("green", fmt.Sprintf("  Find files with %s time older than:\n\n", by.Label()))
 	fmt.Println("  [1] 30 days (1 month)")
 	fmt.Println("  [2] 90 days (3 months)")
 	fmt.Println("  [3] 180 days (6 months)")
 	fmt.Println("  [4] 365 days (1 year)")
+	fmt.Println("  [5] Custom number of days")
 	fmt.Println()
-	t.PrintColored("gray", "  Press 1-4 to select, b to go back, q to quit")
+	fmt.Printf("  [t] Age by: %s (%s)\n", by.Label(), by)
+
+	for _, warning := range warnings {
+		fmt.Println()
+		t.PrintColored("yellow", "  ⚠ "+warning)
+		fmt.Println()
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", "  Press 1-5 to select, t to change the timestamp, b to go back, q to quit")
 	fmt.Println()
 
 	return t.ReadKey()
 }
 
 // PrintDuplicatesResults prints duplicate files results
-func (t *Terminal) PrintDuplicatesResults(groups []models.DuplicateGroup, selected map[int]bool, cursor int) string {
+func (t *Terminal) PrintDuplicatesResults(groups []models.DuplicateGroup, list *List, selected map[int]bool, keep models.KeepPolicy, searched string) string {
+	kind := models.GroupIdentical
+	if len(groups) > 0 {
+		kind = groups[0].Kind
+	}
 	t.Clear()
-	t.PrintTitle("Duplicate Files Results")
+	switch kind {
+	case models.GroupSimilar:
+		t.PrintTitle("Similar Images Results")
+	case models.GroupDirectory:
+		t.PrintTitle("Duplicate Folders Results")
+	default:
+		t.PrintTitle("Duplicate Files Results")
+	}
+
+	fmt.Printf("  (%s)\n\n", searched)
 
 	if len(groups) == 0 {
 		t.PrintColored("green", "  No duplicates found!")
@@ -442,25 +990,29 @@ func (t *Terminal) PrintDuplicatesResults(groups []models.DuplicateGroup, select
 		return t.ReadKey()
 	}
 
-	fmt.Printf("  Found %d duplicate groups:\n\n", len(groups))
-
-	start := cursor
-	if start > len(groups)-5 {
-		start = len(groups) - 5
-	}
-	if start < 0 {
-		start = 0
-	}
+	fmt.Printf("  Found %d duplicate groups (keep: %s):\n\n", len(groups), keep.Label())
+	t.printListBar(list)
 
-	end := start + 5
-	if end > len(groups) {
-		end = len(groups)
+	// Only identical files can be replaced with links
+	help := "  " + listKeys + "  [Space] Toggle  [Enter] Choose Copies  [k] Keep Policy  [p] Preview  [d] Delete Selected  [b] Back  [q] Quit"
+	if kind == models.GroupIdentical {
+		help = "  " + listKeys + "  [Space] Toggle  [Enter] Choose Copies  [k] Keep Policy  [p] Preview  [d] Delete Selected  [l] Link Selected  [b] Back  [q] Quit"
 	}
-
-	for i := start; i < end; i++ {
+	// A group takes a line, up to three files, a line for the rest and a
+	// blank line
+	rows := list.Rows()
+	start, end := list.Window(rows, t.Height-9-t.lines("  ("+searched+")")-listLines-t.lines(help), func(pos int) int {
+		files := len(groups[rows[pos]].Files)
+		if files > 3 {
+			return 6
+		}
+		return files + 2
+	})
+	for pos := start; pos < end; pos++ {
+		i := rows[pos]
 		group := groups[i]
 		cursorStr := "  "
-		if cursor == i {
+		if list.Cursor == pos {
 			cursorStr = "> "
 		}
 
@@ -469,30 +1021,46 @@ func (t *Terminal) PrintDuplicatesResults(groups []models.DuplicateGroup, select
 			checked = "[✓]"
 		}
 
-		if cursor == i {
+		if list.Cursor == pos {
 			t.PrintColored("cyan", cursorStr+checked)
 		} else if selected[i] {
 			t.PrintColored("green", cursorStr+checked)
 		} else {
 			fmt.Print(cursorStr + checked)
 		}
-		fmt.Printf(" Group %d: %s (%d files)\n", i+1, formatBytes(group.Size), len(group.Files))
+		switch group.Kind {
+		case models.GroupSimilar:
+			fmt.Printf(" Group %d: %d similar images (largest %s)\n", i+1, len(group.Files), utils.FormatBytes(group.Size))
+		case models.GroupDirectory:
+			fmt.Printf(" Group %d: %s (%d folders)\n", i+1, utils.FormatBytes(group.Size), len(group.Files))
+		default:
+			fmt.Printf(" Group %d: %s (%d files)\n", i+1, utils.FormatBytes(group.Size), len(group.Files))
+		}
 
 		// Show first 3 files
+		kept := group.Kept()
 		showCount := 3
 		if len(group.Files) < showCount {
 			showCount = len(group.Files)
 		}
 		for j := 0; j < showCount; j++ {
 			shortPath := group.Files[j]
-			if len(shortPath) > 60 {
-				shortPath = "..." + shortPath[len(shortPath)-57:]
-			}
 			prefix := "    └─"
 			if j < showCount-1 || len(group.Files) > showCount {
 				prefix = "    ├─"
 			}
-			fmt.Printf("%s %s\n", prefix, shortPath)
+			if group.Kind == models.GroupSimilar {
+				shortPath = fmt.Sprintf("%9s  %s", utils.FormatBytes(group.FileSize(j)), t.fitPath(shortPath, 23))
+			} else {
+				shortPath = t.fitPath(shortPath, 12)
+			}
+			if slices.Contains(kept, group.Files[j]) {
+				fmt.Print(prefix + " ")
+				t.PrintColored("green", "keep")
+				fmt.Printf(" %s\n", shortPath)
+			} else {
+				fmt.Printf("%s del  %s\n", prefix, shortPath)
+			}
 		}
 		if len(group.Files) > showCount {
 			fmt.Printf("    ... and %d more\n", len(group.Files)-showCount)
@@ -500,36 +1068,93 @@ func (t *Terminal) PrintDuplicatesResults(groups []models.DuplicateGroup, select
 		fmt.Println()
 	}
 
-	if len(groups) > 5 {
-		fmt.Printf("  Showing %d-%d of %d groups\n", start+1, end, len(groups))
-	}
+	t.printListPosition(list, rows, start, end, "groups")
 
 	var selectedCount int
 	var selectedSize int64
 	for i, sel := range selected {
 		if sel && i < len(groups) {
 			selectedCount++
-			selectedSize += groups[i].Size * int64(len(groups[i].Files)-1)
+			selectedSize += groups[i].Reclaimable()
 		}
 	}
 	if selectedCount > 0 {
 		fmt.Printf("\n  Selected: %d groups (saves ", selectedCount)
-		t.PrintColored("yellow", formatBytes(selectedSize))
+		t.PrintColored("yellow", utils.FormatBytes(selectedSize))
 		fmt.Println(")")
 	}
 
 	fmt.Println()
-	t.PrintColored("gray", "  [↑↓] Navigate  [Space] Toggle  [d] Delete Selected  [b] Back  [q] Quit")
+	t.PrintColored("gray", help)
 	fmt.Println()
 
-	return t.ReadKey()
+	return t.readKeyOrResize()
+}
+
+// PrintDuplicateGroup prints the copies of one duplicate group so the user
+// can choose which to keep and which to delete
+func (t *Terminal) PrintDuplicateGroup(group models.DuplicateGroup, number int, cursor int, message string) string {
+	t.Clear()
+	t.PrintTitle(fmt.Sprintf("Duplicate Group %d", number))
+	switch group.Kind {
+	case models.GroupSimilar:
+		fmt.Printf("  %d similar images, %s\n\n", len(group.Files), group.Hash)
+	case models.GroupDirectory:
+		fmt.Printf("  %s x %d folders, %s\n\n", utils.FormatBytes(group.Size), len(group.Files), group.Hash)
+	default:
+		fmt.Printf("  %s x %d files, %s\n\n", utils.FormatBytes(group.Size), len(group.Files), group.Hash)
+	}
+
+	help := "  [↑↓] Navigate  [Space] Keep/Delete  [b] Back  [q] Quit"
+	kept := group.Kept()
+	start, end := scroll(cursor, len(group.Files), t.Height-13-t.lines(help), nil)
+
+	for i := start; i < end; i++ {
+		cursorStr := "  "
+		if cursor == i {
+			cursorStr = "> "
+		}
+		mark := "[del] "
+		color := "gray"
+		if slices.Contains(kept, group.Files[i]) {
+			mark, color = "[keep]", "green"
+		}
+		if cursor == i {
+			color = "cyan"
+		}
+		shortPath := t.fitPath(group.Files[i], 9)
+		if group.Kind == models.GroupSimilar {
+			shortPath = fmt.Sprintf("%9s  %s", utils.FormatBytes(group.FileSize(i)), t.fitPath(group.Files[i], 20))
+		}
+		t.PrintColored(color, cursorStr+mark)
+		fmt.Printf(" %s\n", shortPath)
+	}
+	if end-start < len(group.Files) {
+		fmt.Printf("\n  Showing %d-%d of %d files\n", start+1, end, len(group.Files))
+	}
+
+	fmt.Printf("\n  Deleting %d copies frees ", len(group.Removed()))
+	t.PrintColored("yellow", utils.FormatBytes(group.Reclaimable()))
+	fmt.Println()
+	if message != "" {
+		fmt.Println()
+		t.PrintColored("yellow", "  "+message)
+		fmt.Println()
+	}
+
+	fmt.Println()
+	t.PrintColored("gray", help)
+	fmt.Println()
+
+	return t.readKeyOrResize()
 }
 
-// PrintOldFilesResults prints old files results
-func (t *Terminal) PrintOldFilesResults(files []models.OldFile, selected map[int]bool, cursor int, days int) string {
+// PrintOldFilesResults prints old files results as the rows of list, one
+// item per file
+func (t *Terminal) PrintOldFilesResults(files []models.OldFile, list *List, selected map[int]bool, days int, by models.FileTime) string {
 	t.Clear()
 	t.PrintTitle("Old Files Results")
-	fmt.Printf("  (> %d days)\n\n", days)
+	fmt.Printf("  (%s > %d days ago)\n\n", by.Label(), days)
 
 	if len(files) == 0 {
 		t.PrintColored("green", "  No old files found!")
@@ -546,27 +1171,19 @@ func (t *Terminal) PrintOldFilesResults(files []models.OldFile, selected map[int
 	}
 
 	fmt.Printf("  Found %d old files (", len(files))
-	t.PrintColored("yellow", formatBytes(totalSize))
+	t.PrintColored("yellow", utils.FormatBytes(totalSize))
 	fmt.Println("):")
 	fmt.Println()
+	t.printListBar(list)
 
-	start := cursor
-	if start > len(files)-15 {
-		start = len(files) - 15
-	}
-	if start < 0 {
-		start = 0
-	}
-
-	end := start + 15
-	if end > len(files) {
-		end = len(files)
-	}
-
-	for i := start; i < end; i++ {
+	help := "  " + listKeys + "  [Space] Toggle  [a] All Shown  [p] Preview  [d] Delete  [b] Back  [q] Quit"
+	rows := list.Rows()
+	start, end := list.Window(rows, t.Height-10-listLines-t.lines(help), nil)
+	for pos := start; pos < end; pos++ {
+		i := rows[pos]
 		file := files[i]
 		cursorStr := "  "
-		if cursor == i {
+		if list.Cursor == pos {
 			cursorStr = "> "
 		}
 
@@ -575,25 +1192,19 @@ func (t *Terminal) PrintOldFilesResults(files []models.OldFile, selected map[int
 			checked = "[✓]"
 		}
 
-		daysAgo := int(time.Since(file.LastAccess).Hours() / 24)
-		shortPath := file.Path
-		if len(shortPath) > 50 {
-			shortPath = "..." + shortPath[len(shortPath)-47:]
-		}
+		daysAgo := int(time.Since(file.Time(by)).Hours() / 24)
+		shortPath := t.fitPath(file.Path, 25)
 
-		if cursor == i {
+		if list.Cursor == pos {
 			t.PrintColored("cyan", cursorStr+checked)
 		} else if selected[i] {
 			t.PrintColored("green", cursorStr+checked)
 		} else {
 			fmt.Print(cursorStr + checked)
 		}
-		fmt.Printf(" %10s  %4dd  %s\n", formatBytes(file.Size), daysAgo, shortPath)
-	}
-
-	if len(files) > 15 {
-		fmt.Printf("\n  Showing %d-%d of %d files\n", start+1, end, len(files))
+		fmt.Printf(" %10s  %4dd  %s\n", utils.FormatBytes(file.Size), daysAgo, shortPath)
 	}
+	t.printListPosition(list, rows, start, end, "files")
 
 	var selectedCount int
 	var selectedSize int64
@@ -605,19 +1216,30 @@ func (t *Terminal) PrintOldFilesResults(files []models.OldFile, selected map[int
 	}
 	if selectedCount > 0 {
 		fmt.Printf("\n  Selected: %d files (", selectedCount)
-		t.PrintColored("yellow", formatBytes(selectedSize))
+		t.PrintColored("yellow", utils.FormatBytes(selectedSize))
 		fmt.Println(")")
 	}
 
 	fmt.Println()
-	t.PrintColored("gray", "  [↑↓] Navigate  [Space] Toggle  [a] All  [d] Delete  [b] Back  [q] Quit")
+	t.PrintColored("gray", help)
 	fmt.Println()
 
-	return t.ReadKey()
+	return t.readKeyOrResize()
 }
 
-// ReadKey reads a single keypress
+// ReadKey reads a single keypress. A resize of the window meanwhile is
+// noted, so the next screen is laid out for the new size.
 func (t *Terminal) ReadKey() string {
+	return t.readKey(false)
+}
+
+// readKeyOrResize is ReadKey that returns "resize" once the window changes
+// size, so a screen laid out for the old size is drawn again
+func (t *Terminal) readKeyOrResize() string {
+	return t.readKey(true)
+}
+
+func (t *Terminal) readKey(resize bool) string {
 	reader := bufio.NewReader(os.Stdin)
 
 	// Set raw mode
@@ -629,40 +1251,70 @@ func (t *Terminal) ReadKey() string {
 	}
 	defer restoreTerminal(os.Stdin, oldState)
 
+	for !waitReadable(os.Stdin, 100*time.Millisecond) {
+		if t.resized.Swap(false) {
+			t.updateSize()
+			if resize {
+				return "resize"
+			}
+		}
+	}
+
 	b, err := reader.ReadByte()
 	if err != nil {
 		return ""
 	}
 
-	// Handle escape sequences (arrow keys)
-	if b == '\033' {
-		seq := make([]byte, 2)
-		reader.Read(seq)
-		if seq[0] == '[' {
-			switch seq[1] {
-			case 'A':
-				return "up"
-			case 'B':
-				return "down"
-			}
+	// Ctrl-C quits from every screen, even while text is typed, so it is
+	// told apart from a typed q
+	if b == keyCtrlC {
+		return "ctrl-c"
+	}
+
+	// Both DEL and Ctrl-H erase, depending on the terminal
+	if b == 127 || b == 8 {
+		return "backspace"
+	}
+
+	// Handle escape sequences (arrow and paging keys). A sequence arrives
+	// at once, so Esc with nothing after it is the Esc key itself.
+	if b == keyEsc {
+		if reader.Buffered() == 0 && !waitReadable(os.Stdin, 50*time.Millisecond) {
+			return "esc"
 		}
-		return ""
+		return readEscape(reader)
 	}
 
 	return string(b)
 }
 
-// formatBytes formats bytes to human-readable string
-func formatBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return "B"
+// escapeKeys names the keys sent as ESC [ or ESC O and a final letter, or as
+// ESC [ n ~
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end",
+	"1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"5~": "pgup", "6~": "pgdn",
+}
+
+// readEscape reads the rest of an escape sequence and returns its key, or ""
+// for keys without a name
+func readEscape(reader *bufio.Reader) string {
+	intro, err := reader.ReadByte()
+	if err != nil || intro != '[' && intro != 'O' {
+		return ""
 	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	var seq []byte
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, c)
+		// Parameters are digits and semicolons; anything else ends it
+		if (c < '0' || c > '9') && c != ';' {
+			break
+		}
 	}
-	units := []string{"KB", "MB", "GB", "TB", "PB"}
-	return fmt.Sprintf("%.1f %s", float64(b)/float64(div), units[exp])
+	return escapeKeys[string(seq)]
 }
@@ -4,6 +4,9 @@
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -15,7 +18,9 @@ func makeRaw(fd *os.File) (*unix.Termios, error) {
 	}
 
 	newState := *oldState
-	newState.Lflag &^= unix.ECHO | unix.ICANON
+	// ISIG is cleared too so Ctrl-C arrives as a key instead of killing the
+	// process with the terminal still in raw mode
+	newState.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG
 
 	if err := unix.IoctlSetTermios(int(fd.Fd()), unix.TIOCSETA, &newState); err != nil {
 		return nil, err
@@ -30,3 +35,24 @@ func restoreTerminal(fd *os.File, state *unix.Termios) error {
 	}
 	return unix.IoctlSetTermios(int(fd.Fd()), unix.TIOCSETA, state)
 }
+
+// waitReadable reports whether fd has input within timeout
+func waitReadable(fd *os.File, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(fd.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+	return err == nil && n > 0
+}
+
+// windowSize returns the size of the terminal on fd in columns and rows
+func windowSize(fd *os.File) (width, height int, err error) {
+	ws, err := unix.IoctlGetWinsize(int(fd.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// notifyResize sends on c whenever the terminal window changes size
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -0,0 +1,77 @@
+package ltui
+
+import (
+	"os"
+	"unicode/utf8"
+)
+
+// minPathWidth keeps paths readable on very narrow terminals
+const minPathWidth = 20
+
+// updateSize reads the window size, keeping the last known size when stdout
+// is not a terminal
+func (t *Terminal) updateSize() {
+	if w, h, err := windowSize(os.Stdout); err == nil && w > 0 && h > 0 {
+		t.Width, t.Height = w, h
+	}
+}
+
+// lines returns how many screen lines text takes once wrapped
+func (t *Terminal) lines(text string) int {
+	return utf8.RuneCountInString(text)/max(t.Width, 1) + 1
+}
+
+// scroll returns the bounds of the rows of n to show in height screen lines,
+// keeping the cursor in the middle except near either end. Row i takes
+// lines(i) screen lines, or one when lines is nil; the cursor's row is shown
+// even when it does not fit.
+func scroll(cursor, n, height int, lines func(i int) int) (start, end int) {
+	if n == 0 {
+		return 0, 0
+	}
+	if lines == nil {
+		lines = func(int) int { return 1 }
+	}
+	cursor = max(min(cursor, n-1), 0)
+	start, end = cursor, cursor+1
+	used := lines(cursor)
+	for {
+		grew := false
+		if end < n && used+lines(end) <= height {
+			used += lines(end)
+			end++
+			grew = true
+		}
+		if start > 0 && used+lines(start-1) <= height {
+			start--
+			used += lines(start)
+			grew = true
+		}
+		if !grew {
+			return start, end
+		}
+	}
+}
+
+// fitPath shortens path from the left to fit the columns left of the window
+// after reserved columns, so its file name stays visible
+func (t *Terminal) fitPath(path string, reserved int) string {
+	width := max(t.Width-reserved, minPathWidth)
+	if utf8.RuneCountInString(path) <= width {
+		return path
+	}
+	runes := []rune(path)
+	return "..." + string(runes[len(runes)-width+3:])
+}
+
+// fitName shortens name from the right to fit in width columns, without the
+// ellipsis when there is no room for it
+func fitName(name string, width int) string {
+	if utf8.RuneCountInString(name) <= width {
+		return name
+	}
+	if width <= 3 {
+		return string([]rune(name)[:max(width, 0)])
+	}
+	return string([]rune(name)[:width-3]) + "..."
+}
@@ -0,0 +1,41 @@
+package ltui
+
+import "testing"
+
+func TestFitName(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		width int
+		want  string
+	}{
+		{"report.pdf", 20, "report.pdf"},
+		{"report.pdf", 10, "report.pdf"},
+		{"report.pdf", 8, "repor..."},
+		{"résumé-final.pdf", 9, "résumé..."},
+		{"report.pdf", 4, "r..."},
+		{"report.pdf", 3, "rep"},
+		{"report.pdf", 1, "r"},
+		{"report.pdf", 0, ""},
+		{"report.pdf", -5, ""},
+	} {
+		if got := fitName(tt.name, tt.width); got != tt.want {
+			t.Errorf("fitName(%q, %d) = %q, want %q", tt.name, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestFitPath(t *testing.T) {
+	term := &Terminal{Width: 30}
+	if got := term.fitPath("/Users/me/a.txt", 5); got != "/Users/me/a.txt" {
+		t.Errorf("fitPath() = %q, want the path unchanged", got)
+	}
+	long := "/Users/me/Documents/Projects/report.pdf"
+	if got, want := term.fitPath(long, 5), "...ts/Projects/report.pdf"; got != want {
+		t.Errorf("fitPath() = %q, want %q", got, want)
+	}
+	// However narrow the window, the end of the path stays readable
+	term.Width = 2
+	if got := term.fitPath(long, 5); len([]rune(got)) != minPathWidth {
+		t.Errorf("fitPath() = %q, want %d columns", got, minPathWidth)
+	}
+}
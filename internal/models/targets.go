@@ -1,6 +1,11 @@
 // Package models provides the default cleanup targets configuration
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
 // GetDefaultTargets returns the default list of cleanup targets
 func GetDefaultTargets() []CleanupTarget {
 	return []CleanupTarget{
@@ -71,6 +76,50 @@ func GetDefaultTargets() []CleanupTarget {
 	}
 }
 
+// SelectTargets selects every target whose name or category matches one of
+// the given values (case-insensitive). Unknown names or categories are
+// reported as an error and leave the selection untouched.
+func SelectTargets(targets []CleanupTarget, names, categories []string) error {
+	var unknown []string
+	for _, name := range names {
+		if !containsFold(targets, name, func(t CleanupTarget) string { return t.Name }) {
+			unknown = append(unknown, fmt.Sprintf("target %q", name))
+		}
+	}
+	for _, cat := range categories {
+		if !containsFold(targets, cat, func(t CleanupTarget) string { return t.Category }) {
+			unknown = append(unknown, fmt.Sprintf("category %q", cat))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown %s", strings.Join(unknown, ", "))
+	}
+
+	for i := range targets {
+		for _, name := range names {
+			if strings.EqualFold(targets[i].Name, name) {
+				targets[i].Selected = true
+			}
+		}
+		for _, cat := range categories {
+			if strings.EqualFold(targets[i].Category, cat) {
+				targets[i].Selected = true
+			}
+		}
+	}
+	return nil
+}
+
+// containsFold reports whether any target's field equals value (case-insensitive)
+func containsFold(targets []CleanupTarget, value string, field func(CleanupTarget) string) bool {
+	for _, t := range targets {
+		if strings.EqualFold(field(t), value) {
+			return true
+		}
+	}
+	return false
+}
+
 // HasSelection checks if any target is selected
 func HasSelection(targets []CleanupTarget) bool {
 	for _, t := range targets {
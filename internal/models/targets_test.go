@@ -1,7 +1,10 @@
 package models
 
 import (
+	"slices"
 	"testing"
+
+	"macos-cleaner/internal/filetype"
 )
 
 func TestGetDefaultTargets(t *testing.T) {
@@ -203,3 +206,122 @@ func TestTargetProperties(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectTargets(t *testing.T) {
+	targets := []CleanupTarget{
+		{Name: "User Caches", Category: "Cache"},
+		{Name: "Safari Cache", Category: "Cache"},
+		{Name: "User Logs", Category: "Logs"},
+		{Name: "Trash", Category: "Trash"},
+	}
+
+	if err := SelectTargets(targets, []string{"trash"}, []string{"cache"}); err != nil {
+		t.Fatalf("SelectTargets() error = %v", err)
+	}
+
+	want := []bool{true, true, false, true}
+	for i, target := range targets {
+		if target.Selected != want[i] {
+			t.Errorf("%s selected = %v, want %v", target.Name, target.Selected, want[i])
+		}
+	}
+}
+
+func TestSelectTargets_Unknown(t *testing.T) {
+	targets := []CleanupTarget{
+		{Name: "User Caches", Category: "Cache"},
+	}
+
+	err := SelectTargets(targets, []string{"User Caches", "Nope"}, []string{"Missing"})
+	if err == nil {
+		t.Fatal("SelectTargets() expected error for unknown names")
+	}
+
+	if targets[0].Selected {
+		t.Error("Selection should be untouched when names are unknown")
+	}
+}
+
+func TestDuplicateGroup_KeptRemoved(t *testing.T) {
+	g := DuplicateGroup{Size: 10, Files: []string{"/a", "/b", "/c"}}
+	if kept, removed := g.Kept(), g.Removed(); len(kept) != 1 || kept[0] != "/a" || len(removed) != 2 {
+		t.Errorf("without Keep: kept %v, removed %v; want the first kept", kept, removed)
+	}
+
+	g.Keep = []bool{false, true, true}
+	if removed := g.Removed(); len(removed) != 1 || removed[0] != "/a" || g.Reclaimable() != 10 {
+		t.Errorf("Removed() = %v, Reclaimable() = %d; want [/a], 10", removed, g.Reclaimable())
+	}
+
+	// Never delete every copy
+	g.Keep = []bool{false, false, false}
+	if removed := g.Removed(); len(removed) != 0 {
+		t.Errorf("Removed() = %v with nothing kept, want none", removed)
+	}
+
+	// Similar images free their own sizes
+	g = DuplicateGroup{Kind: GroupSimilar, Size: 30, Files: []string{"/a", "/b", "/c"}, Sizes: []int64{30, 20, 5}}
+	if g.Reclaimable() != 25 {
+		t.Errorf("Reclaimable() of similar group = %d, want 25", g.Reclaimable())
+	}
+	g.Keep = []bool{false, true, false}
+	if g.Reclaimable() != 35 || g.FileSize(2) != 5 {
+		t.Errorf("Reclaimable() = %d, FileSize(2) = %d; want 35, 5", g.Reclaimable(), g.FileSize(2))
+	}
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	def := DefaultDuplicateFilter()
+	if def.Match("a.bin", DefaultDuplicateMinSize-1) || !def.Match("a.bin", DefaultDuplicateMinSize) {
+		t.Error("default filter should compare files from DefaultDuplicateMinSize")
+	}
+	if got := def.String(); got != "files of at least 1.0 MB" {
+		t.Errorf("String() = %q", got)
+	}
+
+	f := DuplicateFilter{MinSize: 10, MaxSize: 100, Extensions: []string{".psd"}, Kinds: []filetype.Kind{filetype.Image}}
+	for _, tt := range []struct {
+		name string
+		size int64
+		want bool
+	}{
+		{"photo.JPG", 50, true},
+		{"design.PSD", 50, true},
+		{"movie.mov", 50, false},
+		{"photo.jpg", 5, false},
+		{"photo.jpg", 500, false},
+	} {
+		if got := f.Match(tt.name, tt.size); got != tt.want {
+			t.Errorf("Match(%q, %d) = %v, want %v", tt.name, tt.size, got, tt.want)
+		}
+	}
+	if got := f.String(); got != "images or .psd files from 10 B to 100 B" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (DuplicateFilter{Kinds: []filetype.Kind{filetype.Video, filetype.Archive}}).String(); got != "video or archives of any size" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if err := (DuplicateFilter{MinSize: 100, MaxSize: 10}).Validate(); err == nil {
+		t.Error("Validate() expected error for an empty size range")
+	}
+}
+
+func TestTotalByKind(t *testing.T) {
+	files := []BigFile{
+		{Path: "/a.mov", Size: 500, Kind: filetype.Video},
+		{Path: "/b.dmg", Size: 400, Kind: filetype.Installer},
+		{Path: "/c.pkg", Size: 300, Kind: filetype.Installer},
+		{Path: "/d.bin", Size: 100, Kind: filetype.Other},
+	}
+
+	totals := TotalByKind(files)
+	want := []KindTotal{
+		{Kind: filetype.Installer, Count: 2, Size: 700},
+		{Kind: filetype.Video, Count: 1, Size: 500},
+		{Kind: filetype.Other, Count: 1, Size: 100},
+	}
+	if !slices.Equal(totals, want) {
+		t.Errorf("TotalByKind() = %v, want %v", totals, want)
+	}
+}
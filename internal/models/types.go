@@ -1,7 +1,17 @@
 // Package models contains all data types and structures used by the cleaner
 package models
 
-import "time"
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/utils"
+)
 
 // CleanupTarget represents a cleanup target
 type CleanupTarget struct {
@@ -21,20 +31,317 @@ type BigFile struct {
 	Path    string
 	Size    int64
 	ModTime time.Time
+	Kind    filetype.Kind // By extension, or by contents when that is unknown
+}
+
+// KindTotal is the number and total size of the big files of one kind
+type KindTotal struct {
+	Kind  filetype.Kind
+	Count int
+	Size  int64
+}
+
+// TotalByKind totals files per kind, largest total first
+func TotalByKind(files []BigFile) []KindTotal {
+	var totals []KindTotal
+	index := make(map[filetype.Kind]int)
+	for _, f := range files {
+		i, ok := index[f.Kind]
+		if !ok {
+			i = len(totals)
+			index[f.Kind] = i
+			totals = append(totals, KindTotal{Kind: f.Kind})
+		}
+		totals[i].Count++
+		totals[i].Size += f.Size
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].Size > totals[j].Size
+	})
+	return totals
+}
+
+// GroupKind says how the files of a DuplicateGroup match
+type GroupKind int
+
+const (
+	GroupIdentical GroupKind = iota // Byte-for-byte copies sharing a content hash
+	GroupSimilar                    // Images that look alike by perceptual hash
+	GroupDirectory                  // Directory trees with identical contents; Files are their paths
+)
+
+// String returns the kind as shown in the UI and in machine output
+func (k GroupKind) String() string {
+	switch k {
+	case GroupSimilar:
+		return "similar"
+	case GroupDirectory:
+		return "directory"
+	}
+	return "identical"
 }
 
-// DuplicateGroup represents a group of duplicate files
+// DuplicateGroup represents a group of duplicate files. Similar images
+// differ in content and size, so their Sizes are recorded per file and Size
+// is that of the largest.
 type DuplicateGroup struct {
+	Kind  GroupKind
 	Hash  string
 	Size  int64
 	Files []string
+	Sizes []int64 // Per file sizes; nil when every copy is Size bytes
+	Keep  []bool  // Per file, whether the copy is kept; nil keeps only the first
+}
+
+// FileSize returns the size of the i-th file
+func (g DuplicateGroup) FileSize(i int) int64 {
+	if len(g.Sizes) == len(g.Files) {
+		return g.Sizes[i]
+	}
+	return g.Size
 }
 
-// OldFile represents an old/unused file
+// Kept returns the copies that stay
+func (g DuplicateGroup) Kept() []string {
+	if len(g.Keep) != len(g.Files) {
+		return g.Files[:min(1, len(g.Files))]
+	}
+	var kept []string
+	for i, file := range g.Files {
+		if g.Keep[i] {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// Removed returns the copies to delete. It is empty when no copy would be
+// kept, so a group is never deleted entirely.
+func (g DuplicateGroup) Removed() []string {
+	if len(g.Keep) != len(g.Files) {
+		if len(g.Files) == 0 {
+			return nil
+		}
+		return g.Files[1:]
+	}
+	if len(g.Kept()) == 0 {
+		return nil
+	}
+	var removed []string
+	for i, file := range g.Files {
+		if !g.Keep[i] {
+			removed = append(removed, file)
+		}
+	}
+	return removed
+}
+
+// Reclaimable returns the bytes deleting the removed copies frees
+func (g DuplicateGroup) Reclaimable() int64 {
+	if len(g.Kept()) == 0 {
+		return 0
+	}
+	var total int64
+	for i := range g.Files {
+		if !g.keeps(i) {
+			total += g.FileSize(i)
+		}
+	}
+	return total
+}
+
+// keeps reports whether the i-th file is kept
+func (g DuplicateGroup) keeps(i int) bool {
+	if len(g.Keep) != len(g.Files) {
+		return i == 0
+	}
+	return g.Keep[i]
+}
+
+// KeepPolicy decides which copy of a duplicate group is kept
+type KeepPolicy string
+
+const (
+	KeepNotDownloads KeepPolicy = "not-downloads" // The first copy outside ~/Downloads
+	KeepNewest       KeepPolicy = "newest"        // The most recently modified copy
+	KeepOldest       KeepPolicy = "oldest"        // The least recently modified copy
+	KeepShortest     KeepPolicy = "shortest"      // The copy with the shortest path
+	KeepPreferred    KeepPolicy = "preferred"     // The copy in the earliest preferred directory
+)
+
+// KeepPolicies lists every KeepPolicy in menu order
+var KeepPolicies = []KeepPolicy{KeepNotDownloads, KeepNewest, KeepOldest, KeepShortest, KeepPreferred}
+
+// ParseKeepPolicy parses a KeepPolicy name
+func ParseKeepPolicy(s string) (KeepPolicy, error) {
+	for _, p := range KeepPolicies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown keep policy %q (use not-downloads, newest, oldest, shortest or preferred)", s)
+}
+
+// Label returns the policy as shown in the UI
+func (p KeepPolicy) Label() string {
+	switch p {
+	case KeepNewest:
+		return "newest copy"
+	case KeepOldest:
+		return "oldest copy"
+	case KeepShortest:
+		return "shortest path"
+	case KeepPreferred:
+		return "preferred directories"
+	}
+	return "not in Downloads"
+}
+
+// DefaultDuplicateMinSize is the smallest file the Duplicate Finder compares
+// by default; small files rarely free much space and are slow to hash in bulk
+const DefaultDuplicateMinSize = 1024 * 1024
+
+// DuplicateFilter narrows the files the Duplicate Finder compares. With both
+// Extensions and Kinds set, a file matching either is compared.
+type DuplicateFilter struct {
+	MinSize    int64           // Smallest file compared, in bytes
+	MaxSize    int64           // Largest file compared, in bytes; 0 has no limit
+	Extensions []string        // Extensions compared, lower case with the dot; empty allows any
+	Kinds      []filetype.Kind // Kinds compared; empty allows any
+}
+
+// DefaultDuplicateFilter compares every file of at least DefaultDuplicateMinSize
+func DefaultDuplicateFilter() DuplicateFilter {
+	return DuplicateFilter{MinSize: DefaultDuplicateMinSize}
+}
+
+// Match reports whether a file called name of size bytes is compared
+func (f DuplicateFilter) Match(name string, size int64) bool {
+	if size < f.MinSize || (f.MaxSize > 0 && size > f.MaxSize) {
+		return false
+	}
+	if len(f.Extensions) == 0 && len(f.Kinds) == 0 {
+		return true
+	}
+	return slices.Contains(f.Extensions, filetype.NormalizeExtension(filepath.Ext(name))) ||
+		slices.Contains(f.Kinds, filetype.ByExtension(name))
+}
+
+// Validate checks that the size range is not empty
+func (f DuplicateFilter) Validate() error {
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return fmt.Errorf("sizes cannot be negative")
+	}
+	if f.MaxSize > 0 && f.MaxSize < f.MinSize {
+		return fmt.Errorf("maximum size %s is below minimum size %s", utils.FormatBytes(f.MaxSize), utils.FormatBytes(f.MinSize))
+	}
+	return nil
+}
+
+// String describes the filter, e.g. "images or .psd files from 1.0 MB to 2.0 GB"
+func (f DuplicateFilter) String() string {
+	var types []string
+	for _, k := range f.Kinds {
+		types = append(types, k.Label())
+	}
+	types = append(types, f.Extensions...)
+	what := "files"
+	switch {
+	case len(types) == 1:
+		what = types[0] + " files"
+	case len(types) > 1:
+		what = strings.Join(types[:len(types)-1], ", ") + " or " + types[len(types)-1] + " files"
+	}
+	if len(f.Kinds) > 0 && len(f.Extensions) == 0 {
+		what = strings.TrimSuffix(what, " files")
+	}
+
+	return what + " " + f.SizeRange()
+}
+
+// SizeRange describes the sizes compared, e.g. "of at least 1.0 MB"
+func (f DuplicateFilter) SizeRange() string {
+	switch {
+	case f.MaxSize > 0:
+		return fmt.Sprintf("from %s to %s", utils.FormatBytes(f.MinSize), utils.FormatBytes(f.MaxSize))
+	case f.MinSize > 0:
+		return "of at least " + utils.FormatBytes(f.MinSize)
+	}
+	return "of any size"
+}
+
+// OldFile represents an old/unused file. Changed and Created are zero when
+// the platform does not expose them.
 type OldFile struct {
 	Path       string
 	Size       int64
 	LastAccess time.Time
+	Modified   time.Time
+	Changed    time.Time
+	Created    time.Time
+}
+
+// Time returns the timestamp selected by kind, or the modification time
+// when the platform does not record it
+func (f OldFile) Time(kind FileTime) time.Time {
+	var t time.Time
+	switch kind {
+	case FileTimeAccessed:
+		t = f.LastAccess
+	case FileTimeChanged:
+		t = f.Changed
+	case FileTimeCreated:
+		t = f.Created
+	}
+	if t.IsZero() {
+		return f.Modified
+	}
+	return t
+}
+
+// FileTime selects the timestamp that decides how old a file is
+type FileTime string
+
+const (
+	FileTimeAccessed FileTime = "atime" // Last read
+	FileTimeModified FileTime = "mtime" // Last written
+	FileTimeChanged  FileTime = "ctime" // Last inode change
+	FileTimeCreated  FileTime = "birth" // Created
+)
+
+// FileTimes lists every FileTime in menu order
+var FileTimes = []FileTime{FileTimeAccessed, FileTimeModified, FileTimeChanged, FileTimeCreated}
+
+// ParseFileTime parses a FileTime name
+func ParseFileTime(s string) (FileTime, error) {
+	for _, t := range FileTimes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown timestamp %q (use atime, mtime, ctime or birth)", s)
+}
+
+// Label describes the timestamp for headings, e.g. "last access"
+func (t FileTime) Label() string {
+	switch t {
+	case FileTimeModified:
+		return "last modified"
+	case FileTimeChanged:
+		return "last changed"
+	case FileTimeCreated:
+		return "created"
+	}
+	return "last access"
+}
+
+// PlannedDeletion describes a path a dry run would delete
+type PlannedDeletion struct {
+	Path    string
+	Size    int64
+	Reason  string // Why the path was selected, e.g. the target name
+	Sudo    bool   // Deletion would run through sudo
+	Command string // Set instead of Path for command-based targets
 }
 
 // AppMode represents the current application mode
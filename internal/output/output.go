@@ -0,0 +1,488 @@
+// Package output serializes scan and clean results into machine-readable formats
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"macos-cleaner/internal/cleaner"
+	"macos-cleaner/internal/history"
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/quarantine"
+)
+
+// Format is an output format for results
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatNDJSON, FormatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want text, json, ndjson or csv)", s)
+}
+
+// Record is a result row that can be serialized to every format
+type Record interface {
+	CSVHeader() []string
+	CSVRows() [][]string
+}
+
+// Write serializes records in the given format. FormatText is left to the
+// caller since every command has its own human-readable layout.
+func Write[T Record](w io.Writer, format Format, records []T) error {
+	switch format {
+	case FormatJSON:
+		if records == nil {
+			records = []T{}
+		}
+		return WriteJSON(w, records)
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		var zero T
+		cw := csv.NewWriter(w)
+		if err := cw.Write(zero.CSVHeader()); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := cw.WriteAll(r.CSVRows()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("format %q is not machine-readable", format)
+}
+
+// WriteJSON writes v as one indented JSON document
+func WriteJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Target is the serialized form of models.CleanupTarget
+type Target struct {
+	Name         string `json:"name"`
+	Category     string `json:"category"`
+	Description  string `json:"description"`
+	Path         string `json:"path"`
+	Command      string `json:"command,omitempty"`
+	RequiresSudo bool   `json:"requires_sudo"`
+	Selected     bool   `json:"selected"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+func (Target) CSVHeader() []string {
+	return []string{"name", "category", "description", "path", "command", "requires_sudo", "selected", "size_bytes"}
+}
+
+func (t Target) CSVRows() [][]string {
+	return [][]string{{
+		t.Name, t.Category, t.Description, t.Path, t.Command,
+		strconv.FormatBool(t.RequiresSudo), strconv.FormatBool(t.Selected), itoa(t.SizeBytes),
+	}}
+}
+
+// BigFile is the serialized form of models.BigFile
+type BigFile struct {
+	Path      string    `json:"path"`
+	Kind      string    `json:"kind"` // e.g. "installer", "vm-disk" or "other"
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+func (BigFile) CSVHeader() []string {
+	return []string{"path", "kind", "size_bytes", "mod_time"}
+}
+
+func (f BigFile) CSVRows() [][]string {
+	return [][]string{{f.Path, f.Kind, itoa(f.SizeBytes), formatTime(f.ModTime)}}
+}
+
+// DuplicateGroup is the serialized form of models.DuplicateGroup
+type DuplicateGroup struct {
+	Kind             string   `json:"kind"` // "identical" or "similar"
+	Hash             string   `json:"hash"`
+	SizeBytes        int64    `json:"size_bytes"`           // Of the largest file
+	Files            []string `json:"files"`                // Largest first for similar images
+	FileSizes        []int64  `json:"file_sizes,omitempty"` // Per file, for similar images
+	Kept             []string `json:"kept"`                 // Copies the keep policy keeps; the rest are deleted
+	ReclaimableBytes int64    `json:"reclaimable_bytes"`
+}
+
+func (DuplicateGroup) CSVHeader() []string {
+	return []string{"kind", "hash", "size_bytes", "reclaimable_bytes", "path", "keep"}
+}
+
+// CSVRows emits one row per file so the CSV stays flat; size_bytes is the
+// file's own size
+func (g DuplicateGroup) CSVRows() [][]string {
+	rows := make([][]string, 0, len(g.Files))
+	for i, file := range g.Files {
+		size := g.SizeBytes
+		if len(g.FileSizes) == len(g.Files) {
+			size = g.FileSizes[i]
+		}
+		keep := slices.Contains(g.Kept, file)
+		rows = append(rows, []string{g.Kind, g.Hash, itoa(size), itoa(g.ReclaimableBytes), file, strconv.FormatBool(keep)})
+	}
+	return rows
+}
+
+// OldFile is the serialized form of models.OldFile
+type OldFile struct {
+	Path       string     `json:"path"`
+	SizeBytes  int64      `json:"size_bytes"`
+	LastAccess time.Time  `json:"last_access"`
+	Modified   time.Time  `json:"modified"`
+	Changed    *time.Time `json:"changed,omitempty"`
+	Created    *time.Time `json:"created,omitempty"`
+}
+
+func (OldFile) CSVHeader() []string {
+	return []string{"path", "size_bytes", "last_access", "modified", "changed", "created"}
+}
+
+func (f OldFile) CSVRows() [][]string {
+	return [][]string{{f.Path, itoa(f.SizeBytes), formatTime(f.LastAccess), formatTime(f.Modified),
+		formatTimePtr(f.Changed), formatTimePtr(f.Created)}}
+}
+
+// CleanResult is the serialized form of cleaner.CleanResult
+type CleanResult struct {
+	Target         string    `json:"target"`
+	RequestedBytes int64     `json:"requested_bytes"`
+	FreedBytes     int64     `json:"freed_bytes"`
+	Error          string    `json:"error,omitempty"`
+	Refused        []string  `json:"refused,omitempty"` // Paths outside the target's base directory
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+func (CleanResult) CSVHeader() []string {
+	return []string{"target", "requested_bytes", "freed_bytes", "error", "refused", "timestamp"}
+}
+
+func (r CleanResult) CSVRows() [][]string {
+	return [][]string{{r.Target, itoa(r.RequestedBytes), itoa(r.FreedBytes), r.Error,
+		strings.Join(r.Refused, ";"), formatTime(r.Timestamp)}}
+}
+
+// DeletedFile is the serialized form of cleaner.FileResult
+type DeletedFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Status    string `json:"status"` // deleted, skipped, permission_denied, vanished, sudo_refused or failed
+	Error     string `json:"error,omitempty"`
+}
+
+func (DeletedFile) CSVHeader() []string {
+	return []string{"path", "size_bytes", "status", "error"}
+}
+
+func (f DeletedFile) CSVRows() [][]string {
+	return [][]string{{f.Path, itoa(f.SizeBytes), f.Status, f.Error}}
+}
+
+// DeleteResult is the JSON form of a whole cleaner.DeleteReport: the
+// per-path records followed by the summary text mode prints
+type DeleteResult struct {
+	Files      []DeletedFile  `json:"files"`
+	FreedBytes int64          `json:"freed_bytes"`
+	Counts     map[string]int `json:"counts"` // Paths by status, with every status present
+}
+
+// PlannedDeletion is the serialized form of models.PlannedDeletion
+type PlannedDeletion struct {
+	Path      string `json:"path,omitempty"`
+	Command   string `json:"command,omitempty"`
+	SizeBytes int64  `json:"size_bytes"`
+	Reason    string `json:"reason"`
+	Sudo      bool   `json:"sudo"`
+}
+
+func (PlannedDeletion) CSVHeader() []string {
+	return []string{"path", "command", "size_bytes", "reason", "sudo"}
+}
+
+func (p PlannedDeletion) CSVRows() [][]string {
+	return [][]string{{p.Path, p.Command, itoa(p.SizeBytes), p.Reason, strconv.FormatBool(p.Sudo)}}
+}
+
+// QuarantineEntry is the serialized form of quarantine.Entry
+type QuarantineEntry struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Time         time.Time `json:"time"`
+	Operation    string    `json:"operation"`
+}
+
+func (QuarantineEntry) CSVHeader() []string {
+	return []string{"id", "original_path", "size_bytes", "time", "operation"}
+}
+
+func (e QuarantineEntry) CSVRows() [][]string {
+	return [][]string{{e.ID, e.OriginalPath, itoa(e.SizeBytes), formatTime(e.Time), e.Operation}}
+}
+
+// HistoryResult is one target's result within a recorded run
+type HistoryResult struct {
+	Time           time.Time `json:"time"`
+	Operation      string    `json:"operation"`
+	Trash          bool      `json:"trash"`
+	Target         string    `json:"target"`
+	RequestedBytes int64     `json:"requested_bytes"`
+	FreedBytes     int64     `json:"freed_bytes"`
+	Error          string    `json:"error,omitempty"`
+}
+
+func (HistoryResult) CSVHeader() []string {
+	return []string{"time", "operation", "trash", "target", "requested_bytes", "freed_bytes", "error"}
+}
+
+func (r HistoryResult) CSVRows() [][]string {
+	return [][]string{{
+		formatTime(r.Time), r.Operation, strconv.FormatBool(r.Trash), r.Target,
+		itoa(r.RequestedBytes), itoa(r.FreedBytes), r.Error,
+	}}
+}
+
+// HistorySummary is the serialized form of history.TargetSummary
+type HistorySummary struct {
+	Target              string    `json:"target"`
+	Runs                int       `json:"runs"`
+	Failures            int       `json:"failures"`
+	TotalFreedBytes     int64     `json:"total_freed_bytes"`
+	LastFreedBytes      int64     `json:"last_freed_bytes"`
+	FirstRun            time.Time `json:"first_run"`
+	LastRun             time.Time `json:"last_run"`
+	RegrowthBytesPerDay int64     `json:"regrowth_bytes_per_day"`
+}
+
+func (HistorySummary) CSVHeader() []string {
+	return []string{"target", "runs", "failures", "total_freed_bytes", "last_freed_bytes", "first_run", "last_run", "regrowth_bytes_per_day"}
+}
+
+func (s HistorySummary) CSVRows() [][]string {
+	return [][]string{{
+		s.Target, strconv.Itoa(s.Runs), strconv.Itoa(s.Failures), itoa(s.TotalFreedBytes),
+		itoa(s.LastFreedBytes), formatTime(s.FirstRun), formatTime(s.LastRun), itoa(s.RegrowthBytesPerDay),
+	}}
+}
+
+// FromTargets converts cleanup targets to records
+func FromTargets(targets []models.CleanupTarget) []Target {
+	records := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		records = append(records, Target{
+			Name:         t.Name,
+			Category:     t.Category,
+			Description:  t.Description,
+			Path:         t.Path,
+			Command:      t.Command,
+			RequiresSudo: t.RequiresSudo,
+			Selected:     t.Selected,
+			SizeBytes:    t.Size,
+		})
+	}
+	return records
+}
+
+// FromBigFiles converts big files to records
+func FromBigFiles(files []models.BigFile) []BigFile {
+	records := make([]BigFile, 0, len(files))
+	for _, f := range files {
+		records = append(records, BigFile{Path: f.Path, Kind: string(f.Kind), SizeBytes: f.Size, ModTime: f.ModTime})
+	}
+	return records
+}
+
+// FromDuplicateGroups converts duplicate groups to records
+func FromDuplicateGroups(groups []models.DuplicateGroup) []DuplicateGroup {
+	records := make([]DuplicateGroup, 0, len(groups))
+	for _, g := range groups {
+		records = append(records, DuplicateGroup{
+			Kind:             g.Kind.String(),
+			Hash:             g.Hash,
+			SizeBytes:        g.Size,
+			Files:            g.Files,
+			FileSizes:        g.Sizes,
+			Kept:             g.Kept(),
+			ReclaimableBytes: g.Reclaimable(),
+		})
+	}
+	return records
+}
+
+// FromOldFiles converts old files to records
+func FromOldFiles(files []models.OldFile) []OldFile {
+	records := make([]OldFile, 0, len(files))
+	for _, f := range files {
+		records = append(records, OldFile{
+			Path:       f.Path,
+			SizeBytes:  f.Size,
+			LastAccess: f.LastAccess,
+			Modified:   f.Modified,
+			Changed:    timePtr(f.Changed),
+			Created:    timePtr(f.Created),
+		})
+	}
+	return records
+}
+
+// FromCleanResults converts clean results to records, flattening errors to strings
+func FromCleanResults(results []cleaner.CleanResult) []CleanResult {
+	records := make([]CleanResult, 0, len(results))
+	for _, r := range results {
+		record := CleanResult{
+			Target:         r.Target,
+			RequestedBytes: r.Requested,
+			FreedBytes:     r.Actual,
+			Refused:        r.Refused,
+			Timestamp:      r.Timestamp,
+		}
+		if r.Error != nil {
+			record.Error = r.Error.Error()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// FromDeleteReport converts per-file deletion results to records
+func FromDeleteReport(report cleaner.DeleteReport) []DeletedFile {
+	records := make([]DeletedFile, 0, len(report))
+	for _, f := range report {
+		record := DeletedFile{Path: f.Path, SizeBytes: f.Size, Status: string(f.Status)}
+		if f.Err != nil {
+			record.Error = f.Err.Error()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// FromDeleteSummary converts a deletion report to its records and summary
+func FromDeleteSummary(report cleaner.DeleteReport) DeleteResult {
+	counts := make(map[string]int, len(cleaner.Statuses))
+	for _, status := range cleaner.Statuses {
+		counts[string(status)] = report.Count(status)
+	}
+	return DeleteResult{Files: FromDeleteReport(report), FreedBytes: report.Freed(), Counts: counts}
+}
+
+// FromPlan converts a dry-run plan to records
+func FromPlan(plan []models.PlannedDeletion) []PlannedDeletion {
+	records := make([]PlannedDeletion, 0, len(plan))
+	for _, p := range plan {
+		records = append(records, PlannedDeletion{
+			Path:      p.Path,
+			Command:   p.Command,
+			SizeBytes: p.Size,
+			Reason:    p.Reason,
+			Sudo:      p.Sudo,
+		})
+	}
+	return records
+}
+
+// FromQuarantine converts quarantine entries to records
+func FromQuarantine(entries []quarantine.Entry) []QuarantineEntry {
+	records := make([]QuarantineEntry, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, QuarantineEntry{
+			ID:           e.ID,
+			OriginalPath: e.OriginalPath,
+			SizeBytes:    e.Size,
+			Time:         e.Time,
+			Operation:    e.Operation,
+		})
+	}
+	return records
+}
+
+// FromHistoryRuns converts recorded runs to records, one per target result
+func FromHistoryRuns(runs []history.Run) []HistoryResult {
+	var records []HistoryResult
+	for _, run := range runs {
+		for _, r := range run.Results {
+			records = append(records, HistoryResult{
+				Time:           run.Time,
+				Operation:      run.Operation,
+				Trash:          run.Trash,
+				Target:         r.Target,
+				RequestedBytes: r.Requested,
+				FreedBytes:     r.Freed,
+				Error:          r.Error,
+			})
+		}
+	}
+	return records
+}
+
+// FromHistorySummaries converts per-target history summaries to records
+func FromHistorySummaries(summaries []history.TargetSummary) []HistorySummary {
+	records := make([]HistorySummary, 0, len(summaries))
+	for _, s := range summaries {
+		records = append(records, HistorySummary{
+			Target:              s.Target,
+			Runs:                s.Runs,
+			Failures:            s.Failures,
+			TotalFreedBytes:     s.TotalFreed,
+			LastFreedBytes:      s.LastFreed,
+			FirstRun:            s.FirstRun,
+			LastRun:             s.LastRun,
+			RegrowthBytesPerDay: s.RegrowthPerDay,
+		})
+	}
+	return records
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// timePtr returns nil for the zero time so it is omitted from JSON
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,197 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"macos-cleaner/internal/cleaner"
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/history"
+	"macos-cleaner/internal/models"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "json", "ndjson", "csv"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("ParseFormat(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(\"yaml\") expected error")
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := []models.BigFile{{Path: "/tmp/a.dmg", Size: 2048, ModTime: modTime, Kind: filetype.Installer}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, FromBigFiles(files)); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("decoded %d records, want 1", len(decoded))
+	}
+	if decoded[0]["path"] != "/tmp/a.dmg" || decoded[0]["size_bytes"] != float64(2048) || decoded[0]["kind"] != "installer" {
+		t.Errorf("unexpected record: %v", decoded[0])
+	}
+	if decoded[0]["mod_time"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("mod_time = %v", decoded[0]["mod_time"])
+	}
+}
+
+func TestWrite_JSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write[OldFile](&buf, FormatJSON, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty JSON = %q, want []", buf.String())
+	}
+}
+
+func TestWrite_NDJSON(t *testing.T) {
+	results := []cleaner.CleanResult{
+		{Target: "User Caches", Requested: 100, Actual: 100},
+		{Target: "System Logs", Requested: 50, Error: errors.New("permission denied")},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatNDJSON, FromCleanResults(results)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	var second CleanResult
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if second.Error != "permission denied" {
+		t.Errorf("error = %q, want %q", second.Error, "permission denied")
+	}
+	if strings.Contains(lines[0], `"error"`) {
+		t.Error("successful result should omit the error field")
+	}
+}
+
+func TestWrite_CSV(t *testing.T) {
+	groups := []models.DuplicateGroup{
+		{Hash: "abc", Size: 10, Files: []string{"/a", "/b", "/c"}, Keep: []bool{false, true, false}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, FromDuplicateGroups(groups)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "kind,hash,size_bytes,reclaimable_bytes,path,keep\nidentical,abc,10,20,/a,false\nidentical,abc,10,20,/b,true\nidentical,abc,10,20,/c,false\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWrite_PlanCSV(t *testing.T) {
+	plan := []models.PlannedDeletion{
+		{Path: "/tmp/cache", Size: 512, Reason: "User Caches"},
+		{Command: "brew cleanup", Size: 1024, Reason: "Homebrew Cache", Sudo: true},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, FromPlan(plan)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "path,command,size_bytes,reason,sudo\n/tmp/cache,,512,User Caches,false\n,brew cleanup,1024,Homebrew Cache,true\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWrite_Text(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatText, FromTargets(models.GetDefaultTargets())); err == nil {
+		t.Error("Write() with text format should fail")
+	}
+}
+
+func TestWrite_HistoryCSV(t *testing.T) {
+	runs := []history.Run{{
+		Time:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Operation: "clean",
+		Results: []history.Result{
+			{Target: "Trash", Requested: 10, Freed: 10},
+			{Target: "System Logs", Requested: 5, Error: "sudo failed"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, FromHistoryRuns(runs)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "time,operation,trash,target,requested_bytes,freed_bytes,error\n" +
+		"2024-01-02T03:04:05Z,clean,false,Trash,10,10,\n" +
+		"2024-01-02T03:04:05Z,clean,false,System Logs,5,0,sudo failed\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWrite_DeleteReportCSV(t *testing.T) {
+	report := cleaner.DeleteReport{
+		{Path: "/tmp/a.dmg", Size: 2048, Status: cleaner.StatusDeleted},
+		{Path: "/tmp/b.dmg", Size: 512, Status: cleaner.StatusPermissionDenied, Err: errors.New("permission denied")},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, FromDeleteReport(report)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "path,size_bytes,status,error\n/tmp/a.dmg,2048,deleted,\n/tmp/b.dmg,512,permission_denied,permission denied\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSON_DeleteSummary(t *testing.T) {
+	report := cleaner.DeleteReport{
+		{Path: "/tmp/a.dmg", Size: 2048, Status: cleaner.StatusDeleted},
+		{Path: "/tmp/b.dmg", Size: 512, Status: cleaner.StatusPermissionDenied, Err: errors.New("permission denied")},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, FromDeleteSummary(report)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got DeleteResult
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not one JSON object: %v\n%s", err, buf.String())
+	}
+	if len(got.Files) != 2 || got.Files[1].Error != "permission denied" {
+		t.Errorf("files = %+v, want both records", got.Files)
+	}
+	if got.FreedBytes != 2048 {
+		t.Errorf("freed_bytes = %d, want 2048", got.FreedBytes)
+	}
+	if got.Counts["deleted"] != 1 || got.Counts["permission_denied"] != 1 || got.Counts["failed"] != 0 {
+		t.Errorf("counts = %v, want 1 deleted and 1 permission_denied", got.Counts)
+	}
+	if _, ok := got.Counts["skipped"]; !ok {
+		t.Errorf("counts = %v, want every status present", got.Counts)
+	}
+}
@@ -0,0 +1,300 @@
+// Package quarantine moves deleted items aside so they can be restored later
+package quarantine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"macos-cleaner/internal/utils"
+)
+
+const manifestName = "manifest.jsonl"
+
+// Entry records a quarantined item
+type Entry struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"`
+	StoredPath   string    `json:"stored_path"`
+	Size         int64     `json:"size"`
+	Time         time.Time `json:"time"`
+	Operation    string    `json:"operation"`
+	Sudo         bool      `json:"sudo"` // Moved with sudo, so restore and purge need it too
+}
+
+// Quarantine is a directory holding moved items and their manifest
+type Quarantine struct {
+	Dir         string
+	SudoManager *utils.SudoManager
+
+	mu sync.Mutex
+}
+
+// DefaultDir returns the default quarantine location
+func DefaultDir() string {
+	return filepath.Join(utils.DataDir(), "Quarantine")
+}
+
+// New creates a Quarantine rooted at dir
+func New(dir string, sudoMgr *utils.SudoManager) *Quarantine {
+	return &Quarantine{
+		Dir:         dir,
+		SudoManager: sudoMgr,
+	}
+}
+
+// Move moves path into the quarantine and records it in the manifest
+func (q *Quarantine) Move(path, operation string, useSudo bool) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	size := info.Size()
+	if info.IsDir() {
+		size = utils.DirSize(path)
+	}
+
+	if err := os.MkdirAll(q.Dir, 0700); err != nil {
+		return Entry{}, fmt.Errorf("create quarantine: %w", err)
+	}
+	id, err := q.newSlot()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	stored := filepath.Join(q.Dir, id, filepath.Base(path))
+	if err := q.move(path, stored, useSudo); err != nil {
+		os.Remove(filepath.Join(q.Dir, id))
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		ID:           id,
+		OriginalPath: path,
+		StoredPath:   stored,
+		Size:         size,
+		Time:         time.Now(),
+		Operation:    operation,
+		Sudo:         useSudo,
+	}
+	if err := q.appendEntry(entry); err != nil {
+		return entry, fmt.Errorf("update manifest: %w", err)
+	}
+	return entry, nil
+}
+
+// List returns every quarantined entry, oldest first
+func (q *Quarantine) List() ([]Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.load()
+}
+
+// Restore moves the entry with the given ID back to its original path.
+// Existing files are never overwritten.
+func (q *Quarantine) Restore(id string) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.load()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	idx := -1
+	for i, e := range entries {
+		if e.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return Entry{}, fmt.Errorf("no quarantined item with id %q", id)
+	}
+	entry := entries[idx]
+
+	if _, err := os.Lstat(entry.OriginalPath); err == nil {
+		return entry, fmt.Errorf("refusing to overwrite existing %s", entry.OriginalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0755); err != nil && !entry.Sudo {
+		return entry, fmt.Errorf("recreate parent directory: %w", err)
+	}
+	if err := q.move(entry.StoredPath, entry.OriginalPath, entry.Sudo); err != nil {
+		return entry, err
+	}
+	os.Remove(filepath.Dir(entry.StoredPath))
+
+	entries = append(entries[:idx], entries[idx+1:]...)
+	return entry, q.save(entries)
+}
+
+// Purge permanently deletes entries quarantined longer than olderThan ago
+// and returns them
+func (q *Quarantine) Purge(olderThan time.Duration) ([]Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.load()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var kept, purged []Entry
+	var errs []error
+	for _, e := range entries {
+		if e.Time.After(cutoff) {
+			kept = append(kept, e)
+			continue
+		}
+		if err := checkID(e.ID); err != nil {
+			errs = append(errs, err)
+			kept = append(kept, e)
+			continue
+		}
+		if err := q.remove(filepath.Join(q.Dir, e.ID), e.Sudo); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.ID, err))
+			kept = append(kept, e)
+			continue
+		}
+		purged = append(purged, e)
+	}
+
+	if err := q.save(kept); err != nil {
+		errs = append(errs, fmt.Errorf("update manifest: %w", err))
+	}
+	return purged, errors.Join(errs...)
+}
+
+// checkID rejects manifest IDs that do not name a slot directly inside the
+// quarantine, so an edited manifest cannot make Purge remove anything else
+func checkID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid quarantine id %q", id)
+	}
+	return nil
+}
+
+// newSlot creates a unique directory for the next item and returns its ID
+func (q *Quarantine) newSlot() (string, error) {
+	base := time.Now().Format("20060102-150405")
+	for n := 1; ; n++ {
+		id := fmt.Sprintf("%s-%03d", base, n)
+		err := os.Mkdir(filepath.Join(q.Dir, id), 0700)
+		if err == nil {
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("create quarantine slot: %w", err)
+		}
+	}
+}
+
+// move renames src to dst, falling back to mv(1) across volumes
+func (q *Quarantine) move(src, dst string, useSudo bool) error {
+	if useSudo {
+		if err := q.SudoManager.Run("mv", src, dst); err != nil {
+			return fmt.Errorf("sudo mv failed: %w", err)
+		}
+		return nil
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("move failed: %w", err)
+	}
+	if output, err := exec.Command("mv", src, dst).CombinedOutput(); err != nil {
+		return fmt.Errorf("mv failed: %v: %s", err, output)
+	}
+	return nil
+}
+
+// remove deletes a quarantine slot
+func (q *Quarantine) remove(path string, useSudo bool) error {
+	if useSudo {
+		return q.SudoManager.Run("rm", "-rf", path)
+	}
+	return os.RemoveAll(path)
+}
+
+func (q *Quarantine) manifestPath() string {
+	return filepath.Join(q.Dir, manifestName)
+}
+
+// load reads the manifest
+func (q *Quarantine) load() ([]Entry, error) {
+	file, err := os.Open(q.manifestPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", q.manifestPath(), line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// appendEntry adds one entry to the manifest
+func (q *Quarantine) appendEntry(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.manifestPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// save atomically rewrites the manifest
+func (q *Quarantine) save(entries []Entry) error {
+	tmp := q.manifestPath() + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.manifestPath())
+}
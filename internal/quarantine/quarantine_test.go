@@ -0,0 +1,177 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"macos-cleaner/internal/utils"
+)
+
+func TestMoveAndRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := New(filepath.Join(tmpDir, "quarantine"), utils.NewSudoManager())
+
+	file := filepath.Join(tmpDir, "data", "file.bin")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	if err := os.WriteFile(file, make([]byte, 1234), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := q.Move(file, "big files", false)
+	if err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if entry.Size != 1234 || entry.OriginalPath != file || entry.Operation != "big files" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("file should have been moved away")
+	}
+	if _, err := os.Stat(entry.StoredPath); err != nil {
+		t.Errorf("stored copy missing: %v", err)
+	}
+
+	entries, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Fatalf("List() = %+v, want the moved entry", entries)
+	}
+
+	// Restore even if the parent directory was removed meanwhile
+	os.RemoveAll(filepath.Dir(file))
+	if _, err := q.Restore(entry.ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() != 1234 {
+		t.Errorf("file was not restored: %v", err)
+	}
+
+	entries, _ = q.List()
+	if len(entries) != 0 {
+		t.Errorf("manifest still has %d entries after restore", len(entries))
+	}
+}
+
+func TestRestore_RefusesOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := New(filepath.Join(tmpDir, "quarantine"), utils.NewSudoManager())
+
+	file := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(file, []byte("old"), 0644)
+
+	entry, err := q.Move(file, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(file, []byte("new"), 0644)
+	if _, err := q.Restore(entry.ID); err == nil {
+		t.Fatal("Restore() should refuse to overwrite an existing file")
+	}
+
+	data, _ := os.ReadFile(file)
+	if string(data) != "new" {
+		t.Error("existing file was overwritten")
+	}
+	if _, err := q.Restore("missing"); err == nil {
+		t.Error("Restore() expected error for unknown id")
+	}
+}
+
+func TestMove_Directory(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := New(filepath.Join(tmpDir, "quarantine"), utils.NewSudoManager())
+
+	dir := filepath.Join(tmpDir, "cache")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 200), 0644)
+
+	entry, err := q.Move(dir, "clean", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != 300 {
+		t.Errorf("entry.Size = %d, want 300", entry.Size)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := New(filepath.Join(tmpDir, "quarantine"), utils.NewSudoManager())
+
+	oldFile := filepath.Join(tmpDir, "old.txt")
+	newFile := filepath.Join(tmpDir, "new.txt")
+	os.WriteFile(oldFile, []byte("old"), 0644)
+	os.WriteFile(newFile, []byte("new"), 0644)
+
+	oldEntry, err := q.Move(oldFile, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newEntry, err := q.Move(newFile, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Age the first entry
+	entries, _ := q.List()
+	entries[0].Time = time.Now().AddDate(0, 0, -40)
+	if err := q.save(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := q.Purge(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != oldEntry.ID {
+		t.Fatalf("Purge() = %+v, want only the old entry", purged)
+	}
+	if _, err := os.Stat(oldEntry.StoredPath); !os.IsNotExist(err) {
+		t.Error("purged item still on disk")
+	}
+	if _, err := os.Stat(newEntry.StoredPath); err != nil {
+		t.Error("recent item should be kept")
+	}
+
+	entries, _ = q.List()
+	if len(entries) != 1 || entries[0].ID != newEntry.ID {
+		t.Errorf("manifest after purge = %+v", entries)
+	}
+}
+
+func TestPurge_RejectsUnsafeIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := New(filepath.Join(tmpDir, "quarantine"), utils.NewSudoManager())
+	os.MkdirAll(q.Dir, 0700)
+	victim := filepath.Join(tmpDir, "victim")
+	os.WriteFile(victim, []byte("keep me"), 0644)
+
+	old := time.Now().AddDate(0, 0, -40)
+	var entries []Entry
+	for _, id := range []string{"", ".", "..", "../victim", "a/b", `a\b`} {
+		entries = append(entries, Entry{ID: id, Time: old})
+	}
+	if err := q.save(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := q.Purge(0)
+	if err == nil || len(purged) != 0 {
+		t.Fatalf("Purge() = %+v, %v; want every entry refused", purged, err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("file outside the quarantine removed: %v", err)
+	}
+	if _, err := os.Stat(q.Dir); err != nil {
+		t.Errorf("quarantine directory removed: %v", err)
+	}
+	if kept, _ := q.List(); len(kept) != len(entries) {
+		t.Errorf("manifest after purge = %+v, want the refused entries kept", kept)
+	}
+}
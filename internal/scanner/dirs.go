@@ -0,0 +1,350 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/utils"
+)
+
+// ScanDuplicateDirs finds directory trees with identical contents, such as
+// copied projects or photo folders, and reports each set of trees as one
+// group so deleting a copy frees its entire size. Trees match when they hold
+// the same file names with the same contents at the same relative paths,
+// whatever their own names; empty directories are ignored. Only trees below
+// the scanned roots whose size is within s.Duplicates' size range are
+// reported, and a tree inside a reported one is not reported again.
+//
+// Each directory gets a Merkle digest over its files' hashes and its
+// subdirectories' digests. Files are only hashed when a cheaper digest over
+// names and sizes already matches another directory's. Directories holding
+// anything the walk skips, such as excluded or special files, never match,
+// as deleting them would remove content that was not compared. Finder's
+// .DS_Store files are left out of the comparison instead, since they only
+// hold view settings and differ between copies with the same content.
+func (s *Scanner) ScanDuplicateDirs(progress func(status string)) ([]models.DuplicateGroup, int64) {
+	groups, totalSize, _ := s.ScanDuplicateDirsContext(context.Background(), progress)
+	return groups, totalSize
+}
+
+// ScanDuplicateDirsContext is ScanDuplicateDirs that stops once ctx is done.
+// Nothing is reported from an interrupted scan, as a tree can only be
+// compared once all of its files are hashed.
+func (s *Scanner) ScanDuplicateDirsContext(ctx context.Context, progress func(status string)) ([]models.DuplicateGroup, int64, error) {
+	roots := s.DuplicateRoots(models.GroupDirectory)
+	matcher, err := s.Scope.Matcher(roots)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	t := &dirTree{
+		filter:     s.Duplicates,
+		nodes:      make(map[string]*dirNode),
+		incomplete: make(map[string]bool),
+	}
+	entries, err := utils.WalkContext(ctx, roots, utils.WalkOptions{
+		Workers: s.Workers,
+		SkipDir: func(path string) bool {
+			if matcher.SkipDir(path) {
+				t.markIncomplete(filepath.Dir(path))
+				return true
+			}
+			return false
+		},
+		Keep: func(path string, info os.FileInfo) bool {
+			if info.Name() == finderMetadata {
+				return false
+			}
+			mode := info.Mode()
+			if matcher.SkipFile(path) || !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+				t.markIncomplete(filepath.Dir(path))
+				return false
+			}
+			return true
+		},
+		Progress: scannedProgress(progress),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, root := range roots {
+		t.roots = append(t.roots, filepath.Clean(root))
+	}
+	t.build(entries)
+
+	// Directories whose names and sizes match another's are worth hashing
+	byShape := make(map[string][]string)
+	for dir := range t.nodes {
+		if shape := t.shape(dir); shape != "" && t.candidate(dir) {
+			byShape[shape] = append(byShape[shape], dir)
+		}
+	}
+	var candidates []string
+	for _, dirs := range byShape {
+		if len(dirs) > 1 {
+			candidates = append(candidates, dirs...)
+		}
+	}
+	progress(fmt.Sprintf("Found %d directories with matching layouts, checking their contents...", len(candidates)))
+
+	var paths []string
+	queued := make(map[string]bool)
+	for _, dir := range candidates {
+		for _, file := range t.filesBelow(dir) {
+			if !queued[file] {
+				queued[file] = true
+				paths = append(paths, file)
+			}
+		}
+	}
+	sort.Strings(paths)
+	fileHash := utils.FileHash
+	if s.Hashes != nil {
+		fileHash = s.Hashes.Full
+	}
+	hashes, err := s.hashAll(ctx, paths, func(path string) string {
+		// Links match by target; FileHash would follow them
+		if target, err := os.Readlink(path); err == nil {
+			return "link:" + target
+		}
+		return fileHash(path)
+	}, func(done int) {
+		if done%10 == 0 {
+			progress(fmt.Sprintf("Verified %d/%d files...", done, len(paths)))
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	t.hashes = make(map[string]string, len(paths))
+	for i, path := range paths {
+		t.hashes[path] = hashes[i]
+	}
+
+	byDigest := make(map[string][]string)
+	for _, dir := range candidates {
+		if digest := t.digest(dir); digest != "" {
+			byDigest[digest] = append(byDigest[digest], dir)
+		}
+	}
+	duplicated := make(map[string]bool)
+	for _, dirs := range byDigest {
+		if len(dirs) > 1 {
+			for _, dir := range dirs {
+				duplicated[dir] = true
+			}
+		}
+	}
+
+	var groups []models.DuplicateGroup
+	var totalSize int64
+	for digest, dirs := range byDigest {
+		// A copy inside a duplicated tree goes with it
+		var top []string
+		for _, dir := range dirs {
+			if !duplicated[filepath.Dir(dir)] {
+				top = append(top, dir)
+			}
+		}
+		if len(top) < 2 {
+			continue
+		}
+		sort.Strings(top)
+		groups = append(groups, models.DuplicateGroup{
+			Kind:  models.GroupDirectory,
+			Hash:  digest,
+			Size:  t.nodes[top[0]].size,
+			Files: top,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	ChooseKeep(groups, s.KeepPolicy, s.KeepPreferred)
+	for _, g := range groups {
+		totalSize += g.Reclaimable()
+	}
+	return groups, totalSize, nil
+}
+
+// finderMetadata is the file Finder keeps a folder's view settings in.
+// AppleDouble ._ files are compared like any other, as they hold resource
+// forks and extended attributes that deleting a copy would lose.
+const finderMetadata = ".DS_Store"
+
+// dirTree is the directory structure of the walked files
+type dirTree struct {
+	filter models.DuplicateFilter
+	roots  []string
+	nodes  map[string]*dirNode
+	hashes map[string]string // Content hash per file path
+
+	mu         sync.Mutex
+	incomplete map[string]bool // Directories directly holding skipped entries
+}
+
+// dirNode is one directory; size and the digests cover everything below it
+type dirNode struct {
+	files   []utils.FileEntry
+	subdirs []string
+	size    int64
+	count   int
+	skipped bool // It or a directory below it holds skipped entries
+
+	shape, digest string
+	shaped        bool
+	digested      bool
+}
+
+func (t *dirTree) markIncomplete(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.incomplete[dir] = true
+}
+
+// build links every file to its directory and every directory to its
+// parent, and sums sizes and skipped entries bottom-up
+func (t *dirTree) build(entries []utils.FileEntry) {
+	for _, entry := range entries {
+		n := t.node(filepath.Dir(entry.Path))
+		n.files = append(n.files, entry)
+	}
+	for dir := range t.incomplete {
+		t.node(dir).skipped = true
+	}
+
+	// Deepest first, so every directory is complete before its parent
+	dirs := make([]string, 0, len(t.nodes))
+	for dir := range t.nodes {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	for _, dir := range dirs {
+		n := t.nodes[dir]
+		for _, f := range n.files {
+			n.size += f.Info.Size()
+			n.count++
+		}
+		for _, sub := range n.subdirs {
+			child := t.nodes[sub]
+			n.size += child.size
+			n.count += child.count
+			n.skipped = n.skipped || child.skipped
+		}
+	}
+}
+
+// node returns the node of dir, creating it and its parents up to the
+// nearest root
+func (t *dirTree) node(dir string) *dirNode {
+	if n := t.nodes[dir]; n != nil {
+		return n
+	}
+	n := &dirNode{}
+	t.nodes[dir] = n
+	if parent := filepath.Dir(dir); parent != dir && !slices.Contains(t.roots, dir) {
+		p := t.node(parent)
+		p.subdirs = append(p.subdirs, dir)
+	}
+	return n
+}
+
+// candidate reports whether dir may be reported: a complete tree within the
+// filter's size range strictly below one of the roots
+func (t *dirTree) candidate(dir string) bool {
+	n := t.nodes[dir]
+	if n.skipped || n.size < t.filter.MinSize || (t.filter.MaxSize > 0 && n.size > t.filter.MaxSize) {
+		return false
+	}
+	for _, root := range t.roots {
+		if dir != root && inDir(dir, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// filesBelow returns every file in the tree at dir
+func (t *dirTree) filesBelow(dir string) []string {
+	n := t.nodes[dir]
+	var files []string
+	for _, f := range n.files {
+		files = append(files, f.Path)
+	}
+	for _, sub := range n.subdirs {
+		files = append(files, t.filesBelow(sub)...)
+	}
+	return files
+}
+
+// shape returns a digest of the names and sizes in the tree at dir
+func (t *dirTree) shape(dir string) string {
+	n := t.nodes[dir]
+	if !n.shaped {
+		n.shaped = true
+		n.shape = t.sum(n, func(f utils.FileEntry) string {
+			return fmt.Sprint(f.Info.Size())
+		}, t.shape)
+	}
+	return n.shape
+}
+
+// digest returns a digest of the names and file hashes in the tree at dir,
+// or "" when a file could not be hashed
+func (t *dirTree) digest(dir string) string {
+	n := t.nodes[dir]
+	if !n.digested {
+		n.digested = true
+		n.digest = t.sum(n, func(f utils.FileEntry) string {
+			return t.hashes[f.Path]
+		}, t.digest)
+	}
+	return n.digest
+}
+
+// sum hashes the entries of n, describing files with file and subdirectories
+// with sub. Empty subdirectories are left out. It returns "" for trees with
+// skipped entries or no files, or when file or sub returns "".
+func (t *dirTree) sum(n *dirNode, file func(utils.FileEntry) string, sub func(string) string) string {
+	if n.skipped || n.count == 0 {
+		return ""
+	}
+	var lines []string
+	for _, f := range n.files {
+		v := file(f)
+		if v == "" {
+			return ""
+		}
+		lines = append(lines, fmt.Sprintf("f %q %s", filepath.Base(f.Path), v))
+	}
+	for _, dir := range n.subdirs {
+		if t.nodes[dir].count == 0 {
+			continue
+		}
+		v := sub(dir)
+		if v == "" {
+			return ""
+		}
+		lines = append(lines, fmt.Sprintf("d %q %s", filepath.Base(dir), v))
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		fmt.Fprintln(h, line)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
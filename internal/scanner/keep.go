@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/utils"
+)
+
+// ChooseKeep marks one copy of every group as kept according to policy,
+// replacing earlier choices. preferred lists directories, highest priority
+// first, for KeepPreferred. When the policy cannot decide, for example when
+// no copy is in a preferred directory, the first copy outside ~/Downloads
+// is kept.
+func ChooseKeep(groups []models.DuplicateGroup, policy models.KeepPolicy, preferred []string) {
+	for i := range groups {
+		g := &groups[i]
+		g.Keep = make([]bool, len(g.Files))
+		if len(g.Files) > 0 {
+			g.Keep[keeper(g.Files, policy, preferred)] = true
+		}
+	}
+}
+
+// keeper returns the index of the copy policy keeps
+func keeper(files []string, policy models.KeepPolicy, preferred []string) int {
+	switch policy {
+	case models.KeepNewest, models.KeepOldest:
+		best, bestTime := -1, time.Time{}
+		for i, file := range files {
+			info, err := os.Lstat(file)
+			if err != nil {
+				continue
+			}
+			t := info.ModTime()
+			if best < 0 ||
+				(policy == models.KeepNewest && t.After(bestTime)) ||
+				(policy == models.KeepOldest && t.Before(bestTime)) {
+				best, bestTime = i, t
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	case models.KeepShortest:
+		best := 0
+		for i, file := range files {
+			if len(file) < len(files[best]) {
+				best = i
+			}
+		}
+		return best
+	case models.KeepPreferred:
+		for _, dir := range preferred {
+			dir = filepath.Clean(utils.ExpandPath(dir))
+			for i, file := range files {
+				if inDir(file, dir) {
+					return i
+				}
+			}
+		}
+	}
+
+	downloads := utils.ExpandPath("~/Downloads")
+	for i, file := range files {
+		if !inDir(file, downloads) {
+			return i
+		}
+	}
+	return 0
+}
+
+// inDir reports whether path is dir or lies below it
+func inDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
@@ -2,67 +2,117 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/hashcache"
+	"macos-cleaner/internal/imagehash"
 	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/scope"
 	"macos-cleaner/internal/utils"
 )
 
 // Scanner handles scanning operations
 type Scanner struct {
 	SudoManager *utils.SudoManager
+	Scope       scope.Scope     // Roots and exclusions of the finders; must be valid
+	Workers     int             // Directories walked and files hashed in parallel; 0 uses NumCPU
+	OldFilesBy  models.FileTime // Timestamp that decides a file's age in ScanOldFiles
+	SizeMode    utils.SizeMode  // How CalculateSize counts bytes; match the Cleaner's
+
+	Duplicates    models.DuplicateFilter // Files ScanDuplicates compares; sizes bound ScanDuplicateDirs' trees
+	KeepPolicy    models.KeepPolicy      // Copy of each duplicate group ScanDuplicates marks as kept
+	KeepPreferred []string               // Directories in priority order for KeepPreferred
+	Hashes        *hashcache.Cache       // Hashes remembered between duplicate scans; nil hashes every file
+
+	ImageHash     imagehash.Algorithm // Perceptual hash ScanSimilarImages compares
+	ImageDistance int                 // Differing hash bits up to which images are similar
 }
 
 // New creates a new Scanner
 func New(sudoMgr *utils.SudoManager) *Scanner {
 	return &Scanner{
 		SudoManager: sudoMgr,
+		Scope:       scope.Default(),
+		OldFilesBy:  models.FileTimeAccessed,
+		Duplicates:  models.DefaultDuplicateFilter(),
+		KeepPolicy:  models.KeepNotDownloads,
+
+		ImageHash:     imagehash.Difference,
+		ImageDistance: imagehash.DefaultDistance,
 	}
 }
 
-// CalculateSize calculates the total size of files matching a pattern
-// This uses SafeGlob for better handling of nested directories
+// CalculateSize calculates the total size of files matching a pattern.
+// Symlinks are counted as links and matches that resolve outside the
+// pattern's base directory are skipped, as the Cleaner refuses them.
 func (s *Scanner) CalculateSize(pattern string) int64 {
+	total, _ := s.CalculateSizeContext(context.Background(), pattern)
+	return total
+}
+
+// CalculateSizeContext is CalculateSize that stops once ctx is done,
+// returning the size counted so far together with ctx.Err()
+func (s *Scanner) CalculateSizeContext(ctx context.Context, pattern string) (int64, error) {
 	matches, err := utils.SafeGlob(pattern)
 	if err != nil {
-		return 0
+		return 0, ctx.Err()
 	}
 
+	// One counter for all matches so files hard-linked between them count once
+	counter := utils.NewSizeCounter(s.SizeMode)
 	var total int64
 	for _, match := range matches {
-		info, err := os.Stat(match)
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		info, err := os.Lstat(match)
 		if err != nil {
 			continue
 		}
 
 		if info.IsDir() {
-			total += utils.DirSize(match)
+			size, err := utils.DirUsageContext(ctx, match, s.Workers, counter)
+			total += size
+			if err != nil {
+				return total, err
+			}
 		} else {
-			total += info.Size()
+			total += counter.Add(info)
 		}
 	}
 
-	return total
+	return total, nil
 }
 
 // CalculateSizeForTarget calculates size for a CleanupTarget
 func (s *Scanner) CalculateSizeForTarget(target *models.CleanupTarget) int64 {
+	size, _ := s.CalculateSizeForTargetContext(context.Background(), target)
+	return size
+}
+
+// CalculateSizeForTargetContext is CalculateSizeForTarget that stops once
+// ctx is done
+func (s *Scanner) CalculateSizeForTargetContext(ctx context.Context, target *models.CleanupTarget) (int64, error) {
 	if target.IsCommand {
-		return s.calculateCommandSize(target.Command)
+		return s.calculateCommandSize(ctx, target.Command), ctx.Err()
 	}
-	return s.CalculateSize(target.Path)
+	return s.CalculateSizeContext(ctx, target.Path)
 }
 
 // calculateCommandSize estimates size for command-based targets
-func (s *Scanner) calculateCommandSize(command string) int64 {
+func (s *Scanner) calculateCommandSize(ctx context.Context, command string) int64 {
 	// For brew cleanup, use dry-run to estimate size
 	if strings.Contains(command, "brew cleanup") {
-		return s.getHomebrewCleanupSize()
+		return s.getHomebrewCleanupSize(ctx)
 	}
 	if strings.Contains(command, "tmutil deletelocalsnapshots") {
 		return s.getTimeMachineSnapshotSize()
@@ -71,10 +121,10 @@ func (s *Scanner) calculateCommandSize(command string) int64 {
 }
 
 // getHomebrewCleanupSize gets the estimated cleanup size from Homebrew
-func (s *Scanner) getHomebrewCleanupSize() int64 {
+func (s *Scanner) getHomebrewCleanupSize(ctx context.Context) int64 {
 	// Run brew cleanup -n and parse output for size estimation
 	// brew cleanup -n outputs lines like: "Would remove: /path/to/file (1.2MB)"
-	cmd := exec.Command("brew", "cleanup", "-n")
+	cmd := exec.CommandContext(ctx, "brew", "cleanup", "-n")
 	output, err := cmd.Output()
 	if err != nil {
 		return 0
@@ -149,224 +199,322 @@ func (s *Scanner) getTimeMachineSnapshotSize() int64 {
 	return int64(count) * 1024 * 1024 * 1024 // Estimate 1GB per snapshot
 }
 
+// walk walks the scope's roots, or defaults when it has none, and returns
+// the files in scope that keep accepts
+func (s *Scanner) walk(ctx context.Context, defaults []string, keep func(info os.FileInfo) bool, progress func(string)) ([]utils.FileEntry, error) {
+	roots := s.Scope.RootsOr(defaults)
+	matcher, err := s.Scope.Matcher(roots)
+	if err != nil {
+		return nil, err
+	}
+	return utils.WalkContext(ctx, roots, utils.WalkOptions{
+		Workers: s.Workers,
+		SkipDir: matcher.SkipDir,
+		Keep: func(path string, info os.FileInfo) bool {
+			return !matcher.SkipFile(path) && keep(info)
+		},
+		Progress: scannedProgress(progress),
+	})
+}
+
 // ScanBigFiles scans for files larger than the specified size
 func (s *Scanner) ScanBigFiles(minSize int64, progress func(status string)) []models.BigFile {
+	files, _ := s.ScanBigFilesContext(context.Background(), minSize, progress)
+	return files
+}
+
+// ScanBigFilesContext is ScanBigFiles that stops once ctx is done, returning
+// the files found so far together with ctx.Err()
+func (s *Scanner) ScanBigFilesContext(ctx context.Context, minSize int64, progress func(status string)) ([]models.BigFile, error) {
 	var files []models.BigFile
 
 	// Scan specific directories instead of entire home to improve performance
-	dirs := []string{
+	entries, err := s.walk(ctx, []string{
 		utils.ExpandPath("~/Documents"),
 		utils.ExpandPath("~/Desktop"),
 		utils.ExpandPath("~/Downloads"),
 		utils.ExpandPath("~/Movies"),
 		utils.ExpandPath("~/Music"),
 		utils.ExpandPath("~/Pictures"),
+	}, func(info os.FileInfo) bool {
+		return info.Size() >= minSize
+	}, progress)
+
+	for _, entry := range entries {
+		files = append(files, models.BigFile{
+			Path:    entry.Path,
+			Size:    entry.Info.Size(),
+			ModTime: entry.Info.ModTime(),
+			Kind:    filetype.Classify(entry.Path),
+		})
+		progress(fmt.Sprintf("Found: %s (%s)", utils.ShortenPath(entry.Info.Name(), 30), utils.FormatBytes(entry.Info.Size())))
 	}
 
-	skipDirs := map[string]bool{
-		".git":         true,
-		"node_modules": true,
-		"vendor":       true,
-		"Library":      true, // Skip Library - it's huge and mostly cache
-	}
-
-	scannedCount := 0
+	return files, err
+}
 
-	for _, dir := range dirs {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			continue
+// scannedProgress reports the walked file count every 500 files
+func scannedProgress(progress func(string)) func(int) {
+	return func(scanned int) {
+		if scanned%500 == 0 {
+			progress(fmt.Sprintf("Scanned %d files...", scanned))
 		}
-
-		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-
-			scannedCount++
-
-			// Update progress periodically
-			if scannedCount%500 == 0 {
-				progress(fmt.Sprintf("Scanned %d files...", scannedCount))
-			}
-
-			// Skip hidden dirs and system dirs
-			if info.IsDir() {
-				name := info.Name()
-				if skipDirs[name] || strings.HasPrefix(name, ".") {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			if info.Size() >= minSize {
-				files = append(files, models.BigFile{
-					Path:    path,
-					Size:    info.Size(),
-					ModTime: info.ModTime(),
-				})
-				progress(fmt.Sprintf("Found: %s (%s)", utils.ShortenPath(info.Name(), 30), formatBytes(info.Size())))
-			}
-
-			return nil
-		})
 	}
-
-	return files
 }
 
 // ScanDuplicates scans for duplicate files in the specified directories
 func (s *Scanner) ScanDuplicates(progress func(status string)) ([]models.DuplicateGroup, int64) {
+	groups, totalSize, _ := s.ScanDuplicatesContext(context.Background(), progress)
+	return groups, totalSize
+}
+
+// ScanDuplicatesContext is ScanDuplicates that stops once ctx is done. The
+// groups verified so far are returned together with ctx.Err(); files that
+// were not fully hashed are left out rather than reported unverified.
+func (s *Scanner) ScanDuplicatesContext(ctx context.Context, progress func(status string)) ([]models.DuplicateGroup, int64, error) {
 	sizeMap := make(map[int64][]string)
 
-	dirs := []string{
-		utils.ExpandPath("~/Documents"),
-		utils.ExpandPath("~/Desktop"),
-		utils.ExpandPath("~/Downloads"),
+	// First pass: group by size
+	entries, err := s.walk(ctx, s.DuplicateRoots(models.GroupIdentical), func(info os.FileInfo) bool {
+		return s.Duplicates.Match(info.Name(), info.Size())
+	}, progress)
+	for _, entry := range entries {
+		sizeMap[entry.Info.Size()] = append(sizeMap[entry.Info.Size()], entry.Path)
 	}
 
-	skipDirs := map[string]bool{
-		".git":         true,
-		"node_modules": true,
-		"vendor":       true,
-		"Library":      true,
+	if err != nil {
+		return nil, 0, err // Nothing has been verified yet
 	}
+	progress(fmt.Sprintf("Found %d files with unique sizes, checking for duplicates...", len(sizeMap)))
 
-	// First pass: group by size
-	scannedCount := 0
-	for _, dir := range dirs {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			continue
+	// Second pass: fingerprint head and tail of files with the same size
+	var candidates []hashGroup
+	for size, paths := range sizeMap {
+		if len(paths) > 1 {
+			candidates = append(candidates, hashGroup{size: size, paths: paths})
 		}
+	}
+	// Largest first so the order does not depend on map iteration
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].size > candidates[j].size
+	})
+	partialHash, fileHash := utils.PartialHash, utils.FileHash
+	if s.Hashes != nil {
+		partialHash, fileHash = s.Hashes.Partial, s.Hashes.Full
+	}
+	candidates, err = s.regroup(ctx, candidates, partialHash, "Checked", progress)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
+	// Third pass: confirm with a full-content hash
+	candidates, err = s.regroup(ctx, candidates, fileHash, "Verified", progress)
 
-			if info.IsDir() {
-				if skipDirs[info.Name()] || strings.HasPrefix(info.Name(), ".") {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+	// Create duplicate groups from fully verified files only
+	var groups []models.DuplicateGroup
+	var totalSize int64
+	for _, c := range candidates {
+		sort.Strings(c.paths)
+		groups = append(groups, models.DuplicateGroup{
+			Hash:  c.hash,
+			Size:  c.size,
+			Files: c.paths,
+		})
+		totalSize += c.size * int64(len(c.paths)-1)
+	}
+	ChooseKeep(groups, s.KeepPolicy, s.KeepPreferred)
 
-			scannedCount++
-			if scannedCount%500 == 0 {
-				progress(fmt.Sprintf("Scanned %d files...", scannedCount))
-			}
+	return groups, totalSize, err
+}
 
-			// Only check files > 1MB to save time
-			if info.Size() > 1024*1024 {
-				sizeMap[info.Size()] = append(sizeMap[info.Size()], path)
-			}
+// DuplicateRoots returns the directories searched for duplicates of kind:
+// the scope's roots, or ~/Documents, ~/Desktop and ~/Downloads, plus
+// ~/Pictures for similar images
+func (s *Scanner) DuplicateRoots(kind models.GroupKind) []string {
+	defaults := []string{
+		utils.ExpandPath("~/Documents"),
+		utils.ExpandPath("~/Desktop"),
+		utils.ExpandPath("~/Downloads"),
+	}
+	if kind == models.GroupSimilar {
+		defaults = append(defaults, utils.ExpandPath("~/Pictures"))
+	}
+	return s.Scope.RootsOr(defaults)
+}
 
-			return nil
-		})
+// DescribeDuplicates says what a duplicate scan for kind compares and where,
+// e.g. "files of at least 1.0 MB in ~/Documents, ~/Desktop, ~/Downloads"
+func (s *Scanner) DescribeDuplicates(kind models.GroupKind) string {
+	var what string
+	switch kind {
+	case models.GroupSimilar:
+		what = fmt.Sprintf("images within %d bits by %s", s.ImageDistance, s.ImageHash.Label())
+	case models.GroupDirectory:
+		what = "directory trees " + s.Duplicates.SizeRange()
+	default:
+		what = s.Duplicates.String()
 	}
+	var roots []string
+	for _, root := range s.DuplicateRoots(kind) {
+		roots = append(roots, utils.ContractPath(root))
+	}
+	return what + " in " + strings.Join(roots, ", ")
+}
 
-	progress(fmt.Sprintf("Found %d files with unique sizes, checking for duplicates...", len(sizeMap)))
+// hashGroup is a set of same-size files that share a hash
+type hashGroup struct {
+	hash  string
+	size  int64
+	paths []string
+}
 
-	// Second pass: hash files with same size
-	hashMap := make(map[string][]string)
-	hashCount := 0
-	totalPaths := 0
-	for _, paths := range sizeMap {
-		totalPaths += len(paths)
+// regroup splits every group by hash, dropping files that could not be read
+// and groups left with a single file. Files are hashed by a pool of
+// s.Workers goroutines; verb labels the progress updates.
+func (s *Scanner) regroup(ctx context.Context, groups []hashGroup, hash func(string) string, verb string, progress func(string)) ([]hashGroup, error) {
+	var paths []string
+	for _, g := range groups {
+		paths = append(paths, g.paths...)
 	}
-
-	for _, paths := range sizeMap {
-		if len(paths) < 2 {
-			continue
+	hashes, err := s.hashAll(ctx, paths, hash, func(done int) {
+		if done%10 == 0 {
+			progress(fmt.Sprintf("%s %d/%d files...", verb, done, len(paths)))
 		}
-
-		for _, path := range paths {
-			hash := utils.FileHash(path)
-			if hash != "" {
-				hashMap[hash] = append(hashMap[hash], path)
+	})
+
+	var result []hashGroup
+	next := 0
+	for _, g := range groups {
+		byHash := make(map[string][]string)
+		var order []string
+		for _, path := range g.paths {
+			h := hashes[next]
+			next++
+			if h == "" {
+				continue
 			}
-			hashCount++
-			if hashCount%10 == 0 {
-				progress(fmt.Sprintf("Hashed %d/%d files...", hashCount, totalPaths))
+			if _, ok := byHash[h]; !ok {
+				order = append(order, h)
+			}
+			byHash[h] = append(byHash[h], path)
+		}
+		for _, h := range order {
+			if len(byHash[h]) > 1 {
+				result = append(result, hashGroup{hash: h, size: g.size, paths: byHash[h]})
 			}
 		}
 	}
+	return result, err
+}
 
-	// Create duplicate groups
-	var groups []models.DuplicateGroup
-	var totalSize int64
-	for hash, paths := range hashMap {
-		if len(paths) > 1 {
-			info, _ := os.Stat(paths[0])
-			if info != nil {
-				groups = append(groups, models.DuplicateGroup{
-					Hash:  hash,
-					Size:  info.Size(),
-					Files: paths,
-				})
-				totalSize += info.Size() * int64(len(paths)-1)
+// hashAll hashes paths with a bounded worker pool and returns the hashes in
+// the order of paths. onDone is called with the running count of finished
+// files from a single goroutine. Once ctx is done no new files are started
+// and the remaining hashes stay empty.
+func (s *Scanner) hashAll(ctx context.Context, paths []string, hash func(string) string, onDone func(done int)) ([]string, error) {
+	hashes := make([]string, len(paths))
+	jobs := make(chan int)
+	finished := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < utils.Workers(s.Workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i] = hash(paths[i])
+				finished <- struct{}{}
 			}
-		}
+		}()
 	}
 
-	return groups, totalSize
+	go func() {
+	dispatch:
+		for i := range paths {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for range finished {
+		done++
+		onDone(done)
+	}
+	return hashes, ctx.Err()
 }
 
 // ScanOldFiles scans for files not accessed in the specified number of days
 func (s *Scanner) ScanOldFiles(days int, progress func(status string)) []models.OldFile {
+	files, _ := s.ScanOldFilesContext(context.Background(), days, progress)
+	return files
+}
+
+// ScanOldFilesContext is ScanOldFiles that stops once ctx is done, returning
+// the files found so far together with ctx.Err()
+func (s *Scanner) ScanOldFilesContext(ctx context.Context, days int, progress func(status string)) ([]models.OldFile, error) {
 	var files []models.OldFile
 	cutoff := time.Now().AddDate(0, 0, -days)
 
-	dirs := []string{
-		utils.ExpandPath("~/Documents"),
-		utils.ExpandPath("~/Desktop"),
-		utils.ExpandPath("~/Downloads"),
-	}
+	entries, err := s.walk(ctx, oldFilesDirs(), func(info os.FileInfo) bool {
+		return oldFile(info).Time(s.OldFilesBy).Before(cutoff)
+	}, progress)
 
-	skipDirs := map[string]bool{
-		".git":         true,
-		"node_modules": true,
+	for _, entry := range entries {
+		file := oldFile(entry.Info)
+		file.Path = entry.Path
+		files = append(files, file)
 	}
 
-	for _, dir := range dirs {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			continue
-		}
-
-		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil || info.IsDir() {
-				if info != nil && info.IsDir() && skipDirs[info.Name()] {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+	return files, err
+}
 
-			// Check last access time (using ModTime as approximation)
-			if info.ModTime().Before(cutoff) {
-				files = append(files, models.OldFile{
-					Path:       path,
-					Size:       info.Size(),
-					LastAccess: info.ModTime(),
-				})
-			}
+// AccessTimeWarnings explains why last-access times may be stale for the
+// Old Files roots, one message per root whose volume does not record every
+// read. It returns nothing unless ScanOldFiles filters on access time.
+func (s *Scanner) AccessTimeWarnings() []string {
+	if s.OldFilesBy != models.FileTimeAccessed {
+		return nil
+	}
 
-			return nil
-		})
+	var warnings []string
+	for _, root := range s.Scope.RootsOr(oldFilesDirs()) {
+		switch policy := utils.VolumeAtimePolicy(root); policy {
+		case utils.AtimeDisabled:
+			warnings = append(warnings, fmt.Sprintf("%s is on a volume mounted %s: access times are never updated, so files may look unused", root, policy))
+		case utils.AtimeRelaxed:
+			warnings = append(warnings, fmt.Sprintf("%s is on a volume mounted %s: some reads are not recorded, so access times may lag", root, policy))
+		}
 	}
+	return warnings
+}
 
-	return files
+// oldFilesDirs are the directories ScanOldFiles searches by default
+func oldFilesDirs() []string {
+	return []string{
+		utils.ExpandPath("~/Documents"),
+		utils.ExpandPath("~/Desktop"),
+		utils.ExpandPath("~/Downloads"),
+	}
 }
 
-// formatBytes formats bytes to human-readable string
-func formatBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return "B"
+// oldFile builds an OldFile from the timestamps of info
+func oldFile(info os.FileInfo) models.OldFile {
+	times := utils.Times(info)
+	if times.Accessed.IsZero() {
+		times.Accessed = times.Modified
 	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	return models.OldFile{
+		Size:       info.Size(),
+		LastAccess: times.Accessed,
+		Modified:   times.Modified,
+		Changed:    times.Changed,
+		Created:    times.Created,
 	}
-	units := []string{"KB", "MB", "GB", "TB"}
-	return units[exp]
 }
@@ -1,11 +1,19 @@
 package scanner
 
 import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/hashcache"
+	"macos-cleaner/internal/imagehash"
 	"macos-cleaner/internal/models"
 	"macos-cleaner/internal/utils"
 )
@@ -109,6 +117,12 @@ func TestScanBigFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Large file recognised by its contents (should be found)
+	download := filepath.Join(docsDir, "Setup.download")
+	if err := os.WriteFile(download, append([]byte("xar!"), make([]byte, 3000)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	// Mock home directory temporarily
 	originalHome := os.Getenv("HOME")
 	os.Setenv("HOME", tmpDir)
@@ -126,15 +140,22 @@ func TestScanBigFiles(t *testing.T) {
 		t.Error("Progress callback was not called")
 	}
 
-	// Should find 2 files (2000 and 5000 bytes)
-	if len(files) != 2 {
-		t.Errorf("ScanBigFiles() found %d files, want 2", len(files))
+	// Should find 3 files (2000, 3004 and 5000 bytes)
+	if len(files) != 3 {
+		t.Errorf("ScanBigFiles() found %d files, want 3", len(files))
 	}
 
-	// Check that found files have correct paths
+	// Check that found files have correct paths and kinds
 	foundPaths := make(map[string]bool)
 	for _, f := range files {
 		foundPaths[f.Path] = true
+		want := filetype.Document
+		if f.Path == download {
+			want = filetype.Installer
+		}
+		if f.Kind != want {
+			t.Errorf("%s: Kind = %q, want %q", filepath.Base(f.Path), f.Kind, want)
+		}
 	}
 
 	if !foundPaths[largeFile] {
@@ -302,3 +323,327 @@ func TestParseHomebrewSize(t *testing.T) {
 		})
 	}
 }
+
+func TestScanDuplicates_SharedPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Same size and same first and last 4KB, but different in the middle
+	a := make([]byte, 2*1024*1024)
+	b := make([]byte, 2*1024*1024)
+	b[1024*1024] = 1
+	os.WriteFile(filepath.Join(tmpDir, "a.zip"), a, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.zip"), b, 0644)
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	groups, totalSize := scanner.ScanDuplicates(func(string) {})
+	if len(groups) != 0 || totalSize != 0 {
+		t.Errorf("ScanDuplicates() reported files differing past the header as duplicates: %+v", groups)
+	}
+
+	// An exact copy is verified and carries its full-content hash
+	os.WriteFile(filepath.Join(tmpDir, "c.zip"), a, 0644)
+	groups, _ = scanner.ScanDuplicates(func(string) {})
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("ScanDuplicates() = %+v, want one group of 2", groups)
+	}
+	if groups[0].Hash != utils.FileHash(filepath.Join(tmpDir, "a.zip")) {
+		t.Errorf("group hash = %s, want full-content hash", groups[0].Hash)
+	}
+}
+
+func TestScanDuplicates_HashCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := make([]byte, 2*1024*1024)
+	os.WriteFile(filepath.Join(tmpDir, "a.bin"), content, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.bin"), content, 0644)
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	scanner.Hashes = hashcache.New(filepath.Join(tmpDir, "cache", "hashcache.json"))
+	first, _ := scanner.ScanDuplicates(func(string) {})
+	if len(first) != 1 || scanner.Hashes.Len() != 2 {
+		t.Fatalf("ScanDuplicates() = %+v with %d cached files, want one group and 2", first, scanner.Hashes.Len())
+	}
+
+	second, _ := scanner.ScanDuplicates(func(string) {})
+	if len(second) != 1 || second[0].Hash != first[0].Hash {
+		t.Errorf("second ScanDuplicates() = %+v, want the same group as %+v", second, first)
+	}
+}
+
+func TestScanBigFilesContext_Cancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "big.bin"), make([]byte, 2048), 0644)
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, err := scanner.ScanBigFilesContext(ctx, 1024, func(string) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanBigFilesContext() error = %v, want context.Canceled", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("ScanBigFilesContext() found %d files after cancellation", len(files))
+	}
+}
+
+func TestScanOldFiles_Scope(t *testing.T) {
+	tmpDir := t.TempDir()
+	old := time.Now().AddDate(0, 0, -400)
+	for _, name := range []string{"keep.txt", "vendor/lib.go", ".hidden", "skip.iso"} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+		os.Chtimes(path, old, old)
+	}
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	scanner.Scope.Exclude = append(scanner.Scope.Exclude, "*.iso")
+
+	files := scanner.ScanOldFiles(365, func(string) {})
+	if len(files) != 1 || filepath.Base(files[0].Path) != "keep.txt" {
+		t.Errorf("ScanOldFiles() = %+v, want only keep.txt", files)
+	}
+
+	scanner.Scope.Hidden = true
+	if files := scanner.ScanOldFiles(365, func(string) {}); len(files) != 2 {
+		t.Errorf("ScanOldFiles() with hidden files = %d files, want 2", len(files))
+	}
+}
+
+func TestScanOldFiles_By(t *testing.T) {
+	dir := t.TempDir()
+
+	// Read long ago but written recently
+	path := filepath.Join(dir, "unread.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now().AddDate(0, 0, -400), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{dir}
+
+	files := scanner.ScanOldFiles(180, func(string) {})
+	if len(files) != 1 || files[0].Path != path {
+		t.Fatalf("ScanOldFiles() by atime = %v, want %s", files, path)
+	}
+	if files[0].LastAccess.After(time.Now().AddDate(0, 0, -399)) {
+		t.Errorf("LastAccess = %v, want the access time", files[0].LastAccess)
+	}
+
+	scanner.OldFilesBy = models.FileTimeModified
+	if files := scanner.ScanOldFiles(180, func(string) {}); len(files) != 0 {
+		t.Errorf("ScanOldFiles() by mtime = %v, want none", files)
+	}
+}
+
+func TestCalculateSize_HardLinksAndModes(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "a.bin")
+	if err := os.WriteFile(original, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(original, filepath.Join(dir, "b.bin")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	scanner := New(utils.NewSudoManager())
+	pattern := filepath.Join(dir, "*.bin")
+	if got := scanner.CalculateSize(pattern); got != 1000 {
+		t.Errorf("CalculateSize(apparent) = %d, want 1000 (links counted once)", got)
+	}
+
+	info, err := os.Stat(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SizeMode = utils.SizeAllocated
+	if got, want := scanner.CalculateSize(pattern), utils.FileSize(info, utils.SizeAllocated); got != want {
+		t.Errorf("CalculateSize(allocated) = %d, want %d", got, want)
+	}
+}
+
+func TestChooseKeep(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	dir := t.TempDir()
+	old := filepath.Join(dir, "archive", "old", "photo.jpg")
+	recent := filepath.Join(dir, "b.jpg")
+	for _, path := range []string{old, recent} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, past, past)
+
+	downloads := filepath.Join(home, "Downloads", "photo.jpg")
+	documents := filepath.Join(home, "Documents", "photos", "photo.jpg")
+
+	tests := []struct {
+		policy    models.KeepPolicy
+		preferred []string
+		files     []string
+		want      string
+	}{
+		{models.KeepNotDownloads, nil, []string{downloads, documents}, documents},
+		{models.KeepNewest, nil, []string{old, recent}, recent},
+		{models.KeepOldest, nil, []string{recent, old}, old},
+		{models.KeepShortest, nil, []string{old, recent}, recent},
+		{models.KeepPreferred, []string{filepath.Join(dir, "archive"), "~/Documents"}, []string{documents, recent, old}, old},
+		{models.KeepPreferred, []string{"~/Pictures"}, []string{downloads, documents}, documents},
+	}
+	for _, tt := range tests {
+		groups := []models.DuplicateGroup{{Files: tt.files}}
+		ChooseKeep(groups, tt.policy, tt.preferred)
+		if kept := groups[0].Kept(); len(kept) != 1 || kept[0] != tt.want {
+			t.Errorf("%s: kept %v, want %s", tt.policy, kept, tt.want)
+		}
+	}
+}
+
+// writePNG writes a w x h image of a 4x4 checkerboard, inverted if invert
+func writePNG(t *testing.T, path string, w, h int, invert bool) {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			on := (x*4/w+y*4/h)%2 == 0
+			if on != invert {
+				img.SetGray(x, y, color.Gray{Y: 230})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 20})
+			}
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanSimilarImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	large := filepath.Join(tmpDir, "large.png")
+	small := filepath.Join(tmpDir, "small.png")
+	writePNG(t, large, 400, 400, false)
+	writePNG(t, small, 100, 100, false)
+	writePNG(t, filepath.Join(tmpDir, "inverted.png"), 400, 400, true)
+	os.WriteFile(filepath.Join(tmpDir, "broken.png"), []byte("not an image"), 0644)
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	for _, algo := range imagehash.Algorithms {
+		scanner.ImageHash = algo
+		groups, totalSize := scanner.ScanSimilarImages(func(string) {})
+		if len(groups) != 1 {
+			t.Fatalf("%s: ScanSimilarImages() = %+v, want one group", algo, groups)
+		}
+		g := groups[0]
+		if g.Kind != models.GroupSimilar || len(g.Files) != 2 || g.Files[0] != large || g.Files[1] != small {
+			t.Errorf("%s: group = %+v, want large then small", algo, g)
+		}
+		if kept := g.Kept(); len(kept) != 1 || kept[0] != large || totalSize != g.Sizes[1] {
+			t.Errorf("%s: kept %v, reclaimable %d; want the largest kept and the smaller freed", algo, kept, totalSize)
+		}
+	}
+}
+
+func TestClusterImages_NoChains(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Each hash is 3 bits from the next, but a and c are 6 bits apart
+	hashes := []uint64{0, 0b111, 0b111111}
+	var images []imageFile
+	for i, h := range hashes {
+		path := filepath.Join(tmpDir, string(rune('a'+i))+".png")
+		os.WriteFile(path, make([]byte, 300-100*i), 0644)
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, imageFile{entry: utils.FileEntry{Path: path, Info: info}, hash: h})
+	}
+
+	scanner := New(utils.NewSudoManager())
+	scanner.ImageDistance = 4
+	groups := scanner.clusterImages(images)
+	if len(groups) != 1 || len(groups[0].Files) != 2 || groups[0].Files[0] != images[0].entry.Path {
+		t.Fatalf("clusterImages() = %+v, want a and b only", groups)
+	}
+}
+
+func TestScanDuplicateDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	small := make([]byte, 700*1024)
+	large := make([]byte, 1536*1024)
+	different := make([]byte, 1536*1024)
+	different[0] = 1
+	writeTree := func(name string, b []byte) string {
+		dir := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+		os.WriteFile(filepath.Join(dir, "a.bin"), small, 0644)
+		os.WriteFile(filepath.Join(dir, "sub", "b.bin"), b, 0644)
+		return dir
+	}
+	projA := writeTree("projA", large)
+	projB := writeTree("projB copy", large)
+	writeTree("projC", different)
+	// Same files, but the excluded .git directory was not compared
+	projD := writeTree("projD", large)
+	os.MkdirAll(filepath.Join(projD, ".git"), 0755)
+	os.WriteFile(filepath.Join(projD, ".git", "HEAD"), []byte("ref"), 0644)
+	// Finder metadata that differs between copies does not keep them apart
+	os.WriteFile(filepath.Join(projA, ".DS_Store"), []byte("icon view"), 0644)
+	os.WriteFile(filepath.Join(projB, ".DS_Store"), []byte("list view, sorted by date"), 0644)
+	// AppleDouble files hold metadata that must match too
+	projE := writeTree("projE", large)
+	os.WriteFile(filepath.Join(projE, "sub", "._b.bin"), []byte("xattrs"), 0644)
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	groups, totalSize := scanner.ScanDuplicateDirs(func(string) {})
+	if len(groups) != 1 {
+		t.Fatalf("ScanDuplicateDirs() = %+v, want one group", groups)
+	}
+	g := groups[0]
+	want := int64(len(small) + len(large))
+	if g.Kind != models.GroupDirectory || len(g.Files) != 2 || g.Files[0] != projA || g.Files[1] != projB {
+		t.Errorf("group = %+v, want projA and projB, without their subdirectories", g)
+	}
+	if g.Size != want || totalSize != want {
+		t.Errorf("Size = %d, totalSize = %d; want the whole tree, %d", g.Size, totalSize, want)
+	}
+}
+
+func TestScanDuplicates_Filter(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.psd", "b.psd", "a.mov", "b.mov"} {
+		os.WriteFile(filepath.Join(tmpDir, name), []byte("small but duplicated"), 0644)
+	}
+
+	scanner := New(utils.NewSudoManager())
+	scanner.Scope.Roots = []string{tmpDir}
+	if groups, _ := scanner.ScanDuplicates(func(string) {}); len(groups) != 0 {
+		t.Errorf("default filter compared files under 1MB: %+v", groups)
+	}
+
+	scanner.Duplicates = models.DuplicateFilter{MinSize: 1, Extensions: []string{".psd"}}
+	groups, _ := scanner.ScanDuplicates(func(string) {})
+	if len(groups) != 1 || filepath.Ext(groups[0].Files[0]) != ".psd" {
+		t.Errorf("ScanDuplicates() = %+v, want only the .psd files", groups)
+	}
+
+	want := ".psd files of at least 1 B in " + tmpDir
+	if got := scanner.DescribeDuplicates(models.GroupIdentical); got != want {
+		t.Errorf("DescribeDuplicates() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"macos-cleaner/internal/imagehash"
+	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/utils"
+)
+
+// ScanSimilarImages finds JPEG, PNG and GIF images that look alike, such as
+// resized or re-exported copies, by comparing perceptual hashes computed with
+// s.ImageHash. Images are grouped only when every pair in the group differs
+// in at most s.ImageDistance bits, so whichever copy is kept each deleted one
+// looks like it. The files of a group are ordered largest first, as the
+// largest copy usually has the most detail.
+func (s *Scanner) ScanSimilarImages(progress func(status string)) ([]models.DuplicateGroup, int64) {
+	groups, totalSize, _ := s.ScanSimilarImagesContext(context.Background(), progress)
+	return groups, totalSize
+}
+
+// ScanSimilarImagesContext is ScanSimilarImages that stops once ctx is done.
+// The images hashed so far are grouped and returned together with ctx.Err().
+func (s *Scanner) ScanSimilarImagesContext(ctx context.Context, progress func(status string)) ([]models.DuplicateGroup, int64, error) {
+	entries, err := s.walk(ctx, s.DuplicateRoots(models.GroupSimilar), func(info os.FileInfo) bool {
+		return info.Size() > 0 && imagehash.Supported(info.Name())
+	}, progress)
+	if err != nil {
+		return nil, 0, err
+	}
+	progress(fmt.Sprintf("Found %d images, comparing them...", len(entries)))
+
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	// Undecodable images hash to "" and are left out
+	hashes, err := s.hashAll(ctx, paths, func(path string) string {
+		h, err := imagehash.File(path, s.ImageHash)
+		if err != nil {
+			return ""
+		}
+		return strconv.FormatUint(h, 16)
+	}, func(done int) {
+		if done%10 == 0 {
+			progress(fmt.Sprintf("Hashed %d/%d images...", done, len(paths)))
+		}
+	})
+
+	var images []imageFile
+	for i, h := range hashes {
+		if v, perr := strconv.ParseUint(h, 16, 64); perr == nil {
+			images = append(images, imageFile{entry: entries[i], hash: v})
+		}
+	}
+
+	groups := s.clusterImages(images)
+	ChooseKeep(groups, s.KeepPolicy, s.KeepPreferred)
+	var totalSize int64
+	for _, g := range groups {
+		totalSize += g.Reclaimable()
+	}
+	return groups, totalSize, err
+}
+
+// imageFile is a decoded image file and its perceptual hash
+type imageFile struct {
+	entry utils.FileEntry
+	hash  uint64
+}
+
+// clusterImages groups images whose hashes are all within s.ImageDistance of
+// each other and drops images that match no other. Images are taken largest
+// first: each one not yet grouped starts a group, which the smaller images
+// join when they are close to every member. Chains of images that each look
+// like the next are split rather than merged, since their ends may not look
+// alike at all.
+func (s *Scanner) clusterImages(images []imageFile) []models.DuplicateGroup {
+	sort.Slice(images, func(i, j int) bool {
+		a, b := images[i].entry, images[j].entry
+		if a.Info.Size() != b.Info.Size() {
+			return a.Info.Size() > b.Info.Size()
+		}
+		return a.Path < b.Path
+	})
+
+	// Comparing hashes is cheap enough that tens of thousands of images take
+	// well under a second even when every pair is compared
+	grouped := make([]bool, len(images))
+	var groups []models.DuplicateGroup
+	for i := range images {
+		if grouped[i] {
+			continue
+		}
+		imgs := []imageFile{images[i]}
+		for j := i + 1; j < len(images); j++ {
+			if !grouped[j] && s.closeToAll(images[j], imgs) {
+				imgs = append(imgs, images[j])
+				grouped[j] = true
+			}
+		}
+		if len(imgs) < 2 {
+			continue
+		}
+		g := models.DuplicateGroup{
+			Kind: models.GroupSimilar,
+			Hash: fmt.Sprintf("%s:%016x", s.ImageHash, imgs[0].hash),
+			Size: imgs[0].entry.Info.Size(),
+		}
+		for _, img := range imgs {
+			g.Files = append(g.Files, img.entry.Path)
+			g.Sizes = append(g.Sizes, img.entry.Info.Size())
+		}
+		groups = append(groups, g)
+	}
+	// Each group starts with the largest image left, so they are already
+	// ordered largest first
+	return groups
+}
+
+// closeToAll reports whether img is within s.ImageDistance of every one of imgs
+func (s *Scanner) closeToAll(img imageFile, imgs []imageFile) bool {
+	for _, other := range imgs {
+		if imagehash.Distance(img.hash, other.hash) > s.ImageDistance {
+			return false
+		}
+	}
+	return true
+}
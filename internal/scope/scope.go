@@ -0,0 +1,315 @@
+// Package scope decides which directories and files the finders look at
+package scope
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"macos-cleaner/internal/utils"
+)
+
+// IgnoreFileName is the gitignore-style file read from every scanned directory
+const IgnoreFileName = ".macleanerignore"
+
+// DefaultExclude is skipped by every finder unless the user's scope replaces it
+var DefaultExclude = []string{".git", "node_modules", "vendor", "Library"}
+
+// Scope is where the finders search and what they leave out
+type Scope struct {
+	Roots       []string // Directories to search; empty uses each finder's defaults
+	Exclude     []string // Gitignore-style patterns, see Matcher
+	Hidden      bool     // Include files and directories whose name starts with a dot
+	IgnoreFiles bool     // Honor .macleanerignore files found while walking
+}
+
+// Default returns the scope used when nothing is configured
+func Default() Scope {
+	return Scope{
+		Exclude:     append([]string(nil), DefaultExclude...),
+		IgnoreFiles: true,
+	}
+}
+
+// Validate reports every invalid exclude pattern
+func (s Scope) Validate() error {
+	var errs []error
+	for _, pattern := range s.Exclude {
+		if _, err := parseRule(pattern, ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RootsOr returns the scope's roots with ~ expanded, or defaults when none are set
+func (s Scope) RootsOr(defaults []string) []string {
+	if len(s.Roots) == 0 {
+		return defaults
+	}
+	roots := make([]string, 0, len(s.Roots))
+	for _, root := range s.Roots {
+		roots = append(roots, utils.ExpandPath(root))
+	}
+	return roots
+}
+
+// Matcher applies a scope to the paths below a set of roots. It is safe for
+// concurrent use.
+//
+// Exclude patterns and ignore files follow gitignore: blank lines and lines
+// starting with # are skipped, ! re-includes, a trailing / matches only
+// directories, * and ? stay within a path segment and ** spans segments.
+// A pattern without a slash matches a name at any depth; other patterns are
+// relative to the root (or the ignore file's directory). Exclude patterns
+// starting with ~/ or / are matched against the absolute path instead. When
+// several patterns match, the last one wins, with ignore files applied after
+// the exclude list and deeper ignore files after shallower ones.
+type Matcher struct {
+	scope   Scope
+	roots   []string
+	exclude []rule
+
+	mu      sync.Mutex
+	ignores map[string][]rule // Rules of each directory's ignore file
+}
+
+// Matcher compiles the scope for walking roots
+func (s Scope) Matcher(roots []string) (*Matcher, error) {
+	m := &Matcher{scope: s, ignores: make(map[string][]rule)}
+	for _, root := range roots {
+		m.roots = append(m.roots, filepath.Clean(root))
+	}
+	for _, pattern := range s.Exclude {
+		r, err := parseRule(pattern, "")
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			m.exclude = append(m.exclude, *r)
+		}
+	}
+	return m, nil
+}
+
+// SkipDir reports whether the directory at path should not be descended into
+func (m *Matcher) SkipDir(path string) bool {
+	return m.skip(path, true)
+}
+
+// SkipFile reports whether the file at path should be left out
+func (m *Matcher) SkipFile(path string) bool {
+	return m.skip(path, false)
+}
+
+func (m *Matcher) skip(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	if !m.scope.Hidden && strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+
+	root := m.rootOf(path)
+	rel := relPath(root, path)
+	excluded := false
+	for _, r := range m.exclude {
+		target := rel
+		if r.absolute {
+			target = path
+		}
+		if r.matches(target, isDir) {
+			excluded = !r.negate
+		}
+	}
+
+	if m.scope.IgnoreFiles && root != "" {
+		// Apply the ignore files from the root down to the parent of path
+		dirs := []string{root}
+		if parent := filepath.Dir(rel); parent != "." {
+			for _, part := range strings.Split(parent, "/") {
+				dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], part))
+			}
+		}
+		for _, dir := range dirs {
+			for _, r := range m.ignoreRules(dir) {
+				if r.matches(relPath(dir, path), isDir) {
+					excluded = !r.negate
+				}
+			}
+		}
+	}
+	return excluded
+}
+
+// rootOf returns the deepest root containing path, or "" when there is none
+func (m *Matcher) rootOf(path string) string {
+	best := ""
+	for _, root := range m.roots {
+		if (path == root || strings.HasPrefix(path, root+string(filepath.Separator)) || root == "/") && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// relPath returns path relative to dir with forward slashes
+func relPath(dir, path string) string {
+	if dir == "" {
+		return filepath.ToSlash(strings.TrimPrefix(path, "/"))
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ignoreRules loads and caches the ignore file of dir. Unreadable files and
+// invalid lines are ignored so one bad file does not stop a scan.
+func (m *Matcher) ignoreRules(dir string) []rule {
+	m.mu.Lock()
+	rules, ok := m.ignores[dir]
+	m.mu.Unlock()
+	if ok {
+		return rules
+	}
+
+	rules = readIgnoreFile(filepath.Join(dir, IgnoreFileName))
+
+	m.mu.Lock()
+	m.ignores[dir] = rules
+	m.mu.Unlock()
+	return rules
+}
+
+// readIgnoreFile parses an ignore file; a missing file has no rules
+func readIgnoreFile(path string) []rule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		r, err := parseRule(scanner.Text(), filepath.Dir(path))
+		if err == nil && r != nil {
+			rules = append(rules, *r)
+		}
+	}
+	return rules
+}
+
+// rule is one compiled pattern
+type rule struct {
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	absolute bool // Matched against the absolute path
+}
+
+func (r rule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(path)
+}
+
+// parseRule compiles one pattern line. It returns nil for blank lines and
+// comments. ignoreDir is set for lines read from an ignore file, where
+// absolute patterns are not allowed.
+func parseRule(line, ignoreDir string) (*rule, error) {
+	pattern := strings.TrimRight(line, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil, nil
+	}
+
+	r := &rule{}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid pattern %q", line)
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	if ignoreDir == "" && (strings.HasPrefix(pattern, "~/") || strings.HasPrefix(pattern, "/")) {
+		r.absolute = true
+		pattern = strings.TrimPrefix(filepath.ToSlash(utils.ExpandPath(pattern)), "/")
+	} else {
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	if r.absolute {
+		expr = "^/?" + strings.TrimPrefix(expr, "^")
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	r.re = re
+	return r, nil
+}
+
+// globToRegexp translates a gitignore glob into an unanchored expression
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?") // "**/" matches zero or more directories
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
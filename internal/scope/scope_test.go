@@ -0,0 +1,93 @@
+package scope
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Exclude(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	s := Scope{Exclude: []string{
+		"node_modules",
+		"*.iso",
+		"!keep.iso",
+		"build/",
+		"docs/**/*.tmp",
+		"~/Downloads/tmp",
+	}}
+	m, err := s.Matcher([]string{"/root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/root/a/node_modules", true, true},
+		{"/root/disk.iso", false, true},
+		{"/root/a/keep.iso", false, false},
+		{"/root/build", true, true},
+		{"/root/build", false, false}, // Directory-only pattern
+		{"/root/docs/x/y/z.tmp", false, true},
+		{"/root/other/docs/z.tmp", false, false}, // Anchored to the root
+		{"/root/.hidden", false, true},
+		{"/root/file.txt", false, false},
+		{filepath.Join(home, "Downloads/tmp"), true, true},
+	}
+	for _, tt := range tests {
+		if got := m.skip(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("skip(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_Hidden(t *testing.T) {
+	m, err := Scope{Hidden: true}.Matcher([]string{"/root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SkipFile("/root/.env") {
+		t.Error("hidden file skipped with Hidden set")
+	}
+}
+
+func TestMatcher_IgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "project", "cache"), 0755)
+	os.WriteFile(filepath.Join(root, IgnoreFileName), []byte("# top level\n*.log\n"), 0644)
+	os.WriteFile(filepath.Join(root, "project", IgnoreFileName), []byte("/cache/\n!important.log\n"), 0644)
+
+	m, err := Scope{IgnoreFiles: true}.Matcher([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.SkipFile(filepath.Join(root, "debug.log")) {
+		t.Error("root ignore file not applied")
+	}
+	if !m.SkipDir(filepath.Join(root, "project", "cache")) {
+		t.Error("nested ignore file not applied")
+	}
+	if m.SkipFile(filepath.Join(root, "project", "important.log")) {
+		t.Error("nested negation should override the parent ignore file")
+	}
+	if !m.SkipFile(filepath.Join(root, "project", "other.log")) {
+		t.Error("parent ignore file should apply to subdirectories")
+	}
+
+	off, _ := Scope{}.Matcher([]string{root})
+	if off.SkipFile(filepath.Join(root, "debug.log")) {
+		t.Error("ignore files applied with IgnoreFiles unset")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Scope{Exclude: []string{"[abc"}}).Validate(); err == nil {
+		t.Error("Validate() expected error for unterminated class")
+	}
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+}
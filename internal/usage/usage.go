@@ -0,0 +1,252 @@
+// Package usage builds directory size trees for the disk usage explorer
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"macos-cleaner/internal/utils"
+)
+
+// SortKey orders the children of a node
+type SortKey int
+
+const (
+	BySize    SortKey = iota // Largest first
+	ByCount                  // Most files first
+	ByModTime                // Most recently modified first
+)
+
+// String returns the name shown in the explorer
+func (k SortKey) String() string {
+	switch k {
+	case ByCount:
+		return "count"
+	case ByModTime:
+		return "modified"
+	}
+	return "size"
+}
+
+// Next returns the sort key that follows k, wrapping around
+func (k SortKey) Next() SortKey {
+	return (k + 1) % 3
+}
+
+// Node is a file or directory in a usage tree. Symlinks are files; they are
+// counted but not followed.
+type Node struct {
+	Name     string
+	Path     string
+	IsDir    bool
+	Size     int64     // Bytes of the file, or of every file below the directory
+	Count    int       // Files below the directory; 1 for a file
+	ModTime  time.Time // Newest modification time at or below the node
+	Parent   *Node
+	Children []*Node
+
+	read   bool      // Children have been listed
+	dirMod time.Time // Directory mtime when the children were listed
+}
+
+// Build walks the tree below root with the given number of workers (0 uses
+// NumCPU). progress receives the running count of files seen. Once ctx is
+// done the partial tree is returned together with ctx.Err().
+func Build(ctx context.Context, root string, workers int, progress func(scanned int)) (*Node, error) {
+	root = filepath.Clean(root)
+	node := &Node{Name: root, Path: root, IsDir: true}
+	err := Refresh(ctx, node, workers, progress)
+	return node, err
+}
+
+// Refresh brings node and everything below it up to date. Directories whose
+// modification time has not changed keep their cached listing and file
+// sizes, so only directories with added, removed or renamed entries are read
+// again. The sizes of node's ancestors are updated to match.
+func Refresh(ctx context.Context, node *Node, workers int, progress func(scanned int)) error {
+	w := &walker{ctx: ctx, progress: progress}
+	w.sem = make(chan struct{}, utils.Workers(workers)-1)
+
+	if node.IsDir {
+		w.refreshDir(node)
+	} else if info, err := os.Lstat(node.Path); err == nil {
+		node.setFile(info)
+	}
+	if node.Parent != nil {
+		node.Parent.updateAncestors()
+	}
+	return ctx.Err()
+}
+
+// Remove detaches node from its parent and updates the ancestors' sizes
+func (n *Node) Remove() {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	for i, child := range parent.Children {
+		if child == n {
+			parent.Children = append(parent.Children[:i:i], parent.Children[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+	parent.updateAncestors()
+}
+
+// Sorted returns the children of n ordered by key, ties broken by name
+func (n *Node) Sorted(key SortKey) []*Node {
+	children := append([]*Node(nil), n.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		a, b := children[i], children[j]
+		switch key {
+		case BySize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case ByCount:
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+		case ByModTime:
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+		}
+		return a.Name < b.Name
+	})
+	return children
+}
+
+// setFile fills a file node from its Lstat info
+func (n *Node) setFile(info os.FileInfo) {
+	n.IsDir = false
+	n.Size = info.Size()
+	n.Count = 1
+	n.ModTime = info.ModTime()
+	n.Children = nil
+}
+
+// sum recalculates a directory's totals from its children
+func (n *Node) sum() {
+	n.Size, n.Count = 0, 0
+	n.ModTime = n.dirMod
+	for _, child := range n.Children {
+		n.Size += child.Size
+		n.Count += child.Count
+		if child.ModTime.After(n.ModTime) {
+			n.ModTime = child.ModTime
+		}
+	}
+}
+
+// updateAncestors recalculates n and every directory above it
+func (n *Node) updateAncestors() {
+	for dir := n; dir != nil; dir = dir.Parent {
+		dir.sum()
+	}
+}
+
+// walker is the shared state of one Build or Refresh call
+type walker struct {
+	ctx      context.Context
+	sem      chan struct{} // Slots for extra goroutines beyond the caller's
+	progress func(scanned int)
+
+	mu      sync.Mutex
+	scanned int
+}
+
+// refreshDir lists dir unless its cached listing is current, then refreshes
+// the subdirectories concurrently while worker slots are free
+func (w *walker) refreshDir(dir *Node) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	info, err := os.Lstat(dir.Path)
+	if err != nil {
+		dir.Children = nil
+		dir.sum()
+		return
+	}
+	if !dir.read || !info.ModTime().Equal(dir.dirMod) {
+		dir.Children = w.list(dir)
+		dir.read = true
+		dir.dirMod = info.ModTime()
+	}
+
+	var files int
+	var wg sync.WaitGroup
+	for _, child := range dir.Children {
+		if !child.IsDir {
+			files++
+			continue
+		}
+		select {
+		case w.sem <- struct{}{}:
+			wg.Add(1)
+			go func(child *Node) {
+				defer wg.Done()
+				defer func() { <-w.sem }()
+				w.refreshDir(child)
+			}(child)
+		default:
+			w.refreshDir(child)
+		}
+	}
+	wg.Wait()
+	dir.sum()
+	w.report(files)
+}
+
+// list reads the entries of dir, reusing the nodes of entries that are
+// still there so their cached listings survive
+func (w *walker) list(dir *Node) []*Node {
+	entries, err := os.ReadDir(dir.Path)
+	if err != nil {
+		return nil
+	}
+
+	existing := make(map[string]*Node, len(dir.Children))
+	for _, child := range dir.Children {
+		existing[child.Name] = child
+	}
+
+	children := make([]*Node, 0, len(entries))
+	for _, entry := range entries {
+		child := existing[entry.Name()]
+		if child == nil || child.IsDir != entry.IsDir() {
+			child = &Node{
+				Name:   entry.Name(),
+				Path:   filepath.Join(dir.Path, entry.Name()),
+				IsDir:  entry.IsDir(),
+				Parent: dir,
+			}
+		}
+		if !entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			child.setFile(info)
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+// report adds files to the running count and calls progress
+func (w *walker) report(files int) {
+	if w.progress == nil || files == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.scanned += files
+	w.progress(w.scanned)
+}
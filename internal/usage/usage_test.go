@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func child(t *testing.T, n *Node, name string) *Node {
+	t.Helper()
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("%s has no child %q", n.Path, name)
+	return nil
+}
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.bin"), 100)
+	writeFile(t, filepath.Join(root, "docs", "b.bin"), 300)
+	writeFile(t, filepath.Join(root, "docs", "deep", "c.bin"), 50)
+	os.MkdirAll(filepath.Join(root, "empty"), 0755)
+
+	tree, err := Build(context.Background(), root, 2, nil)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if tree.Size != 450 || tree.Count != 3 {
+		t.Errorf("root = %d bytes in %d files, want 450 in 3", tree.Size, tree.Count)
+	}
+	docs := child(t, tree, "docs")
+	if docs.Size != 350 || docs.Count != 2 {
+		t.Errorf("docs = %d bytes in %d files, want 350 in 2", docs.Size, docs.Count)
+	}
+
+	var names []string
+	for _, n := range tree.Sorted(BySize) {
+		names = append(names, n.Name)
+	}
+	if want := []string{"docs", "a.bin", "empty"}; !equal(names, want) {
+		t.Errorf("Sorted(BySize) = %v, want %v", names, want)
+	}
+}
+
+func TestBuild_Cancelled(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.bin"), 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Build(ctx, root, 1, nil); err != context.Canceled {
+		t.Errorf("Build() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "docs", "b.bin"), 300)
+	writeFile(t, filepath.Join(root, "docs", "c.bin"), 50)
+
+	tree, _ := Build(context.Background(), root, 1, nil)
+	child(t, child(t, tree, "docs"), "b.bin").Remove()
+
+	if tree.Size != 50 || tree.Count != 1 {
+		t.Errorf("root after Remove = %d bytes in %d files, want 50 in 1", tree.Size, tree.Count)
+	}
+}
+
+func TestRefresh_ReusesUnchangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "stable", "a.bin"), 100)
+	writeFile(t, filepath.Join(root, "changing", "b.bin"), 100)
+
+	tree, _ := Build(context.Background(), root, 1, nil)
+	stable := child(t, tree, "stable")
+	cached := stable.Children[0]
+
+	// A new file changes the directory mtime; the stable directory is not reread
+	writeFile(t, filepath.Join(root, "changing", "c.bin"), 40)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(filepath.Join(root, "changing"), later, later)
+
+	var scanned int
+	if err := Refresh(context.Background(), tree, 1, func(n int) { scanned = n }); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if tree.Size != 240 || tree.Count != 3 {
+		t.Errorf("root after Refresh = %d bytes in %d files, want 240 in 3", tree.Size, tree.Count)
+	}
+	if child(t, tree, "stable") != stable || stable.Children[0] != cached {
+		t.Error("Refresh() replaced the nodes of an unchanged directory")
+	}
+	if scanned != 3 {
+		t.Errorf("progress = %d, want 3", scanned)
+	}
+}
+
+func TestRefresh_Subtree(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "docs", "a.bin"), 100)
+	writeFile(t, filepath.Join(root, "top.bin"), 10)
+
+	tree, _ := Build(context.Background(), root, 1, nil)
+	docs := child(t, tree, "docs")
+
+	os.Remove(filepath.Join(root, "docs", "a.bin"))
+	if err := Refresh(context.Background(), docs, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if docs.Size != 0 || tree.Size != 10 {
+		t.Errorf("sizes after Refresh = docs %d, root %d; want 0 and 10", docs.Size, tree.Size)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,7 @@
+package utils
+
+import "errors"
+
+// ErrCloneUnsupported is returned by CloneFile when the platform or the
+// volume cannot share blocks between files
+var ErrCloneUnsupported = errors.New("file clones are not supported here")
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloneFile creates dst as a copy-on-write clone of src with clonefile(2).
+// dst must not exist.
+func CloneFile(src, dst string) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return fmt.Errorf("clone %s: %w", src, ErrCloneUnsupported)
+	}
+	if err != nil {
+		return fmt.Errorf("clone %s: %w", src, err)
+	}
+	return nil
+}
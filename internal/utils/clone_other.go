@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package utils
+
+import "fmt"
+
+// CloneFile is not available on this platform
+func CloneFile(src, dst string) error {
+	return fmt.Errorf("clone %s: %w", src, ErrCloneUnsupported)
+}
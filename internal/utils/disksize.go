@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SizeMode selects how file sizes are counted
+type SizeMode int
+
+const (
+	SizeApparent  SizeMode = iota // Bytes of content, as ls -l shows
+	SizeAllocated                 // Blocks allocated on disk, what deleting frees
+)
+
+// ParseSizeMode parses "apparent" or "allocated"
+func ParseSizeMode(s string) (SizeMode, error) {
+	switch s {
+	case "apparent":
+		return SizeApparent, nil
+	case "allocated":
+		return SizeAllocated, nil
+	}
+	return 0, fmt.Errorf("unknown size mode %q (use apparent or allocated)", s)
+}
+
+// String returns the name accepted by ParseSizeMode
+func (m SizeMode) String() string {
+	if m == SizeAllocated {
+		return "allocated"
+	}
+	return "apparent"
+}
+
+// FileSize returns the size of info in mode. Allocated sizes fall back to
+// the apparent size where the platform does not report blocks.
+func FileSize(info os.FileInfo, mode SizeMode) int64 {
+	if mode == SizeAllocated {
+		if size, ok := allocatedSize(info); ok {
+			return size
+		}
+	}
+	return info.Size()
+}
+
+// fileID identifies a file across its hard links
+type fileID struct {
+	dev, ino uint64
+}
+
+// SizeCounter sums file sizes in one mode, counting every hard-linked file
+// once no matter how many of its links are added. It is safe for
+// concurrent use.
+type SizeCounter struct {
+	Mode SizeMode
+
+	mu   sync.Mutex
+	seen map[fileID]bool
+}
+
+// NewSizeCounter creates a SizeCounter for mode
+func NewSizeCounter(mode SizeMode) *SizeCounter {
+	return &SizeCounter{Mode: mode, seen: make(map[fileID]bool)}
+}
+
+// Add returns the size of info, or 0 when another link to the same file was
+// counted before
+func (c *SizeCounter) Add(info os.FileInfo) int64 {
+	if id, ok := hardLinkID(info); ok {
+		c.mu.Lock()
+		seen := c.seen[id]
+		c.seen[id] = true
+		c.mu.Unlock()
+		if seen {
+			return 0
+		}
+	}
+	return FileSize(info, c.Mode)
+}
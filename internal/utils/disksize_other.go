@@ -0,0 +1,18 @@
+//go:build !darwin && !linux && !freebsd
+
+package utils
+
+import "os"
+
+func allocatedSize(os.FileInfo) (int64, bool) {
+	return 0, false
+}
+
+func hardLinkID(os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// Inode returns 0; inode numbers are not available on this platform
+func Inode(os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSize_HardLinks(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original.bin")
+	if err := os.WriteFile(original, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(original, filepath.Join(dir, "link.bin")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	if got := DirSize(dir); got != 1000 {
+		t.Errorf("DirSize() = %d, want 1000 (hard link counted once)", got)
+	}
+}
+
+func TestFileSize_Sparse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Truncate(64 << 20); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FileSize(info, SizeApparent); got != 64<<20 {
+		t.Errorf("FileSize(apparent) = %d, want %d", got, 64<<20)
+	}
+	if got := FileSize(info, SizeAllocated); got >= 64<<20 {
+		t.Errorf("FileSize(allocated) = %d, want less than the apparent size of a sparse file", got)
+	}
+}
+
+func TestParseSizeMode(t *testing.T) {
+	for _, mode := range []SizeMode{SizeApparent, SizeAllocated} {
+		got, err := ParseSizeMode(mode.String())
+		if err != nil || got != mode {
+			t.Errorf("ParseSizeMode(%q) = %v, %v", mode.String(), got, err)
+		}
+	}
+	if _, err := ParseSizeMode("logical"); err == nil {
+		t.Error("ParseSizeMode(\"logical\") expected error")
+	}
+}
@@ -0,0 +1,36 @@
+//go:build darwin || linux || freebsd
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocatedSize returns the bytes allocated to info; st_blocks is always in
+// 512-byte units
+func allocatedSize(info os.FileInfo) (int64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int64(st.Blocks) * 512, true
+}
+
+// hardLinkID returns the identity of a file with more than one link
+func hardLinkID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 || info.IsDir() {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// Inode returns the inode number of info, or 0 when it is unknown
+func Inode(info os.FileInfo) uint64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(st.Ino)
+}
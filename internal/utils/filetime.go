@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"time"
+)
+
+// FileTimes holds the timestamps the platform keeps for a file. Accessed,
+// Changed and Created are zero when the platform does not expose them.
+type FileTimes struct {
+	Modified time.Time // Content last written
+	Accessed time.Time // Content last read, subject to the volume's atime policy
+	Changed  time.Time // Inode last changed (ctime)
+	Created  time.Time // Birth time
+}
+
+// Times returns the timestamps of info
+func Times(info os.FileInfo) FileTimes {
+	times := statTimes(info)
+	times.Modified = info.ModTime()
+	return times
+}
+
+// AtimePolicy describes how a volume updates file access times
+type AtimePolicy int
+
+const (
+	AtimeUnknown  AtimePolicy = iota
+	AtimeStrict               // Every read updates the access time
+	AtimeRelaxed              // Some updates are skipped (relatime); reads can go unrecorded
+	AtimeDisabled             // Mounted noatime; access times are never updated
+)
+
+// String returns the mount option name of the policy
+func (p AtimePolicy) String() string {
+	switch p {
+	case AtimeStrict:
+		return "strictatime"
+	case AtimeRelaxed:
+		return "relatime"
+	case AtimeDisabled:
+		return "noatime"
+	}
+	return "unknown"
+}
+
+// VolumeAtimePolicy returns the atime policy of the volume holding path
+func VolumeAtimePolicy(path string) AtimePolicy {
+	return volumeAtimePolicy(path)
+}
+
+func timespec(sec, nsec int64) time.Time {
+	if sec == 0 && nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, nsec)
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func statTimes(info os.FileInfo) FileTimes {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileTimes{}
+	}
+	return FileTimes{
+		Accessed: timespec(st.Atimespec.Unix()),
+		Changed:  timespec(st.Ctimespec.Unix()),
+		Created:  timespec(st.Birthtimespec.Unix()),
+	}
+}
+
+func volumeAtimePolicy(path string) AtimePolicy {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return AtimeUnknown
+	}
+	switch {
+	case fs.Flags&unix.MNT_NOATIME != 0:
+		return AtimeDisabled
+	case fs.Flags&unix.MNT_STRICTATIME != 0:
+		return AtimeStrict
+	}
+	// Without strictatime macOS skips access time updates it deems redundant
+	return AtimeRelaxed
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// statfs(2) f_flags bits
+const (
+	stNoatime  = 0x400
+	stRelatime = 0x1000
+)
+
+func statTimes(info os.FileInfo) FileTimes {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileTimes{}
+	}
+	// Birth time needs statx; the age filter falls back to mtime without it
+	return FileTimes{
+		Accessed: timespec(st.Atim.Unix()),
+		Changed:  timespec(st.Ctim.Unix()),
+	}
+}
+
+func volumeAtimePolicy(path string) AtimePolicy {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return AtimeUnknown
+	}
+	switch {
+	case fs.Flags&stNoatime != 0:
+		return AtimeDisabled
+	case fs.Flags&stRelatime != 0:
+		return AtimeRelaxed
+	}
+	return AtimeStrict
+}
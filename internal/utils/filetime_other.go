@@ -0,0 +1,13 @@
+//go:build !darwin && !freebsd && !linux
+
+package utils
+
+import "os"
+
+func statTimes(os.FileInfo) FileTimes {
+	return FileTimes{}
+}
+
+func volumeAtimePolicy(string) AtimePolicy {
+	return AtimeUnknown
+}
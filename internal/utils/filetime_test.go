@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	atime := time.Now().AddDate(0, 0, -300).Truncate(time.Second)
+	mtime := time.Now().AddDate(0, 0, -10).Truncate(time.Second)
+	if err := os.Chtimes(path, atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := Times(info)
+	if !times.Modified.Equal(mtime) {
+		t.Errorf("Modified = %v, want %v", times.Modified, mtime)
+	}
+	if times.Accessed.IsZero() {
+		t.Skip("platform does not expose access times")
+	}
+	if !times.Accessed.Equal(atime) {
+		t.Errorf("Accessed = %v, want %v", times.Accessed, atime)
+	}
+	if times.Changed.Before(mtime) {
+		t.Errorf("Changed = %v, want after the last modification %v", times.Changed, mtime)
+	}
+}
+
+func TestAtimePolicy_String(t *testing.T) {
+	tests := map[AtimePolicy]string{
+		AtimeStrict:   "strictatime",
+		AtimeRelaxed:  "relatime",
+		AtimeDisabled: "noatime",
+		AtimeUnknown:  "unknown",
+	}
+	for policy, want := range tests {
+		if got := policy.String(); got != want {
+			t.Errorf("AtimePolicy(%d).String() = %q, want %q", policy, got, want)
+		}
+	}
+}
@@ -2,8 +2,9 @@
 package utils
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,36 +19,89 @@ func ExpandPath(path string) string {
 	return path
 }
 
-// FileHash computes a fast hash of a file (first 4KB only)
-func FileHash(path string) string {
+// ContractPath replaces the home directory at the start of path with ~,
+// undoing ExpandPath for display
+func ContractPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+"/"); ok {
+		return "~/" + rest
+	}
+	return path
+}
+
+// DataDir returns the directory MaCleaner keeps its own state in
+// ($MACLEANER_DATA_DIR overrides the default)
+func DataDir() string {
+	if dir := os.Getenv("MACLEANER_DATA_DIR"); dir != "" {
+		return ExpandPath(dir)
+	}
+	return ExpandPath("~/Library/Application Support/MaCleaner")
+}
+
+// partialHashChunk is how much of each end of a file PartialHash reads
+const partialHashChunk = 4096
+
+// PartialHash computes a cheap fingerprint of a file from its size and its
+// first and last 4KB. Files with different fingerprints cannot be identical;
+// files with the same fingerprint still need FileHash to confirm.
+func PartialHash(path string) string {
 	file, err := os.Open(path)
 	if err != nil {
 		return ""
 	}
 	defer file.Close()
 
-	// Only hash first 4KB for speed
-	h := md5.New()
-	buf := make([]byte, 4096)
-	n, _ := file.Read(buf)
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:", info.Size())
+	buf := make([]byte, partialHashChunk)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return ""
+	}
 	h.Write(buf[:n])
 
+	if tail := info.Size() - partialHashChunk; tail > 0 {
+		n, err = file.ReadAt(buf, tail)
+		if err != nil && err != io.EOF {
+			return ""
+		}
+		h.Write(buf[:n])
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// FileHash computes the SHA-256 of a file's full content
+func FileHash(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return ""
+	}
+
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
 // DirSize calculates the total size of a directory by walking all files
+// with one worker per CPU
 func DirSize(path string) int64 {
-	var size int64
-	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return nil
-	})
-	return size
+	return DirSizeWorkers(path, 0)
 }
 
 // FileExists checks if a file or directory exists
@@ -65,29 +119,68 @@ func IsDirectory(path string) bool {
 	return info.IsDir()
 }
 
-// SafeGlob performs a safer glob that handles nested directories better
-// than standard filepath.Glob for patterns like ~/Library/Caches/*
+// SafeGlob expands pattern like filepath.Glob after expanding ~, leaving out
+// matches that symlinked directories place outside the pattern's base
+// directory (see GlobBase). Symlinks among the matches themselves are
+// returned as links and never followed.
 func SafeGlob(pattern string) ([]string, error) {
 	expanded := ExpandPath(pattern)
+	matches, err := filepath.Glob(expanded)
+	if err != nil {
+		return nil, err
+	}
 
-	// If pattern ends with /*, we need to find all matching directories recursively
-	if strings.HasSuffix(pattern, "/*") {
-		baseDir := strings.TrimSuffix(expanded, "/*")
-		var matches []string
-		err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // Continue on error
-			}
-			// Add the path itself if it's a file or directory in the base
-			if path != baseDir {
-				matches = append(matches, path)
+	base := GlobBase(expanded)
+	kept := matches[:0]
+	for _, match := range matches {
+		if Within(match, base) {
+			kept = append(kept, match)
+		}
+	}
+	return kept, nil
+}
+
+// GlobBase returns the directory a glob pattern is rooted at: its leading
+// path elements up to the first one containing a glob metacharacter. A
+// pattern without metacharacters is its own base.
+func GlobBase(pattern string) string {
+	pattern = filepath.Clean(pattern)
+	elems := strings.Split(pattern, string(filepath.Separator))
+	for i, elem := range elems {
+		if strings.ContainsAny(elem, "*?[\\") {
+			base := strings.Join(elems[:i], string(filepath.Separator))
+			if base == "" {
+				return string(filepath.Separator)
 			}
-			return nil
-		})
-		return matches, err
+			return base
+		}
 	}
+	return pattern
+}
 
-	return filepath.Glob(expanded)
+// Within reports whether the directory entry at path lies inside base once
+// symlinks in base and in path's parent directories are resolved. path
+// itself is not resolved, so a symlink inside base is within it wherever it
+// points; deleting it removes only the link. base itself is always within.
+func Within(path, base string) bool {
+	path, base = filepath.Clean(path), filepath.Clean(base)
+	if path == base {
+		return true
+	}
+
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return false
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(realBase, filepath.Join(realParent, filepath.Base(path)))
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 // ShortenPath creates a shortened version of a path for display
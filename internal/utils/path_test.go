@@ -29,6 +29,33 @@ func TestExpandPath(t *testing.T) {
 	}
 }
 
+func TestContractPath(t *testing.T) {
+	t.Setenv("HOME", "/Users/me")
+	for in, want := range map[string]string{
+		"/Users/me":           "~",
+		"/Users/me/Documents": "~/Documents",
+		"/Users/meg/Desktop":  "/Users/meg/Desktop",
+		"/Volumes/Photos":     "/Volumes/Photos",
+	} {
+		if got := ContractPath(in); got != want {
+			t.Errorf("ContractPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	t.Setenv("MACLEANER_DATA_DIR", "/tmp/macleaner-data")
+	if got := DataDir(); got != "/tmp/macleaner-data" {
+		t.Errorf("DataDir() = %q, want env override", got)
+	}
+
+	t.Setenv("MACLEANER_DATA_DIR", "")
+	home, _ := os.UserHomeDir()
+	if got := DataDir(); got != filepath.Join(home, "Library/Application Support/MaCleaner") {
+		t.Errorf("DataDir() = %q", got)
+	}
+}
+
 func TestFileExists(t *testing.T) {
 	// Create temp file
 	tmpFile, err := os.CreateTemp("", "test")
@@ -175,3 +202,84 @@ func TestDirSize(t *testing.T) {
 		t.Errorf("DirSize() = %d, want %d", size, expectedTotal)
 	}
 }
+
+func TestPartialHash(t *testing.T) {
+	dir := t.TempDir()
+	head := make([]byte, 3*4096)
+	middle := make([]byte, 3*4096)
+	middle[4096+10] = 1
+	tail := make([]byte, 3*4096)
+	tail[len(tail)-1] = 1
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	headPath, middlePath, tailPath := write("head", head), write("middle", middle), write("tail", tail)
+
+	if PartialHash(headPath) != PartialHash(middlePath) {
+		t.Error("PartialHash should only read the head and tail")
+	}
+	if PartialHash(headPath) == PartialHash(tailPath) {
+		t.Error("PartialHash should differ when the tail differs")
+	}
+	if FileHash(headPath) == FileHash(middlePath) {
+		t.Error("FileHash should cover the full content")
+	}
+	if PartialHash("/non/existent/file") != "" {
+		t.Error("PartialHash should return empty string for non-existing file")
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := map[string]string{
+		"/Users/me/Library/Caches/*":                   "/Users/me/Library/Caches",
+		"/Users/me/Library/Containers/*/Data/Caches/*": "/Users/me/Library/Containers",
+		"/Users/me/Library/Logs":                       "/Users/me/Library/Logs",
+		"/*":                                           "/",
+	}
+	for pattern, want := range tests {
+		if got := GlobBase(pattern); got != want {
+			t.Errorf("GlobBase(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestSafeGlob_SymlinkOutsideBase(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(root, "outside")
+	base := filepath.Join(root, "Containers")
+	for _, dir := range []string{
+		filepath.Join(outside, "Caches"),
+		filepath.Join(base, "good", "Data", "Caches"),
+		filepath.Join(base, "evil"),
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(outside, "Caches", "precious"), nil, 0644)
+	os.WriteFile(filepath.Join(base, "good", "Data", "Caches", "cache.db"), nil, 0644)
+	if err := os.Symlink(outside, filepath.Join(base, "evil", "Data")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	matches, err := SafeGlob(filepath.Join(base, "*", "Data", "Caches", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(base, "good", "Data", "Caches", "cache.db")
+	if len(matches) != 1 || matches[0] != want {
+		t.Errorf("SafeGlob() = %v, want only %s", matches, want)
+	}
+
+	// A symlink directly inside the base is within it; deleting it removes the link
+	link := filepath.Join(base, "link")
+	os.Symlink(outside, link)
+	if !Within(link, base) {
+		t.Errorf("Within(%q, %q) = false, want true", link, base)
+	}
+}
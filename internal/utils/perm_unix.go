@@ -0,0 +1,45 @@
+//go:build darwin || linux || freebsd
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// CanRemove reports whether the current user may unlink path. Sticky
+// directories aside, that only depends on write and search permission on
+// the parent directory.
+func CanRemove(path string) bool {
+	return unix.Access(filepath.Dir(path), unix.W_OK|unix.X_OK) == nil
+}
+
+// SameOwnerAndMode reports whether two files have the same permission bits
+// and owner, so one can stand in for the other without changing who may
+// read or write it
+func SameOwnerAndMode(a, b os.FileInfo) bool {
+	sa, okA := a.Sys().(*syscall.Stat_t)
+	sb, okB := b.Sys().(*syscall.Stat_t)
+	if !okA || !okB {
+		return false
+	}
+	return a.Mode() == b.Mode() && sa.Uid == sb.Uid && sa.Gid == sb.Gid
+}
+
+// CopyMetadata gives path the permission bits, owner and access and
+// modification times recorded in info
+func CopyMetadata(path string, info os.FileInfo) error {
+	if err := os.Chmod(path, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(path, int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	times := Times(info)
+	return os.Chtimes(path, times.Accessed, times.Modified)
+}
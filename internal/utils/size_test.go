@@ -0,0 +1,56 @@
+package utils
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		input int64
+		want  string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{100 * 1024 * 1024, "100.0 MB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatBytes(tt.input); got != tt.want {
+				t.Errorf("FormatBytes(%d) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"2048", 2048, false},
+		{"100KB", 100 * 1024, false},
+		{"500MB", 500 * 1024 * 1024, false},
+		{"500m", 500 * 1024 * 1024, false},
+		{"1.5G", int64(1.5 * 1024 * 1024 * 1024), false},
+		{"2 GB", 2 * 1024 * 1024 * 1024, false},
+		{"10B", 10, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"-5MB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
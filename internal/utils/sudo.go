@@ -2,6 +2,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,12 +10,20 @@
 	"time"
 )
 
+// ErrSudoRefused is returned when administrator rights are needed but the
+// user did not grant them
+var ErrSudoRefused = errors.New("sudo authentication failed")
+
 // SudoManager handles sudo authentication and keeps it alive
 type SudoManager struct {
 	mu       sync.Mutex
 	HasSudo  bool
 	LastAuth time.Time
 	Timeout  time.Duration
+
+	// Suspend, when set, wraps the password prompt so a caller holding the
+	// terminal (e.g. in raw mode) can release it while sudo reads stdin
+	Suspend func(prompt func() error) error
 }
 
 // NewSudoManager creates a new SudoManager
@@ -40,17 +49,26 @@ func (s *SudoManager) EnsureSudo() error {
 	}
 
 	// Need to authenticate - this will prompt for password once
-	fmt.Println("\n🔐 Some operations require administrator privileges.")
-	fmt.Println("   Please enter your password (will be cached for 5 minutes):")
-
-	cmd := exec.Command("sudo", "-v") // Validate credentials
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	prompt := func() error {
+		fmt.Println("\n🔐 Some operations require administrator privileges.")
+		fmt.Println("   Please enter your password (will be cached for 5 minutes):")
+
+		cmd := exec.Command("sudo", "-v") // Validate credentials
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
 
-	if err := cmd.Run(); err != nil {
+	var err error
+	if s.Suspend != nil {
+		err = s.Suspend(prompt)
+	} else {
+		err = prompt()
+	}
+	if err != nil {
 		s.HasSudo = false
-		return fmt.Errorf("sudo authentication failed")
+		return ErrSudoRefused
 	}
 
 	s.HasSudo = true
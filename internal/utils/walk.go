@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// FileEntry is a file found by Walk
+type FileEntry struct {
+	Path string
+	Info os.FileInfo
+}
+
+// WalkOptions configures Walk
+type WalkOptions struct {
+	// Workers is the number of directories read concurrently; 0 uses NumCPU
+	Workers int
+	// SkipDir prunes a directory below the roots. It is called concurrently.
+	SkipDir func(path string) bool
+	// Keep decides whether a file is returned. It is called concurrently.
+	// A nil Keep keeps every file.
+	Keep func(path string, info os.FileInfo) bool
+	// Progress is called with the running count of files seen, in order and
+	// from one goroutine at a time
+	Progress func(scanned int)
+}
+
+// Workers returns n, or the number of CPUs when n is not positive
+func Workers(n int) int {
+	if n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// Walk reads the directory trees under roots with a bounded pool of workers
+// and returns the kept files sorted by path, so the result does not depend
+// on scheduling. Symlinks are reported but not followed and unreadable
+// directories are skipped, like filepath.Walk with an ignoring callback.
+func Walk(roots []string, opts WalkOptions) []FileEntry {
+	files, _ := WalkContext(context.Background(), roots, opts)
+	return files
+}
+
+// WalkContext is Walk that stops reading directories once ctx is done. The
+// files found so far are returned together with ctx.Err().
+func WalkContext(ctx context.Context, roots []string, opts WalkOptions) ([]FileEntry, error) {
+	w := &walker{ctx: ctx, opts: opts}
+	w.cond = sync.NewCond(&w.mu)
+
+	for _, root := range roots {
+		info, err := os.Lstat(root)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			w.pending = append(w.pending, root)
+		} else {
+			w.visit([]FileEntry{{Path: root, Info: info}})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < Workers(opts.Workers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(w.files, func(i, j int) bool {
+		return w.files[i].Path < w.files[j].Path
+	})
+	return w.files, ctx.Err()
+}
+
+// walker is the shared state of one Walk call
+type walker struct {
+	ctx  context.Context
+	opts WalkOptions
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []string // Directories waiting to be read
+	active  int      // Directories being read
+	scanned int
+	files   []FileEntry
+}
+
+// work reads queued directories until none are left and none are in flight
+func (w *walker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.pending) == 0 && w.active > 0 && w.ctx.Err() == nil {
+			w.cond.Wait()
+		}
+		// Once cancelled, workers finish the directory in hand and stop
+		if len(w.pending) == 0 || w.ctx.Err() != nil {
+			w.mu.Unlock()
+			w.cond.Broadcast()
+			return
+		}
+		dir := w.pending[len(w.pending)-1]
+		w.pending = w.pending[:len(w.pending)-1]
+		w.active++
+		w.mu.Unlock()
+
+		subdirs, files := w.readDir(dir)
+
+		w.mu.Lock()
+		w.pending = append(w.pending, subdirs...)
+		w.active--
+		w.mu.Unlock()
+		w.visit(files)
+		w.cond.Broadcast()
+	}
+}
+
+// readDir lists dir, returning the subdirectories to descend into and its files
+func (w *walker) readDir(dir string) ([]string, []FileEntry) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+
+	var subdirs []string
+	var files []FileEntry
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if w.opts.SkipDir == nil || !w.opts.SkipDir(path) {
+				subdirs = append(subdirs, path)
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileEntry{Path: path, Info: info})
+	}
+	return subdirs, files
+}
+
+// visit filters files with Keep and reports progress
+func (w *walker) visit(files []FileEntry) {
+	var kept []FileEntry
+	for _, f := range files {
+		if w.opts.Keep == nil || w.opts.Keep(f.Path, f.Info) {
+			kept = append(kept, f)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files = append(w.files, kept...)
+	for range files {
+		w.scanned++
+		if w.opts.Progress != nil {
+			w.opts.Progress(w.scanned)
+		}
+	}
+}
+
+// DirSizeWorkers calculates the apparent size of a directory with the given
+// number of workers (0 uses NumCPU). Hard-linked files are counted once.
+func DirSizeWorkers(path string, workers int) int64 {
+	size, _ := DirSizeContext(context.Background(), path, workers)
+	return size
+}
+
+// DirSizeContext is DirSizeWorkers that stops once ctx is done, returning
+// the size counted so far together with ctx.Err()
+func DirSizeContext(ctx context.Context, path string, workers int) (int64, error) {
+	return DirUsageContext(ctx, path, workers, NewSizeCounter(SizeApparent))
+}
+
+// DirUsageContext adds the files below path to counter and returns their
+// size. Sharing a counter across calls counts files hard-linked into several
+// directories once.
+func DirUsageContext(ctx context.Context, path string, workers int, counter *SizeCounter) (int64, error) {
+	var size atomic.Int64
+	_, err := WalkContext(ctx, []string{path}, WalkOptions{
+		Workers: workers,
+		Keep: func(_ string, info os.FileInfo) bool {
+			size.Add(counter.Add(info))
+			return false
+		},
+	})
+	return size.Load(), err
+}
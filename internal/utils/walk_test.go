@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"b/2.txt", "a/1.txt", "a/deep/3.txt", ".git/objects/x", "top.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var counts []int
+	entries := Walk([]string{root, filepath.Join(root, "missing")}, WalkOptions{
+		Workers:  4,
+		SkipDir:  func(path string) bool { return filepath.Base(path) == ".git" },
+		Keep:     func(path string, _ os.FileInfo) bool { return filepath.Base(path) != "top.txt" },
+		Progress: func(scanned int) { counts = append(counts, scanned) },
+	})
+
+	want := []string{"a/1.txt", "a/deep/3.txt", "b/2.txt"}
+	if len(entries) != len(want) {
+		t.Fatalf("Walk() returned %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Path != filepath.Join(root, want[i]) {
+			t.Errorf("entry %d = %s, want %s", i, entry.Path, want[i])
+		}
+	}
+
+	// top.txt is counted even though Keep drops it; .git is never read
+	if len(counts) != 4 {
+		t.Fatalf("Progress called %d times, want 4", len(counts))
+	}
+	for i, n := range counts {
+		if n != i+1 {
+			t.Errorf("Progress call %d reported %d", i, n)
+		}
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	if Workers(3) != 3 {
+		t.Error("Workers(3) should keep an explicit value")
+	}
+	if Workers(0) < 1 {
+		t.Error("Workers(0) should default to the CPU count")
+	}
+}
+
+func TestWalkContext_Cancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	entries, err := WalkContext(ctx, []string{root}, WalkOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WalkContext() error = %v, want context.Canceled", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("WalkContext() read %d files after cancellation", len(entries))
+	}
+}
@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"macos-cleaner/internal/cleaner"
+	"macos-cleaner/internal/config"
+	"macos-cleaner/internal/filetype"
+	"macos-cleaner/internal/hashcache"
+	"macos-cleaner/internal/history"
+	"macos-cleaner/internal/imagehash"
 	"macos-cleaner/internal/ltui"
 	"macos-cleaner/internal/models"
+	"macos-cleaner/internal/quarantine"
 	"macos-cleaner/internal/scanner"
+	"macos-cleaner/internal/scope"
+	"macos-cleaner/internal/usage"
 	"macos-cleaner/internal/utils"
 )
 
@@ -18,23 +33,40 @@ type app struct {
 	term            *ltui.Terminal
 	scanner         *scanner.Scanner
 	cleaner         *cleaner.Cleaner
+	history         *history.Store
 	targets         []models.CleanupTarget
 	bigFiles        []models.BigFile
 	duplicateGroups []models.DuplicateGroup
 	oldFiles        []models.OldFile
+	usageTree       *usage.Node // Kept between visits so refreshes reuse it
 
 	// State
 	cursor     int
 	selections map[int]bool
+	useTrash   bool
 }
 
-func newApp() *app {
+func newApp(set settings) *app {
 	sudoMgr := utils.NewSudoManager()
+	s := scanner.New(sudoMgr)
+	s.Scope = set.scope
+	s.KeepPolicy = set.keep
+	s.KeepPreferred = set.prefer
+	s.Duplicates = set.filter
+	s.ImageHash = set.imageHash
+	s.ImageDistance = set.distance
+	s.Hashes = hashcache.New(hashcache.DefaultPath())
+	// Show target sizes as the space a clean actually frees
+	s.SizeMode = utils.SizeAllocated
+	c := cleaner.New(sudoMgr)
+	c.SizeMode = utils.SizeAllocated
+	c.Protected = set.protected
 	return &app{
 		term:       ltui.NewTerminal(),
-		scanner:    scanner.New(sudoMgr),
-		cleaner:    cleaner.New(sudoMgr),
-		targets:    models.GetDefaultTargets(),
+		scanner:    s,
+		cleaner:    c,
+		history:    history.New(history.DefaultPath()),
+		targets:    set.targets,
 		selections: make(map[int]bool),
 	}
 }
@@ -44,7 +76,7 @@ func (a *app) run() {
 	a.term.HideCursor()
 
 	for {
-		key := a.term.PrintMenu()
+		key := a.term.PrintMenu(a.useTrash)
 		switch key {
 		case "1":
 			a.runCleanup()
@@ -54,18 +86,170 @@ func (a *app) run() {
 			a.runDuplicates()
 		case "4":
 			a.runOldFiles()
-		case "q", "Q":
+		case "5":
+			a.runDiskUsage()
+		case "h", "H":
+			a.showHistory()
+		case "s", "S":
+			a.editScope()
+		case "t", "T":
+			a.toggleTrash()
+		case "q", "Q", "ctrl-c":
 			return
 		}
 	}
 }
 
+// toggleTrash switches between permanent deletion and the quarantine
+func (a *app) toggleTrash() {
+	a.useTrash = !a.useTrash
+	if a.useTrash {
+		a.cleaner.Trash = quarantine.New(quarantine.DefaultDir(), a.cleaner.SudoManager)
+	} else {
+		a.cleaner.Trash = nil
+	}
+}
+
+// cancellable runs fn with a context cancelled by Esc, Ctrl-C, SIGINT or
+// SIGTERM. The terminal is restored before it returns.
+func (a *app) cancellable(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := a.term.WatchCancel(cancel)
+	defer watcher.Stop()
+
+	// Hand the terminal back to sudo if it needs a password
+	a.cleaner.SudoManager.Suspend = watcher.Suspend
+	defer func() { a.cleaner.SudoManager.Suspend = nil }()
+
+	return fn(ctx)
+}
+
+// cancelled tells the user when err is a cancellation and partial results follow
+func (a *app) cancelled(err error, message string) {
+	if errors.Is(err, context.Canceled) {
+		a.term.PrintCancelled(message)
+	}
+}
+
+// showHistory lists the space reclaimed per target by past runs
+func (a *app) showHistory() {
+	runs, err := a.history.Load()
+	if err != nil {
+		for {
+			key := a.term.PrintDone(0, fmt.Sprintf("read history: %v", err))
+			switch key {
+			case "q", "Q", "ctrl-c":
+				os.Exit(0)
+			case "b", "B":
+				return
+			}
+		}
+	}
+	summaries := history.Summarize(runs)
+
+	cursor := 0
+	for {
+		key := a.term.PrintHistory(summaries, cursor)
+		switch key {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B":
+			return
+		case "up":
+			if cursor > 0 {
+				cursor--
+			}
+		case "down":
+			if cursor < len(summaries)-1 {
+				cursor++
+			}
+		}
+	}
+}
+
+// editScope lets the user change the finders' roots, exclude patterns and
+// toggles for the rest of the session
+func (a *app) editScope() {
+	cursor := 0
+	message := ""
+	for {
+		s := &a.scanner.Scope
+		count := len(s.Roots) + len(s.Exclude)
+		if cursor >= count {
+			cursor = count - 1
+		}
+		if cursor < 0 {
+			cursor = 0
+		}
+
+		key := a.term.PrintScope(*s, cursor, message)
+		message = ""
+		switch key {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B":
+			return
+		case "up":
+			if cursor > 0 {
+				cursor--
+			}
+		case "down":
+			if cursor < count-1 {
+				cursor++
+			}
+		case "r", "R":
+			root := a.term.ReadLine("Directory to search (absolute or ~/...): ")
+			switch {
+			case root == "":
+			case !strings.HasPrefix(root, "/") && !strings.HasPrefix(root, "~/"):
+				message = fmt.Sprintf("%q must be absolute or start with ~/", root)
+			default:
+				s.Roots = append(s.Roots, root)
+				cursor = len(s.Roots) - 1
+			}
+		case "e", "E":
+			pattern := a.term.ReadLine("Pattern to exclude (! re-includes): ")
+			if pattern == "" {
+				break
+			}
+			if err := (scope.Scope{Exclude: []string{pattern}}).Validate(); err != nil {
+				message = err.Error()
+				break
+			}
+			s.Exclude = append(s.Exclude, pattern)
+			cursor = len(s.Roots) + len(s.Exclude) - 1
+		case "d", "D", "x", "X":
+			if cursor < len(s.Roots) {
+				s.Roots = slices.Delete(slices.Clone(s.Roots), cursor, cursor+1)
+			} else if cursor < count {
+				i := cursor - len(s.Roots)
+				s.Exclude = slices.Delete(slices.Clone(s.Exclude), i, i+1)
+			}
+		case ".":
+			s.Hidden = !s.Hidden
+		case "i", "I":
+			s.IgnoreFiles = !s.IgnoreFiles
+		}
+	}
+}
+
+// record appends a run to the history and returns a message describing
+// why it could not be saved, or "" on success
+func (a *app) record(run history.Run) string {
+	if err := a.history.Append(run); err != nil {
+		return fmt.Sprintf("record history: %v", err)
+	}
+	return ""
+}
+
 func (a *app) runCleanup() {
 	a.cursor = 0
 	for {
 		key := a.term.PrintTargets(a.targets, a.cursor)
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
@@ -108,33 +292,45 @@ func (a *app) scanTargets() {
 		return
 	}
 
-	for i := range a.targets {
-		if !a.targets[i].Selected {
-			continue
+	err := a.cancellable(func(ctx context.Context) error {
+		for i := range a.targets {
+			if !a.targets[i].Selected {
+				continue
+			}
+			a.term.PrintScanning(fmt.Sprintf("Scanning: %s", a.targets[i].Name))
+			size, err := a.scanner.CalculateSizeForTargetContext(ctx, &a.targets[i])
+			a.targets[i].Size = size
+			if err != nil {
+				return err
+			}
 		}
-		a.term.PrintScanning(fmt.Sprintf("Scanning: %s", a.targets[i].Name))
-		size := a.scanner.CalculateSizeForTarget(&a.targets[i])
-		a.targets[i].Size = size
-	}
+		return nil
+	})
+	a.cancelled(err, "Scan cancelled. Sizes not yet calculated are partial.")
 
-	a.cursor = 0
+	var items []ltui.Item
+	for _, t := range a.targets {
+		path := t.Path
+		if t.IsCommand {
+			path = t.Command
+		}
+		items = append(items, ltui.Item{Name: t.Name, Path: path, Size: t.Size})
+	}
+	list := ltui.NewList(items, ltui.SortDefault, ltui.SortSize, ltui.SortName, ltui.SortPath)
 	for {
-		key := a.term.PrintResults(a.targets, a.cursor)
+		key := a.term.PrintResults(a.targets, list)
+		if list.Handle(key) {
+			continue
+		}
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
-		case "up":
-			if a.cursor > 0 {
-				a.cursor--
-			}
-		case "down":
-			if a.cursor < len(a.targets)-1 {
-				a.cursor++
-			}
 		case " ":
-			a.targets[a.cursor].Selected = !a.targets[a.cursor].Selected
+			if i := list.Current(); i >= 0 {
+				a.targets[i].Selected = !a.targets[i].Selected
+			}
 		case "r", "R":
 			a.scanTargets()
 			return
@@ -148,26 +344,82 @@ func (a *app) scanTargets() {
 }
 
 func (a *app) confirmAndClean() {
-	key := a.term.PrintConfirm(a.targets)
-	switch key {
-	case "y", "Y":
-		a.cleanTargets()
+	for {
+		key := a.term.PrintConfirm(a.targets, a.useTrash)
+		switch key {
+		case "y", "Y":
+			a.cleanTargets()
+			return
+		case "p", "P":
+			a.preview(func(c *cleaner.Cleaner) {
+				c.CleanTargets(a.targets, func(string) {})
+			})
+		default:
+			return
+		}
+	}
+}
+
+// preview runs fn against a dry-run cleaner and lists what it would delete
+func (a *app) preview(fn func(c *cleaner.Cleaner)) {
+	a.term.PrintScanning("Preparing preview...")
+
+	var plan []models.PlannedDeletion
+	fn(a.cleaner.WithDryRun(func(p models.PlannedDeletion) {
+		plan = append(plan, p)
+	}))
+
+	cursor := 0
+	for {
+		key := a.term.PrintPreview(plan, cursor)
+		switch key {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B":
+			return
+		case "up":
+			if cursor > 0 {
+				cursor--
+			}
+		case "down":
+			if cursor < len(plan)-1 {
+				cursor++
+			}
+		}
 	}
 }
 
 func (a *app) cleanTargets() {
 	a.term.PrintCleaning("Starting cleanup...")
 
-	results, totalSaved := a.cleaner.CleanTargets(a.targets, func(status string) {
-		a.term.PrintCleaning(status)
+	var results []cleaner.CleanResult
+	var totalSaved int64
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		results, totalSaved, err = a.cleaner.CleanTargetsContext(ctx, a.targets, func(status string) {
+			a.term.PrintCleaning(status)
+		})
+		return err
 	})
 
 	// Collect errors
 	var errorDetails []string
+	if err != nil {
+		errorDetails = append(errorDetails, fmt.Sprintf("Cleanup cancelled after %d targets", len(results)))
+	}
 	for _, r := range results {
 		if r.Error != nil {
 			errorDetails = append(errorDetails, fmt.Sprintf("%s: %v", r.Target, r.Error))
 		}
+		if len(r.Refused) > 0 {
+			errorDetails = append(errorDetails, fmt.Sprintf("%s: refused %d paths outside the target directory, e.g. %s",
+				r.Target, len(r.Refused), utils.ShortenPath(r.Refused[0], 50)))
+		}
+	}
+	if len(results) > 0 {
+		if msg := a.record(history.FromCleanResults(results, a.useTrash)); msg != "" {
+			errorDetails = append(errorDetails, msg)
+		}
 	}
 
 	lastError := ""
@@ -178,7 +430,7 @@ func (a *app) cleanTargets() {
 	for {
 		key := a.term.PrintDone(totalSaved, lastError)
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
@@ -199,7 +451,7 @@ func (a *app) runBigFiles() {
 		minSize = 1024 * 1024 * 1024
 	case "4":
 		minSize = 5 * 1024 * 1024 * 1024
-	case "b", "B", "q", "Q":
+	case "b", "B", "q", "Q", "ctrl-c":
 		return
 	default:
 		return
@@ -207,9 +459,14 @@ func (a *app) runBigFiles() {
 
 	// Scan
 	a.term.PrintScanning("Scanning for large files...")
-	a.bigFiles = a.scanner.ScanBigFiles(minSize, func(status string) {
-		a.term.PrintScanning(status)
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		a.bigFiles, err = a.scanner.ScanBigFilesContext(ctx, minSize, func(status string) {
+			a.term.PrintScanning(status)
+		})
+		return err
 	})
+	a.cancelled(err, fmt.Sprintf("Scan cancelled. Showing the %d files found so far.", len(a.bigFiles)))
 
 	// Sort by size
 	sort.Slice(a.bigFiles, func(i, j int) bool {
@@ -217,32 +474,52 @@ func (a *app) runBigFiles() {
 	})
 
 	a.selections = make(map[int]bool)
-	a.cursor = 0
 
-	// Results
+	// Results, shown by kind: "" for every kind
+	var items []ltui.Item
+	for _, f := range a.bigFiles {
+		items = append(items, ltui.Item{Name: filepath.Base(f.Path), Path: f.Path, Size: f.Size, ModTime: f.ModTime})
+	}
+	list := ltui.NewList(items, ltui.SortSize, ltui.SortAge, ltui.SortPath, ltui.SortName)
+	var kind filetype.Kind
 	for {
-		key := a.term.PrintBigFilesResults(a.bigFiles, a.selections, a.cursor, minSize)
+		key := a.term.PrintBigFilesResults(a.bigFiles, list, a.selections, minSize, kind)
+		if list.Handle(key) {
+			continue
+		}
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
-		case "up":
-			if a.cursor > 0 {
-				a.cursor--
-			}
-		case "down":
-			if a.cursor < len(a.bigFiles)-1 {
-				a.cursor++
-			}
 		case " ":
-			if len(a.bigFiles) > 0 {
-				a.selections[a.cursor] = !a.selections[a.cursor]
+			if i := list.Current(); i >= 0 {
+				a.selections[i] = !a.selections[i]
 			}
 		case "a", "A":
-			for i := range a.bigFiles {
+			for _, i := range list.Rows() {
 				a.selections[i] = true
 			}
+		case "t", "T":
+			kind = nextKind(a.bigFiles, kind)
+			list.Only = nil
+			if kind != "" {
+				list.Only = func(i int) bool { return a.bigFiles[i].Kind == kind }
+			}
+			list.Cursor = 0
+		case "g", "G":
+			if list.Group != nil {
+				list.Group = nil
+			} else {
+				list.Group = kindRank(a.bigFiles)
+			}
+			list.Cursor = 0
+		case "p", "P":
+			if models.HasBigFilesSelection(a.selections) {
+				a.preview(func(c *cleaner.Cleaner) {
+					c.DeleteBigFiles(a.bigFiles, a.selections, func(string) {})
+				})
+			}
 		case "d", "D":
 			if models.HasBigFilesSelection(a.selections) {
 				a.deleteBigFiles()
@@ -252,16 +529,55 @@ func (a *app) runBigFiles() {
 	}
 }
 
+// nextKind returns the kind shown after kind: every kind present in files,
+// largest total first, and then "" for all of them
+func nextKind(files []models.BigFile, kind filetype.Kind) filetype.Kind {
+	totals := models.TotalByKind(files)
+	for i, total := range totals {
+		if total.Kind == kind {
+			if i+1 < len(totals) {
+				return totals[i+1].Kind
+			}
+			return ""
+		}
+	}
+	if len(totals) > 0 {
+		return totals[0].Kind
+	}
+	return ""
+}
+
+// kindRank orders files by kind, the kind with the largest total first
+func kindRank(files []models.BigFile) func(i int) int {
+	rank := make(map[filetype.Kind]int)
+	for i, total := range models.TotalByKind(files) {
+		rank[total.Kind] = i
+	}
+	return func(i int) int { return rank[files[i].Kind] }
+}
+
 func (a *app) deleteBigFiles() {
 	a.term.PrintCleaning("Deleting files...")
-	totalDeleted := a.cleaner.DeleteBigFiles(a.bigFiles, a.selections, func(status string) {
-		a.term.PrintCleaning(status)
+	var requested int64
+	for i, f := range a.bigFiles {
+		if a.selections[i] {
+			requested += f.Size
+		}
+	}
+	var report cleaner.DeleteReport
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		report, err = a.cleaner.DeleteBigFilesContext(ctx, a.bigFiles, a.selections, func(status string) {
+			a.term.PrintCleaning(status)
+		})
+		return err
 	})
+	msg := deletionMessage(report, err, a.record(history.FromDeletion("bigfiles", "Big Files", requested, report, a.useTrash)))
 
 	for {
-		key := a.term.PrintDone(totalDeleted, "")
+		key := a.term.PrintDone(report.Freed(), msg)
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
@@ -269,66 +585,245 @@ func (a *app) deleteBigFiles() {
 	}
 }
 
+// runDuplicates shows the Duplicate Finder's filters, which last for the
+// rest of the session, until a scan is started
 func (a *app) runDuplicates() {
-	key := a.term.PrintDuplicatesConfig()
-	switch key {
-	case "s", "S":
-		a.scanDuplicates()
-	case "b", "B":
-		return
-	case "q", "Q":
-		os.Exit(0)
+	message := ""
+	for {
+		var roots []string
+		for _, root := range a.scanner.DuplicateRoots(models.GroupIdentical) {
+			roots = append(roots, utils.ContractPath(root))
+		}
+		key := a.term.PrintDuplicatesConfig(a.scanner.Duplicates, roots, message)
+		message = ""
+		f := a.scanner.Duplicates
+		switch key {
+		case "s", "S":
+			a.scanDuplicates(models.GroupIdentical)
+			return
+		case "i", "I":
+			a.scanDuplicates(models.GroupSimilar)
+			return
+		case "f", "F":
+			a.scanDuplicates(models.GroupDirectory)
+			return
+		case "m", "M":
+			size, err := utils.ParseSize(a.term.ReadLine("Minimum size (e.g. 100KB, 0 for any): "))
+			if err != nil {
+				message = err.Error()
+				break
+			}
+			f.MinSize = size
+		case "x", "X":
+			input := a.term.ReadLine("Maximum size (e.g. 4GB, empty for none): ")
+			f.MaxSize = 0
+			if input != "" {
+				size, err := utils.ParseSize(input)
+				if err != nil {
+					message = err.Error()
+					break
+				}
+				f.MaxSize = size
+			}
+		case "e", "E":
+			f.Extensions = nil
+			for _, ext := range strings.Fields(strings.ReplaceAll(a.term.ReadLine("Extensions, e.g. psd ai (empty for any): "), ",", " ")) {
+				f.Extensions = append(f.Extensions, filetype.NormalizeExtension(ext))
+			}
+		case "t", "T":
+			f.Kinds = nil
+			for _, name := range strings.Fields(strings.ReplaceAll(a.term.ReadLine("Kinds: "+filetype.KindNames()+" (empty for any): "), ",", " ")) {
+				kind, err := filetype.ParseKind(name)
+				if err != nil {
+					message = err.Error()
+					break
+				}
+				f.Kinds = append(f.Kinds, kind)
+			}
+		case "r", "R":
+			a.editScope()
+		case "b", "B":
+			return
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		default:
+			return
+		}
+		if message == "" {
+			if err := f.Validate(); err != nil {
+				message = err.Error()
+			} else {
+				a.scanner.Duplicates = f
+			}
+		}
 	}
 }
 
-func (a *app) scanDuplicates() {
-	a.term.PrintScanning("Scanning for duplicates...")
-	groups, _ := a.scanner.ScanDuplicates(func(status string) {
+// scanDuplicates finds groups of kind: identical files, images that look
+// alike or identical directory trees, and lets the user choose what to delete
+func (a *app) scanDuplicates(kind models.GroupKind) {
+	progress := func(status string) {
 		a.term.PrintScanning(status)
-	})
-	a.duplicateGroups = groups
+	}
+	var err error
+	if kind == models.GroupSimilar {
+		a.term.PrintScanning("Scanning for similar images...")
+		err = a.cancellable(func(ctx context.Context) error {
+			var err error
+			a.duplicateGroups, _, err = a.scanner.ScanSimilarImagesContext(ctx, progress)
+			return err
+		})
+	} else {
+		a.term.PrintScanning("Scanning for duplicates...")
+		// The hash cache only saves time, so a broken one is ignored and
+		// every file is hashed again
+		a.scanner.Hashes.Load()
+		err = a.cancellable(func(ctx context.Context) error {
+			var err error
+			if kind == models.GroupDirectory {
+				a.duplicateGroups, _, err = a.scanner.ScanDuplicateDirsContext(ctx, progress)
+			} else {
+				a.duplicateGroups, _, err = a.scanner.ScanDuplicatesContext(ctx, progress)
+			}
+			return err
+		})
+		a.scanner.Hashes.Save()
+	}
+	a.cancelled(err, fmt.Sprintf("Scan cancelled. Showing the %d groups verified so far.", len(a.duplicateGroups)))
 	a.selections = make(map[int]bool)
-	a.cursor = 0
 
-	// Show results and allow selection
+	// Show results and allow selection; groups are found by their first file
+	var items []ltui.Item
+	for _, g := range a.duplicateGroups {
+		items = append(items, ltui.Item{Name: filepath.Base(g.Files[0]), Path: g.Files[0], Size: g.Size})
+	}
+	list := ltui.NewList(items, ltui.SortSize, ltui.SortPath, ltui.SortName)
 	for {
-		key := a.term.PrintDuplicatesResults(a.duplicateGroups, a.selections, a.cursor)
+		key := a.term.PrintDuplicatesResults(a.duplicateGroups, list, a.selections, a.scanner.KeepPolicy, a.scanner.DescribeDuplicates(kind))
+		if list.Handle(key) {
+			continue
+		}
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
-		case "up":
-			if a.cursor > 0 {
-				a.cursor--
+		case " ":
+			if i := list.Current(); i >= 0 {
+				a.selections[i] = !a.selections[i]
 			}
-		case "down":
-			if a.cursor < len(a.duplicateGroups)-1 {
-				a.cursor++
+		case "right", "\n", "\r":
+			if i := list.Current(); i >= 0 {
+				a.chooseCopies(i)
 			}
-		case " ":
-			if len(a.duplicateGroups) > 0 {
-				a.selections[a.cursor] = !a.selections[a.cursor]
+		case "k", "K":
+			a.nextKeepPolicy()
+		case "p", "P":
+			if models.HasDuplicateSelection(a.selections) {
+				a.preview(func(c *cleaner.Cleaner) {
+					c.DeleteDuplicates(a.duplicateGroups, a.selections, func(string) {})
+				})
 			}
 		case "d", "D":
 			if models.HasDuplicateSelection(a.selections) {
-				a.deleteDuplicates()
+				a.deleteDuplicates(cleaner.DedupeOff)
+				return
+			}
+		case "l", "L":
+			// Only identical files can be replaced with links
+			if models.HasDuplicateSelection(a.selections) && kind == models.GroupIdentical {
+				a.deleteDuplicates(cleaner.DedupeAuto)
 				return
 			}
 		}
 	}
 }
 
-func (a *app) deleteDuplicates() {
-	a.term.PrintCleaning("Deleting duplicate files...")
-	totalDeleted := a.cleaner.DeleteDuplicates(a.duplicateGroups, a.selections, func(status string) {
-		a.term.PrintCleaning(status)
+// chooseCopies lets the user pick which copies of one group are kept. A
+// group with a copy marked for deletion is selected on the way out.
+func (a *app) chooseCopies(index int) {
+	group := &a.duplicateGroups[index]
+	if len(group.Keep) != len(group.Files) {
+		group.Keep = make([]bool, len(group.Files))
+		group.Keep[0] = true
+	}
+
+	cursor := 0
+	message := ""
+	for {
+		key := a.term.PrintDuplicateGroup(*group, index+1, cursor, message)
+		message = ""
+		switch key {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B", "left":
+			if len(group.Removed()) > 0 {
+				a.selections[index] = true
+			}
+			return
+		case "up":
+			if cursor > 0 {
+				cursor--
+			}
+		case "down":
+			if cursor < len(group.Files)-1 {
+				cursor++
+			}
+		case " ":
+			group.Keep[cursor] = !group.Keep[cursor]
+			if len(group.Kept()) == 0 {
+				group.Keep[cursor] = true
+				message = "At least one copy must be kept"
+			}
+		}
+	}
+}
+
+// nextKeepPolicy switches to the next keep policy and reapplies it to every
+// group, replacing copies chosen by hand. The preferred policy is skipped
+// when no preferred directories are configured.
+func (a *app) nextKeepPolicy() {
+	i := slices.Index(models.KeepPolicies, a.scanner.KeepPolicy)
+	for {
+		i = (i + 1) % len(models.KeepPolicies)
+		if models.KeepPolicies[i] != models.KeepPreferred || len(a.scanner.KeepPreferred) > 0 {
+			break
+		}
+	}
+	a.scanner.KeepPolicy = models.KeepPolicies[i]
+	scanner.ChooseKeep(a.duplicateGroups, a.scanner.KeepPolicy, a.scanner.KeepPreferred)
+}
+
+// deleteDuplicates deletes the removed copies of the selected groups, or
+// replaces them with clones or hard links unless dedupe is DedupeOff
+func (a *app) deleteDuplicates(dedupe cleaner.DedupeMode) {
+	a.cleaner.Dedupe = dedupe
+	defer func() { a.cleaner.Dedupe = cleaner.DedupeOff }()
+	if dedupe != cleaner.DedupeOff {
+		a.term.PrintCleaning("Replacing duplicate files...")
+	} else {
+		a.term.PrintCleaning("Deleting duplicate files...")
+	}
+	var requested int64
+	for i, g := range a.duplicateGroups {
+		if a.selections[i] {
+			requested += g.Reclaimable()
+		}
+	}
+	var report cleaner.DeleteReport
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		report, err = a.cleaner.DeleteDuplicatesContext(ctx, a.duplicateGroups, a.selections, func(status string) {
+			a.term.PrintCleaning(status)
+		})
+		return err
 	})
+	msg := deletionMessage(report, err, a.record(history.FromDeletion("duplicates", "Duplicates", requested, report, a.useTrash)))
 
 	for {
-		key := a.term.PrintDone(totalDeleted, "")
+		key := a.term.PrintDone(report.Freed(), msg)
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
@@ -337,56 +832,77 @@ func (a *app) deleteDuplicates() {
 }
 
 func (a *app) runOldFiles() {
-	key := a.term.PrintOldFilesConfig()
 	var days int
-	switch key {
-	case "1":
-		days = 30
-	case "2":
-		days = 90
-	case "3":
-		days = 180
-	case "4":
-		days = 365
-	case "b", "B":
-		return
-	case "q", "Q":
-		os.Exit(0)
-	default:
-		return
+	for days == 0 {
+		by := a.scanner.OldFilesBy
+		key := a.term.PrintOldFilesConfig(by, a.scanner.AccessTimeWarnings())
+		switch key {
+		case "1":
+			days = 30
+		case "2":
+			days = 90
+		case "3":
+			days = 180
+		case "4":
+			days = 365
+		case "5":
+			n, err := strconv.Atoi(a.term.ReadLine("Minimum age in days: "))
+			if err == nil && n > 0 {
+				days = n
+			}
+		case "t", "T":
+			i := slices.Index(models.FileTimes, by)
+			a.scanner.OldFilesBy = models.FileTimes[(i+1)%len(models.FileTimes)]
+		case "b", "B":
+			return
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		default:
+			return
+		}
 	}
 
 	a.term.PrintScanning(fmt.Sprintf("Scanning for files > %d days old...", days))
-	a.oldFiles = a.scanner.ScanOldFiles(days, func(status string) {
-		a.term.PrintScanning(status)
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		a.oldFiles, err = a.scanner.ScanOldFilesContext(ctx, days, func(status string) {
+			a.term.PrintScanning(status)
+		})
+		return err
 	})
+	a.cancelled(err, fmt.Sprintf("Scan cancelled. Showing the %d files found so far.", len(a.oldFiles)))
 	a.selections = make(map[int]bool)
-	a.cursor = 0
 
 	// Show results and allow selection
+	var items []ltui.Item
+	for _, f := range a.oldFiles {
+		items = append(items, ltui.Item{Name: filepath.Base(f.Path), Path: f.Path, Size: f.Size, ModTime: f.Time(a.scanner.OldFilesBy)})
+	}
+	list := ltui.NewList(items, ltui.SortAge, ltui.SortSize, ltui.SortPath, ltui.SortName)
 	for {
-		key := a.term.PrintOldFilesResults(a.oldFiles, a.selections, a.cursor, days)
+		key := a.term.PrintOldFilesResults(a.oldFiles, list, a.selections, days, a.scanner.OldFilesBy)
+		if list.Handle(key) {
+			continue
+		}
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
-		case "up":
-			if a.cursor > 0 {
-				a.cursor--
-			}
-		case "down":
-			if a.cursor < len(a.oldFiles)-1 {
-				a.cursor++
-			}
 		case " ":
-			if len(a.oldFiles) > 0 {
-				a.selections[a.cursor] = !a.selections[a.cursor]
+			if i := list.Current(); i >= 0 {
+				a.selections[i] = !a.selections[i]
 			}
 		case "a", "A":
-			for i := range a.oldFiles {
+			for _, i := range list.Rows() {
 				a.selections[i] = true
 			}
+		case "p", "P":
+			if models.HasOldFilesSelection(a.selections) {
+				a.preview(func(c *cleaner.Cleaner) {
+					c.DeleteOldFiles(a.oldFiles, a.selections, func(string) {})
+				})
+			}
 		case "d", "D":
 			if models.HasOldFilesSelection(a.selections) {
 				a.deleteOldFiles()
@@ -398,14 +914,26 @@ func (a *app) runOldFiles() {
 
 func (a *app) deleteOldFiles() {
 	a.term.PrintCleaning("Deleting old files...")
-	totalDeleted := a.cleaner.DeleteOldFiles(a.oldFiles, a.selections, func(status string) {
-		a.term.PrintCleaning(status)
+	var requested int64
+	for i, f := range a.oldFiles {
+		if a.selections[i] {
+			requested += f.Size
+		}
+	}
+	var report cleaner.DeleteReport
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		report, err = a.cleaner.DeleteOldFilesContext(ctx, a.oldFiles, a.selections, func(status string) {
+			a.term.PrintCleaning(status)
+		})
+		return err
 	})
+	msg := deletionMessage(report, err, a.record(history.FromDeletion("oldfiles", "Old Files", requested, report, a.useTrash)))
 
 	for {
-		key := a.term.PrintDone(totalDeleted, "")
+		key := a.term.PrintDone(report.Freed(), msg)
 		switch key {
-		case "q", "Q":
+		case "q", "Q", "ctrl-c":
 			os.Exit(0)
 		case "b", "B":
 			return
@@ -413,9 +941,258 @@ func (a *app) deleteOldFiles() {
 	}
 }
 
+// runDiskUsage explores the size tree below a chosen directory and deletes
+// the entries the user marks
+func (a *app) runDiskUsage() {
+	root := a.term.ReadLine("Directory to explore (empty for your home folder): ")
+	if root == "" {
+		root = "~/"
+	}
+	root = filepath.Clean(utils.ExpandPath(root))
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		for {
+			key := a.term.PrintDone(0, fmt.Sprintf("%s is not a directory", root))
+			switch key {
+			case "q", "Q", "ctrl-c":
+				os.Exit(0)
+			case "b", "B":
+				return
+			}
+		}
+	}
+
+	if a.usageTree == nil || a.usageTree.Path != root {
+		a.usageTree = &usage.Node{Name: root, Path: root, IsDir: true}
+	}
+	a.refreshUsage(a.usageTree)
+
+	dir := a.usageTree
+	key := usage.BySize
+	marked := make(map[string]*usage.Node)
+	cursor := 0
+	for {
+		children := dir.Sorted(key)
+		if cursor >= len(children) {
+			cursor = len(children) - 1
+		}
+		if cursor < 0 {
+			cursor = 0
+		}
+
+		switch a.term.PrintUsage(dir, children, marked, cursor, key) {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B":
+			return
+		case "up":
+			if cursor > 0 {
+				cursor--
+			}
+		case "down":
+			if cursor < len(children)-1 {
+				cursor++
+			}
+		case "right", "\n", "\r":
+			if len(children) > 0 && children[cursor].IsDir {
+				dir = children[cursor]
+				cursor = 0
+			}
+		case "left":
+			if dir.Parent != nil {
+				// Put the cursor back on the directory we came from
+				from := dir
+				dir = dir.Parent
+				cursor = slices.Index(dir.Sorted(key), from)
+			}
+		case "s", "S":
+			key = key.Next()
+			cursor = 0
+		case " ":
+			if len(children) > 0 {
+				node := children[cursor]
+				if marked[node.Path] != nil {
+					delete(marked, node.Path)
+				} else {
+					marked[node.Path] = node
+				}
+			}
+		case "r", "R":
+			a.refreshUsage(dir)
+		case "p", "P":
+			if len(marked) > 0 {
+				a.preview(func(c *cleaner.Cleaner) {
+					c.DeleteFiles(markedPaths(marked), func(string) {})
+				})
+			}
+		case "d", "D":
+			if len(marked) > 0 {
+				a.deleteMarked(marked)
+				marked = make(map[string]*usage.Node)
+			}
+		}
+	}
+}
+
+// refreshUsage brings node up to date, reusing unchanged directories
+func (a *app) refreshUsage(node *usage.Node) {
+	a.term.PrintScanning("Scanning " + utils.ShortenPath(node.Path, 40) + "...")
+	shown := 0
+	err := a.cancellable(func(ctx context.Context) error {
+		return usage.Refresh(ctx, node, a.scanner.Workers, func(scanned int) {
+			if scanned-shown >= 1000 {
+				shown = scanned
+				a.term.PrintScanning(fmt.Sprintf("Scanned %d files...", scanned))
+			}
+		})
+	})
+	a.cancelled(err, "Scan cancelled. Sizes are partial; press r to finish scanning.")
+}
+
+// deleteMarked deletes the marked entries and drops them from the tree
+func (a *app) deleteMarked(marked map[string]*usage.Node) {
+	a.term.PrintCleaning("Deleting...")
+	var requested int64
+	for _, node := range marked {
+		requested += node.Size
+	}
+
+	var report cleaner.DeleteReport
+	err := a.cancellable(func(ctx context.Context) error {
+		var err error
+		report, err = a.cleaner.DeleteFilesContext(ctx, markedPaths(marked), func(status string) {
+			a.term.PrintCleaning(status)
+		})
+		return err
+	})
+	for _, node := range marked {
+		if _, statErr := os.Lstat(node.Path); os.IsNotExist(statErr) {
+			node.Remove()
+		}
+	}
+	msg := deletionMessage(report, err, a.record(history.FromDeletion("diskusage", "Disk Usage", requested, report, a.useTrash)))
+
+	for {
+		key := a.term.PrintDone(report.Freed(), msg)
+		switch key {
+		case "q", "Q", "ctrl-c":
+			os.Exit(0)
+		case "b", "B":
+			return
+		}
+	}
+}
+
+// maxListedFailures caps the failed paths listed on the done screen
+const maxListedFailures = 10
+
+// deletionMessage describes what a file deletion left undone for PrintDone:
+// the cancellation, the paths that failed and the history error, if any
+func deletionMessage(report cleaner.DeleteReport, err error, recordErr string) string {
+	var lines []string
+	if err != nil {
+		lines = append(lines, "Deletion cancelled")
+	}
+	if failures := report.Failures(); len(failures) > 0 {
+		lines = append(lines, report.Summary())
+		for i, f := range failures {
+			if i == maxListedFailures {
+				lines = append(lines, fmt.Sprintf("... and %d more", len(failures)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", f.Status.Label(), utils.ShortenPath(f.Path, 50)))
+		}
+	}
+	if recordErr != "" {
+		lines = append(lines, recordErr)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markedPaths returns the paths of the marked entries in a stable order
+func markedPaths(marked map[string]*usage.Node) []string {
+	paths := make([]string, 0, len(marked))
+	for path := range marked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// settings is the configuration shared by the CLI and the TUI
+type settings struct {
+	targets   []models.CleanupTarget
+	scope     scope.Scope
+	protected []string               // Extra paths the cleaner never deletes
+	keep      models.KeepPolicy      // Copy of each duplicate group kept by default
+	prefer    []string               // Directories for models.KeepPreferred
+	filter    models.DuplicateFilter // Files the Duplicate Finder compares
+	imageHash imagehash.Algorithm    // Perceptual hash for finding similar images
+	distance  int                    // Differing image hash bits up to which images are similar
+}
+
+// loadConfig returns the built-in settings with the user's config layered
+// on top
+func loadConfig() (settings, error) {
+	path := config.DefaultPath()
+	cfg, err := config.Load(path)
+	if err != nil {
+		return settings{}, err
+	}
+
+	var set settings
+	var errs []error
+	set.targets, err = cfg.ApplyTargets(models.GetDefaultTargets())
+	errs = append(errs, err)
+	set.scope, err = cfg.ApplyScope(scope.Default())
+	errs = append(errs, err)
+	set.protected, err = cfg.ProtectedPaths()
+	errs = append(errs, err)
+	set.keep, set.prefer, err = cfg.KeepPolicy()
+	errs = append(errs, err)
+	set.imageHash, set.distance, err = cfg.SimilarImages()
+	errs = append(errs, err)
+	set.filter, err = cfg.DuplicateFilter()
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		return settings{}, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	return set, nil
+}
+
+// usesConfig reports whether the command in args, or the TUI when there is
+// none, uses the settings from the config file. The others run even when
+// the file is broken, so help, restoring and the history keep working.
+func usesConfig(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch args[0] {
+	case "help", "-h", "--help", "restore", "purge", "history", "cache":
+		return false
+	}
+	return true
+}
+
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	app := newApp()
+	var set settings
+	if usesConfig(os.Args[1:]) {
+		var err error
+		if set, err = loadConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "macos-cleaner: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	// Subcommands run non-interactively; no arguments starts the TUI
+	if len(os.Args) > 1 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := newCLI(set).run(ctx, os.Args[1:])
+		stop()
+		os.Exit(code)
+	}
+
+	app := newApp(set)
 	app.run()
 }